@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"go-micro.org/v5/api/router"
@@ -55,10 +57,20 @@ type Endpoint struct {
 	Host []string
 	// HTTP Methods e.g GET, POST
 	Method []string
+	// HTTP Headers the request must carry e.g X-Api-Version: 2
+	Header map[string]string
 	// HTTP Path e.g /greeter. Expect POSIX regex
 	Path []string
 	// Stream flag
 	Stream bool
+	// MaxRecvSize overrides the handler max body size when non zero
+	MaxRecvSize int64
+	// StripPrefix is the leading path segments removed before proxying e.g /v1
+	StripPrefix string
+	// Rewrite is a POSIX regex matched against the proxied path e.g ^/v1/(.*)$
+	Rewrite string
+	// RewriteTo replaces the Rewrite matches e.g /$1
+	RewriteTo string
 }
 
 // Service represents an API service.
@@ -87,6 +99,37 @@ func slice(s string) []string {
 	return sl
 }
 
+// joinHeader encodes headers as a sorted list of key=value pairs.
+func joinHeader(h map[string]string) string {
+	hs := make([]string, 0, len(h))
+	for k, v := range h {
+		hs = append(hs, k+"="+v)
+	}
+
+	sort.Strings(hs)
+
+	return strings.Join(hs, ",")
+}
+
+func splitHeader(s string) map[string]string {
+	var h map[string]string
+
+	for _, kv := range slice(s) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if h == nil {
+			h = make(map[string]string)
+		}
+
+		h[strip(parts[0])] = strip(parts[1])
+	}
+
+	return h
+}
+
 // Encode encodes an endpoint to endpoint metadata.
 func Encode(e *Endpoint) map[string]string {
 	if e == nil {
@@ -111,6 +154,14 @@ func Encode(e *Endpoint) map[string]string {
 	set("method", strings.Join(e.Method, ","))
 	set("path", strings.Join(e.Path, ","))
 	set("host", strings.Join(e.Host, ","))
+	set("header", joinHeader(e.Header))
+	set("strip_prefix", e.StripPrefix)
+	set("rewrite", e.Rewrite)
+	set("rewrite_to", e.RewriteTo)
+
+	if e.MaxRecvSize > 0 {
+		set("max_recv_size", strconv.FormatInt(e.MaxRecvSize, 10))
+	}
 
 	return em
 }
@@ -121,13 +172,21 @@ func Decode(e map[string]string) *Endpoint {
 		return nil
 	}
 
+	// ignore an invalid size, the handler default applies
+	size, _ := strconv.ParseInt(e["max_recv_size"], 10, 64)
+
 	return &Endpoint{
 		Name:        e["endpoint"],
 		Description: e["description"],
 		Method:      slice(e["method"]),
 		Path:        slice(e["path"]),
 		Host:        slice(e["host"]),
+		Header:      splitHeader(e["header"]),
 		Handler:     e["handler"],
+		MaxRecvSize: size,
+		StripPrefix: e["strip_prefix"],
+		Rewrite:     e["rewrite"],
+		RewriteTo:   e["rewrite_to"],
 	}
 }
 
@@ -157,6 +216,12 @@ func Validate(e *Endpoint) error {
 		}
 	}
 
+	if len(e.Rewrite) > 0 {
+		if _, err := regexp.CompilePOSIX(e.Rewrite); err != nil {
+			return err
+		}
+	}
+
 	if len(e.Handler) == 0 {
 		return errors.New("invalid handler")
 	}
@@ -16,6 +16,14 @@ func TestEncoding(t *testing.T) {
 			Method:      []string{"GET"},
 			Path:        []string{"/test"},
 		},
+		{
+			Name:        "Foo.Upload",
+			Handler:     "api",
+			Host:        []string{"foo.com"},
+			Method:      []string{"POST"},
+			Path:        []string{"/upload"},
+			MaxRecvSize: 1024,
+		},
 	}
 
 	compare := func(expect, got []string) bool {
@@ -109,6 +117,9 @@ func TestEncoding(t *testing.T) {
 		if ok := compare(d.Host, de.Host); !ok {
 			t.Fatalf("expected %v got %v", d.Host, de.Host)
 		}
+		if de.MaxRecvSize != d.MaxRecvSize {
+			t.Fatalf("expected %v got %v", d.MaxRecvSize, de.MaxRecvSize)
+		}
 	}
 }
 
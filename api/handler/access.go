@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	log "go-micro.org/v5/logger"
+)
+
+// AccessLog is the record of a request logged by the handlers.
+type AccessLog struct {
+	Method   string
+	Path     string
+	Service  string
+	Endpoint string
+	Status   int
+	Size     int
+	Latency  time.Duration
+	Header   http.Header
+	// Request and response bodies are only recorded in verbose mode
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// RedactFunc scrubs sensitive headers and bodies before logging.
+type RedactFunc func(*AccessLog)
+
+// RedactedHeaders are the credential headers whose values are
+// replaced in the access log before the redact func is called.
+var RedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"Micro-Token",
+}
+
+// redactHeaders replaces the values of the credential headers. The
+// header is cloned first as it may be shared with the request.
+func redactHeaders(l *AccessLog) {
+	if len(l.Header) == 0 {
+		return
+	}
+
+	h := l.Header.Clone()
+
+	for _, k := range RedactedHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			h.Set(k, "[REDACTED]")
+		}
+	}
+
+	l.Header = h
+}
+
+// Recorder is a http.ResponseWriter which records the
+// status and size of the response and optionally the body.
+type Recorder struct {
+	http.ResponseWriter
+
+	Status int
+	Size   int
+	// Body is recorded if not nil
+	Body *bytes.Buffer
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	if r.Status == 0 {
+		r.Status = code
+	}
+
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+
+	if r.Body != nil {
+		r.Body.Write(b)
+	}
+
+	n, err := r.ResponseWriter.Write(b)
+	r.Size += n
+
+	return n, err
+}
+
+func (r *Recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LogAccess redacts and writes the access log entry. The credential
+// headers are always redacted, the redact func scrubs anything else.
+func LogAccess(opts Options, l *AccessLog) {
+	if opts.AccessLogger == nil {
+		return
+	}
+
+	redactHeaders(l)
+
+	if opts.AccessLogRedact != nil {
+		opts.AccessLogRedact(l)
+	}
+
+	fields := map[string]interface{}{
+		"method":   l.Method,
+		"path":     l.Path,
+		"service":  l.Service,
+		"endpoint": l.Endpoint,
+		"status":   l.Status,
+		"size":     l.Size,
+		"latency":  l.Latency.String(),
+	}
+
+	if opts.AccessLogVerbose {
+		fields["header"] = l.Header
+		fields["request"] = string(l.RequestBody)
+		fields["response"] = string(l.ResponseBody)
+	}
+
+	opts.AccessLogger.Fields(fields).Log(log.InfoLevel, "access")
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "go-micro.org/v5/logger"
+)
+
+type testLogger struct {
+	fields map[string]interface{}
+	logged int
+}
+
+func (l *testLogger) Init(...log.Option) error { return nil }
+
+func (l *testLogger) Options() log.Options { return log.Options{} }
+
+func (l *testLogger) Fields(fields map[string]interface{}) log.Logger {
+	l.fields = fields
+	return l
+}
+
+func (l *testLogger) Log(log.Level, ...interface{}) { l.logged++ }
+
+func (l *testLogger) Logf(log.Level, string, ...interface{}) { l.logged++ }
+
+func (l *testLogger) String() string { return "test" }
+
+func testAccessLog() *AccessLog {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Cookie", "session=secret")
+	h.Set("X-Api-Key", "secret")
+	h.Set("Content-Type", "application/json")
+
+	return &AccessLog{
+		Method:       "POST",
+		Path:         "/foo/bar",
+		Service:      "foo",
+		Endpoint:     "Foo.Bar",
+		Status:       200,
+		Size:         2,
+		Latency:      time.Millisecond,
+		Header:       h,
+		RequestBody:  []byte(`{"password":"secret"}`),
+		ResponseBody: []byte(`{}`),
+	}
+}
+
+func TestLogAccess(t *testing.T) {
+	// disabled without a logger
+	LogAccess(NewOptions(), testAccessLog())
+
+	l := new(testLogger)
+	LogAccess(NewOptions(WithAccessLog(l, nil)), testAccessLog())
+
+	if l.logged != 1 {
+		t.Fatalf("expected 1 entry, got %d", l.logged)
+	}
+
+	expect := map[string]interface{}{
+		"method":   "POST",
+		"path":     "/foo/bar",
+		"service":  "foo",
+		"endpoint": "Foo.Bar",
+		"status":   200,
+		"size":     2,
+		"latency":  time.Millisecond.String(),
+	}
+
+	for k, v := range expect {
+		if l.fields[k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, l.fields[k])
+		}
+	}
+
+	for _, k := range []string{"header", "request", "response"} {
+		if _, ok := l.fields[k]; ok {
+			t.Errorf("unexpected %s field without verbose", k)
+		}
+	}
+}
+
+func TestLogAccessVerbose(t *testing.T) {
+	l := new(testLogger)
+	entry := testAccessLog()
+	header := entry.Header
+
+	LogAccess(NewOptions(WithAccessLog(l, nil), WithAccessLogVerbose(true)), entry)
+
+	h, ok := l.fields["header"].(http.Header)
+	if !ok {
+		t.Fatalf("expected header field, got %v", l.fields["header"])
+	}
+
+	for _, k := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+		if v := h.Get(k); v != "[REDACTED]" {
+			t.Errorf("expected %s to be redacted, got %q", k, v)
+		}
+	}
+
+	if v := h.Get("Content-Type"); v != "application/json" {
+		t.Errorf("expected content type to be logged, got %q", v)
+	}
+
+	// the original header is left as is
+	if v := header.Get("Authorization"); v != "Bearer secret" {
+		t.Errorf("expected the request header to be unchanged, got %q", v)
+	}
+
+	if v := l.fields["request"]; v != `{"password":"secret"}` {
+		t.Errorf("unexpected request %v", v)
+	}
+
+	if v := l.fields["response"]; v != `{}` {
+		t.Errorf("unexpected response %v", v)
+	}
+}
+
+func TestLogAccessRedact(t *testing.T) {
+	l := new(testLogger)
+
+	redact := func(a *AccessLog) {
+		a.RequestBody = []byte("[REDACTED]")
+		a.Header.Del("Content-Type")
+	}
+
+	LogAccess(NewOptions(WithAccessLog(l, redact), WithAccessLogVerbose(true)), testAccessLog())
+
+	if v := l.fields["request"]; v != "[REDACTED]" {
+		t.Errorf("expected the request to be redacted, got %v", v)
+	}
+
+	h := l.fields["header"].(http.Header)
+
+	if v := h.Get("Authorization"); v != "[REDACTED]" {
+		t.Errorf("expected authorization to be redacted, got %q", v)
+	}
+
+	if _, ok := h["Content-Type"]; ok {
+		t.Error("expected content type to be removed")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &Recorder{ResponseWriter: w, Body: new(bytes.Buffer)}
+
+	rec.Write([]byte("hello"))
+	rec.WriteHeader(http.StatusTeapot)
+
+	if rec.Status != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Status)
+	}
+
+	if rec.Size != 5 || rec.Body.String() != "hello" {
+		t.Errorf("unexpected size %d body %q", rec.Size, rec.Body.String())
+	}
+}
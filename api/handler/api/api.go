@@ -2,19 +2,38 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	errs "errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"go-micro.org/v5/api/handler"
 	api "go-micro.org/v5/api/proto"
 	"go-micro.org/v5/api/router"
 	"go-micro.org/v5/client"
+	"go-micro.org/v5/client/grpc"
 	"go-micro.org/v5/errors"
+	"go-micro.org/v5/metadata"
+	"go-micro.org/v5/registry"
 	"go-micro.org/v5/selector"
 	"go-micro.org/v5/util/ctx"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
 )
 
 type apiHandler struct {
 	opts handler.Options
+	// metrics of the requests, nil if disabled
+	metrics *metrics
+	// etags of the responses, nil if disabled
+	etags *etagCache
+	// unary rejects streaming endpoints, which its client can't call
+	unary bool
+
+	// group coalesces identical requests
+	group singleflight.Group
 }
 
 const (
@@ -24,92 +43,383 @@ const (
 
 // API handler is the default handler which takes api.Request and returns api.Response.
 func (a *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	bsize := handler.DefaultMaxRecvSize
-	if a.opts.MaxRecvSize > 0 {
-		bsize = a.opts.MaxRecvSize
+	if a.opts.AccessLogger == nil && a.metrics == nil {
+		a.serve(w, r, nil)
+		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, bsize)
+	entry := &record{
+		AccessLog: handler.AccessLog{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+		},
+	}
 
-	request, err := requestToProto(r)
-	if err != nil {
-		er := errors.InternalServerError("go.micro.api", err.Error())
+	rec := &handler.Recorder{ResponseWriter: w}
+	if a.opts.AccessLogVerbose {
+		rec.Body = new(bytes.Buffer)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(er.Error()))
+	start := time.Now()
 
-		return
+	a.serve(rec, r, entry)
+
+	entry.Status = rec.Status
+	entry.Size = rec.Size
+	entry.Latency = time.Since(start)
+
+	if rec.Body != nil {
+		entry.ResponseBody = rec.Body.Bytes()
 	}
 
+	a.metrics.observe(entry)
+	handler.LogAccess(a.opts, &entry.AccessLog)
+}
+
+// serve handles the request, filling in the record if not nil.
+func (a *apiHandler) serve(w http.ResponseWriter, r *http.Request, entry *record) {
 	var service *router.Route
 
+	// echo the request id, also on errors
+	id := requestID(r, a.opts.RequestIDHeader)
+	w.Header().Set(a.opts.RequestIDHeader, id)
+
+	// answer preflights before routing
+	if handler.CORS(a.opts, w, r) {
+		return
+	}
+
+	// reject before routing so the backends are spared
+	if handler.Throttled(a.opts, w, r) {
+		return
+	}
+
 	if a.opts.Router != nil {
 		// try get service from router
 		s, err := a.opts.Router.Route(r)
+		if err != nil && a.opts.DefaultRoute != nil {
+			// send unmatched requests to the default route
+			s, err = a.opts.DefaultRoute, nil
+		}
+
 		if err != nil {
-			er := errors.InternalServerError("go.micro.api", err.Error())
+			if entry != nil {
+				entry.failure = failureRouting
+			}
 
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(er.Error()))
+			a.writeError(w, r, errors.InternalServerError("go.micro.api", err.Error()))
 
 			return
 		}
 
 		service = s
+	} else if a.opts.DefaultRoute != nil {
+		service = a.opts.DefaultRoute
 	} else {
 		// we have no way of routing the request
-		er := errors.InternalServerError("go.micro.api", "no route found")
+		if entry != nil {
+			entry.failure = failureRouting
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(er.Error()))
+		a.writeError(w, r, errors.InternalServerError("go.micro.api", "no route found"))
 
 		return
 	}
 
-	// create request and response
+	if entry != nil {
+		entry.Service = service.Service
+		entry.Endpoint = service.Endpoint.Name
+	}
+
+	if a.unary && (isClientStream(service) || isServerStream(service)) {
+		a.writeError(w, r, errors.New("go.micro.api", fmt.Sprintf("streaming endpoint %s is not supported over grpc", service.Endpoint.Name), http.StatusNotImplemented))
+		return
+	}
+
+	bsize := handler.DefaultMaxRecvSize
+	if a.opts.MaxRecvSize > 0 {
+		bsize = a.opts.MaxRecvSize
+	}
+
+	// prefer the route limit
+	if service.Endpoint != nil && service.Endpoint.MaxRecvSize > 0 {
+		bsize = service.Endpoint.MaxRecvSize
+	} else if isClientStream(service) {
+		// uploads are streamed rather than buffered
+		// so only the route limits their size
+		bsize = 0
+	}
+
+	if bsize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, bsize)
+	}
+
+	handler.SetForwarded(r, a.opts.TrustedProxies)
+
 	c := a.opts.Client
-	req := c.NewRequest(service.Service, service.Endpoint.Name, request)
-	rsp := &api.Response{}
 
 	// create the context from headers
 	cx := ctx.FromRequest(r)
+	// propagate the trace to the backend
+	cx = traceContext(cx, r)
+	// and the request id
+	cx = metadata.Set(cx, a.opts.RequestIDHeader, id)
+	// and the values of the context funcs
+	cx = handler.CallContext(a.opts, cx, r)
 	// create strategy:
-	so := selector.WithStrategy(strategy(service.Versions))
+	st := handler.Strategy(service.Versions, a.opts)
+
+	// record the node picked for the route headers
+	var pn *pickedNode
+	if a.opts.RouteHeaders {
+		pn = new(pickedNode)
+		st = pn.wrap(st)
+	}
+
+	so := selector.WithStrategy(st)
+
+	defer a.metrics.begin(service.Service)()
+
+	// stream uploads rather than buffering the body
+	if isClientStream(service) {
+		if err := a.upload(cx, w, r, service, so, pn); err != nil {
+			if entry != nil {
+				entry.failure = callFailure(err)
+			}
+
+			setRouteHeaders(w, service, pn)
+			a.writeError(w, r, err)
+		}
+
+		return
+	}
+
+	request, err := requestToProto(r)
+	if err != nil {
+		var mbe *http.MaxBytesError
+
+		if errs.As(err, &mbe) {
+			a.writeError(w, r, errors.New("go.micro.api", fmt.Sprintf("request body exceeds limit of %d bytes", mbe.Limit), http.StatusRequestEntityTooLarge))
+		} else {
+			a.writeError(w, r, errors.InternalServerError("go.micro.api", err.Error()))
+		}
+
+		return
+	}
+
+	if err := transcode(r, service, request); err != nil {
+		a.writeError(w, r, errors.BadRequest("go.micro.api", err.Error()))
+		return
+	}
+
+	for _, fn := range a.opts.RequestTransforms {
+		if err := fn(r, request); err != nil {
+			a.writeError(w, r, errors.BadRequest("go.micro.api", err.Error()))
+			return
+		}
+	}
+
+	if entry != nil && a.opts.AccessLogVerbose {
+		entry.RequestBody = []byte(request.Body)
+	}
+
+	// write the responses of streaming endpoints as they arrive
+	if isServerStream(service) {
+		if err := a.stream(cx, w, r, service, request, so, pn); err != nil && entry != nil {
+			entry.failure = callFailure(err)
+		}
+
+		return
+	}
+
+	// create request and response
+	req := c.NewRequest(service.Service, service.Endpoint.Name, request)
+
+	call := func(cx context.Context) (*api.Response, error) {
+		rsp := &api.Response{}
+		err := c.Call(cx, req, rsp, client.WithSelectOption(so))
+
+		return rsp, err
+	}
 
-	if err := c.Call(cx, req, rsp, client.WithSelectOption(so)); err != nil {
-		w.Header().Set("Content-Type", "application/json")
+	var rsp *api.Response
 
-		ce := errors.Parse(err.Error())
-		switch ce.Code {
-		case 0:
-			w.WriteHeader(http.StatusInternalServerError)
-		default:
-			w.WriteHeader(int(ce.Code))
+	key, safe := coalesceKey(r, service, request, a.opts.CoalesceHeaders)
+
+	// answer revalidations of a cached etag without the backend
+	if safe && a.etags != nil {
+		if e := a.etags.get(key); e != nil && etagMatch(r.Header.Get("If-None-Match"), e.header.Get("Etag")) {
+			setRouteHeaders(w, service, pn)
+			e.notModified(w)
+
+			return
 		}
+	}
 
-		w.Write([]byte(ce.Error()))
+	if safe && a.opts.Coalesce {
+		rsp, err = a.coalesce(r.Context(), cx, key, call, pn)
+	} else {
+		rsp, err = call(cx)
+	}
+
+	if err != nil {
+		if entry != nil {
+			entry.failure = callFailure(err)
+		}
+
+		setRouteHeaders(w, service, pn)
+		a.writeError(w, r, err)
 
 		return
 	} else if rsp.StatusCode == 0 {
 		rsp.StatusCode = http.StatusOK
 	}
 
+	for _, fn := range a.opts.ResponseTransforms {
+		if err := fn(r, rsp); err != nil {
+			setRouteHeaders(w, service, pn)
+			a.writeError(w, r, errors.InternalServerError("go.micro.api", err.Error()))
+
+			return
+		}
+	}
+
+	var declared []string
+
+	// announced in the Trailer header and sent after the body
+	trailers := trailerNames(rsp)
+
 	for _, header := range rsp.GetHeader() {
+		// content type is negotiated below
+		if http.CanonicalHeaderKey(header.Key) == "Content-Type" {
+			declared = append(declared, header.Values...)
+			continue
+		}
+
+		if trailers[http.CanonicalHeaderKey(header.Key)] {
+			continue
+		}
+
+		// the gateway's policy replaces the backend's
+		if a.opts.CORS != nil && handler.IsCORSHeader(header.Key) {
+			continue
+		}
+
 		for _, val := range header.Values {
 			w.Header().Add(header.Key, val)
 		}
 	}
 
-	if len(w.Header().Get("Content-Type")) == 0 {
-		w.Header().Set("Content-Type", "application/json")
+	ct := contentType(r, declared, a.opts.ContentTypes, a.opts.DefaultContentType)
+	body := []byte(rsp.Body)
+
+	if len(declared) == 0 {
+		ct, body = encodeBody(ct, a.opts.DefaultContentType, body)
+	}
+
+	setContentType(w, ct)
+
+	// after the backend headers which take precedence
+	setRouteHeaders(w, service, pn)
+
+	if safe && a.etags != nil && statusCode(rsp.StatusCode) == http.StatusOK {
+		etag := setETag(w.Header(), string(body))
+		a.etags.put(key, w.Header())
+
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(statusCode(rsp.StatusCode))
+
+	w.Write(body)
+
+	setTrailers(w, rsp, trailers)
+}
+
+// coalesced is the result of a call shared by identical requests.
+type coalesced struct {
+	rsp  *api.Response
+	node *registry.Node
+}
+
+// detached keeps the values of a context without its deadline and
+// cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detached) Done() <-chan struct{} { return nil }
+
+func (detached) Err() error { return nil }
+
+// coalesce makes the call once for concurrent requests with the same key.
+// The call is detached from the context of the request making it, which
+// may go away while others wait, and times out after the client's request
+// timeout. Each request waits until its own context is done and gets its
+// own copy of the response as transforms modify it.
+func (a *apiHandler) coalesce(rctx, cx context.Context, key string, call func(context.Context) (*api.Response, error), pn *pickedNode) (*api.Response, error) {
+	ch := a.group.DoChan(key, func() (interface{}, error) {
+		var cancel context.CancelFunc
+
+		cx := context.Context(detached{cx})
+		if t := a.opts.Client.Options().CallOptions.RequestTimeout; t > 0 {
+			cx, cancel = context.WithTimeout(cx, t)
+		} else {
+			cx, cancel = context.WithCancel(cx)
+		}
+		defer cancel()
+
+		rsp, err := call(cx)
+
+		// the node the call was sent to, for the requests sharing it
+		res := &coalesced{rsp: rsp}
+		if pn != nil {
+			res.node = pn.get()
+		}
+
+		return res, err
+	})
+
+	var res *coalesced
+
+	select {
+	case <-rctx.Done():
+		return nil, rctx.Err()
+	case v := <-ch:
+		if v.Err != nil {
+			return nil, v.Err
+		}
+
+		res = v.Val.(*coalesced)
+	}
+
+	if pn != nil && res.node != nil {
+		pn.set(res.node)
+	}
+
+	return proto.Clone(res.rsp).(*api.Response), nil
+}
+
+// writeError writes the error of a call with its status code, or with
+// the ErrorEncoder if one is set.
+func (a *apiHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	ce := errors.Parse(err.Error())
+
+	if a.opts.ErrorEncoder != nil {
+		a.opts.ErrorEncoder(w, r, ce)
+		return
 	}
 
-	w.WriteHeader(int(rsp.StatusCode))
+	setContentType(w, a.opts.DefaultContentType)
+	w.WriteHeader(statusCode(ce.Code))
 
-	w.Write([]byte(rsp.Body))
+	w.Write([]byte(ce.Error()))
 }
 
 func (a *apiHandler) String() string {
@@ -121,6 +431,32 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	options := handler.NewOptions(opts...)
 
 	return &apiHandler{
-		opts: options,
+		opts:    options,
+		metrics: newMetrics(options.Metrics, options.Logger),
+		etags:   newETagCache(options.ETagTTL),
+	}
+}
+
+// NewGRPCHandler returns an api.Handler for backends which only serve
+// gRPC. The handler's client is replaced by a grpc client with the same
+// options, so its registry and selector still resolve the nodes picked
+// by the handler's strategy. The api.Request and api.Response are sent
+// as proto, calling e.g. the Foo.Bar endpoint of the greeter service as
+// the gRPC method /greeter.Foo/Bar. Only unary calls are supported, the
+// requests of client and server streaming endpoints fail with a 501.
+func NewGRPCHandler(opts ...handler.Option) handler.Handler {
+	options := handler.NewOptions(opts...)
+
+	copts := options.Client.Options()
+	options.Client = grpc.NewClient(func(o *client.Options) {
+		*o = copts
+		o.ContentType = grpc.DefaultContentType
+	})
+
+	return &apiHandler{
+		opts:    options,
+		metrics: newMetrics(options.Metrics, options.Logger),
+		etags:   newETagCache(options.ETagTTL),
+		unary:   true,
 	}
 }
@@ -0,0 +1,1308 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-micro.org/v5/api/handler"
+	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/codec"
+	raw "go-micro.org/v5/codec/bytes"
+	merrors "go-micro.org/v5/errors"
+	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/metadata"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+	"go-micro.org/v5/transport/headers"
+)
+
+type testRouter struct {
+	router.Router
+}
+
+func (r *testRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Call"},
+	}, nil
+}
+
+type testClient struct {
+	client.Client
+
+	ctx    context.Context
+	md     metadata.Metadata
+	req    client.Request
+	stream *testStream
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.ctx = ctx
+	c.md, _ = metadata.FromContext(ctx)
+	c.req = req
+
+	return nil
+}
+
+func (c *testClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	c.md, _ = metadata.FromContext(ctx)
+	c.stream = &testStream{}
+
+	return c.stream, nil
+}
+
+// testStream records the chunks sent and responds with their count.
+type testStream struct {
+	client.Stream
+
+	chunks [][]byte
+	closed bool
+}
+
+func (s *testStream) Send(v interface{}) error {
+	s.chunks = append(s.chunks, append([]byte(nil), v.(*raw.Frame).Data...))
+	return nil
+}
+
+func (s *testStream) CloseSend() error {
+	s.closed = true
+	return nil
+}
+
+func (s *testStream) Recv(v interface{}) error {
+	v.(*raw.Frame).Data = []byte(fmt.Sprintf("%d chunks", len(s.chunks)))
+	return nil
+}
+
+func (s *testStream) Close() error {
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	var (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+		parent  = "00-" + traceID + "-" + spanID + "-01"
+		c       = &testClient{Client: client.NewClient()}
+	)
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+	)
+
+	req := httptest.NewRequest("POST", "/test/call", nil)
+	req.Header.Set("traceparent", parent)
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	for k, want := range map[string]string{
+		"traceparent":      parent,
+		"tracestate":       "congo=t61rcWkgMzE",
+		headers.TraceIDKey: traceID,
+		headers.SpanID:     spanID,
+	} {
+		if have, _ := c.md.Get(k); have != want {
+			t.Errorf("Expected %s %q got %q", k, want, have)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	testData := []struct {
+		header string
+		id     string
+	}{
+		{header: "X-Request-Id"},
+		{header: "X-Request-Id", id: "abc"},
+		{header: "X-Correlation-Id", id: "def"},
+	}
+
+	for _, d := range testData {
+		c := &testClient{Client: client.NewClient()}
+
+		opts := []handler.Option{
+			handler.WithClient(c),
+			handler.WithRouter(&testRouter{}),
+		}
+
+		if d.header != handler.DefaultRequestIDHeader {
+			opts = append(opts, handler.WithRequestIDHeader(d.header))
+		}
+
+		h := NewHandler(opts...)
+
+		req := httptest.NewRequest("POST", "/test/call", nil)
+		if len(d.id) > 0 {
+			req.Header.Set(d.header, d.id)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		id := w.Header().Get(d.header)
+		if len(id) == 0 {
+			t.Fatalf("Expected %s response header", d.header)
+		}
+
+		if len(d.id) > 0 && id != d.id {
+			t.Fatalf("Expected request id %q got %q", d.id, id)
+		}
+
+		if have, _ := c.md.Get(d.header); have != id {
+			t.Fatalf("Expected %s metadata %q got %q", d.header, id, have)
+		}
+	}
+}
+
+type uploadRouter struct {
+	router.Router
+
+	max int64
+}
+
+func (r *uploadRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Upload", MaxRecvSize: r.max},
+		Versions: []*registry.Service{{
+			Name: "go.micro.srv.test",
+			Endpoints: []*registry.Endpoint{{
+				Name:     "Test.Upload",
+				Metadata: map[string]string{"stream": "client"},
+			}},
+		}},
+	}, nil
+}
+
+func TestUpload(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&uploadRouter{}),
+	)
+
+	body := bytes.Repeat([]byte("a"), DefaultChunkSize*2+1)
+
+	req := httptest.NewRequest("POST", "/test/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=foo")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	if c.stream == nil || !c.stream.closed {
+		t.Fatal("Expected the upload to be streamed")
+	}
+
+	if got := bytes.Join(c.stream.chunks, nil); !bytes.Equal(got, body) {
+		t.Fatalf("Expected %d bytes streamed got %d", len(body), len(got))
+	}
+
+	if len(c.stream.chunks) < 3 {
+		t.Fatalf("Expected the body in chunks got %d", len(c.stream.chunks))
+	}
+
+	if w.Body.String() != fmt.Sprintf("%d chunks", len(c.stream.chunks)) {
+		t.Fatalf("Unexpected response %q", w.Body.String())
+	}
+
+	if ct, _ := c.md.Get("Content-Type"); ct != "multipart/form-data; boundary=foo" {
+		t.Fatalf("Expected the content type in metadata got %q", ct)
+	}
+
+	// the handler limit doesn't apply to uploads
+	h = NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&uploadRouter{}),
+		handler.WithMaxRecvSize(int64(len(body)-1)),
+	)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/upload", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	// but a body over the route limit is rejected
+	h = NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&uploadRouter{max: int64(len(body) - 1)}),
+	)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/upload", bytes.NewReader(body)))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+// routeClient selects a node and responds with the backend headers.
+type routeClient struct {
+	client.Client
+
+	header map[string]*api.Pair
+}
+
+func (c *routeClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	var options client.CallOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var so selector.SelectOptions
+	for _, o := range options.SelectOptions {
+		o(&so)
+	}
+
+	if _, err := so.Strategy(nil)(); err != nil {
+		return err
+	}
+
+	rsp.(*api.Response).Header = c.header
+
+	return nil
+}
+
+type versionRouter struct {
+	router.Router
+}
+
+func (r *versionRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Call"},
+		Versions: []*registry.Service{{
+			Name:    "go.micro.srv.test",
+			Version: "v2",
+			Nodes:   []*registry.Node{{Id: "test-1"}},
+		}},
+	}, nil
+}
+
+func TestRouteHeaders(t *testing.T) {
+	testData := []struct {
+		enabled bool
+		header  map[string]*api.Pair
+		want    map[string]string
+	}{
+		{
+			want: map[string]string{
+				"X-Micro-Service":  "",
+				"X-Micro-Endpoint": "",
+				"X-Micro-Version":  "",
+			},
+		},
+		{
+			enabled: true,
+			want: map[string]string{
+				"X-Micro-Service":  "go.micro.srv.test",
+				"X-Micro-Endpoint": "Test.Call",
+				"X-Micro-Version":  "v2",
+			},
+		},
+		{
+			enabled: true,
+			header: map[string]*api.Pair{
+				"X-Micro-Version": {Key: "X-Micro-Version", Values: []string{"backend"}},
+			},
+			want: map[string]string{
+				"X-Micro-Service": "go.micro.srv.test",
+				"X-Micro-Version": "backend",
+			},
+		},
+	}
+
+	for _, d := range testData {
+		h := NewHandler(
+			handler.WithClient(&routeClient{Client: client.NewClient(), header: d.header}),
+			handler.WithRouter(&versionRouter{}),
+			handler.WithRouteHeaders(d.enabled),
+		)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+		}
+
+		for k, want := range d.want {
+			if have := w.Header().Get(k); have != want {
+				t.Errorf("Expected %s %q got %q", k, want, have)
+			}
+		}
+	}
+}
+
+type errRouter struct {
+	router.Router
+}
+
+func (r *errRouter) Route(req *http.Request) (*router.Route, error) {
+	return nil, errors.New("not found")
+}
+
+func TestDefaultRoute(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	// no default route is a 500
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&errRouter{}),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/unknown", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	h = NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&errRouter{}),
+		handler.WithDefaultRoute("go.micro.srv.legacy", "Legacy.Call"),
+	)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/unknown", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	if c.req == nil || c.req.Service() != "go.micro.srv.legacy" || c.req.Endpoint() != "Legacy.Call" {
+		t.Fatalf("Expected the default route to be called got %v", c.req)
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		handler.WithRequestTransform(func(r *http.Request, req *api.Request) error {
+			if r.Header.Get("X-Tenant") == "" {
+				return errors.New("missing tenant")
+			}
+
+			req.Body = `{"tenant":"` + r.Header.Get("X-Tenant") + `"}`
+
+			return nil
+		}),
+		handler.WithResponseTransform(func(r *http.Request, rsp *api.Response) error {
+			// the backend may respond without a body
+			data := json.RawMessage(rsp.Body)
+			if len(data) == 0 {
+				data = json.RawMessage(`null`)
+			}
+
+			b, err := json.Marshal(map[string]json.RawMessage{"data": data})
+			if err != nil {
+				return err
+			}
+
+			rsp.Body = string(b)
+
+			return nil
+		}),
+	)
+
+	req := httptest.NewRequest("POST", "/test/call", strings.NewReader(`{}`))
+	req.Header.Set("X-Tenant", "acme")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	if body := c.req.Body().(*api.Request).Body; body != `{"tenant":"acme"}` {
+		t.Fatalf("Expected transformed request got %s", body)
+	}
+
+	if !json.Valid(w.Body.Bytes()) || w.Body.String() != `{"data":null}` {
+		t.Fatalf("Expected transformed response got %s", w.Body.String())
+	}
+
+	// a failed request transform is a bad request
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// errClient fails calls with the code.
+type errClient struct {
+	client.Client
+
+	code int32
+}
+
+func (c *errClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return merrors.New("go.micro.srv.test", "failed", c.code)
+}
+
+func TestErrorStatus(t *testing.T) {
+	testData := []struct {
+		code int32
+		want int
+	}{
+		{0, http.StatusInternalServerError},
+		{-1, http.StatusInternalServerError},
+		{99, http.StatusInternalServerError},
+		{404, http.StatusNotFound},
+		{599, 599},
+		{600, http.StatusInternalServerError},
+		{1000, http.StatusInternalServerError},
+	}
+
+	for _, d := range testData {
+		h := NewHandler(
+			handler.WithClient(&errClient{Client: client.NewClient(), code: d.code}),
+			handler.WithRouter(&testRouter{}),
+		)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+		if w.Code != d.want {
+			t.Fatalf("code %d: expected status %d got %d", d.code, d.want, w.Code)
+		}
+	}
+
+	for code, want := range map[int32]int{99: 500, 100: 100, 599: 599, 600: 500} {
+		if got := statusCode(code); got != want {
+			t.Fatalf("code %d: expected %d got %d", code, want, got)
+		}
+	}
+}
+
+// slowClient counts calls, blocking them until released.
+type slowClient struct {
+	client.Client
+
+	mu      sync.Mutex
+	calls   int
+	release chan struct{}
+	// err is the error of the call's context once released
+	err error
+}
+
+func (c *slowClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	<-c.release
+
+	c.mu.Lock()
+	c.err = ctx.Err()
+	c.mu.Unlock()
+
+	rsp.(*api.Response).Body = `{"ok":true}`
+
+	return nil
+}
+
+func (c *slowClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls
+}
+
+func TestCoalesce(t *testing.T) {
+	testData := []struct {
+		method string
+		header string
+		auth   []string
+		opts   []handler.Option
+		calls  int
+	}{
+		{"GET", "Authorization", []string{"a", "a", "a", "a"}, nil, 1},
+		{"GET", "Authorization", []string{"a", "b", "a", "b"}, nil, 2},
+		{"POST", "Authorization", []string{"a", "a", "a", "a"}, nil, 4},
+		{"GET", "X-Tenant", []string{"a", "b", "a", "b"}, nil, 1},
+		{"GET", "X-Tenant", []string{"a", "b", "a", "b"}, []handler.Option{handler.WithCoalesceHeaders("X-Tenant")}, 2},
+	}
+
+	for _, d := range testData {
+		c := &slowClient{Client: client.NewClient(), release: make(chan struct{})}
+
+		h := NewHandler(append(d.opts,
+			handler.WithClient(c),
+			handler.WithRouter(&testRouter{}),
+			handler.WithCoalesce(true),
+		)...)
+
+		var wg sync.WaitGroup
+
+		bodies := make([]string, len(d.auth))
+
+		for i, auth := range d.auth {
+			wg.Add(1)
+
+			go func(i int, auth string) {
+				defer wg.Done()
+
+				r := httptest.NewRequest(d.method, "/test/call?a=1", nil)
+				r.Header.Set(d.header, auth)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+
+				bodies[i] = w.Body.String()
+			}(i, auth)
+		}
+
+		// let the requests reach the backend
+		time.Sleep(100 * time.Millisecond)
+		close(c.release)
+		wg.Wait()
+
+		if n := c.count(); n != d.calls {
+			t.Fatalf("%s %s %v: expected %d calls got %d", d.method, d.header, d.auth, d.calls, n)
+		}
+
+		for _, b := range bodies {
+			if b != `{"ok":true}` {
+				t.Fatalf("%s %s %v: unexpected body %q", d.method, d.header, d.auth, b)
+			}
+		}
+	}
+}
+
+func TestCoalesceDetached(t *testing.T) {
+	c := &slowClient{Client: client.NewClient(), release: make(chan struct{})}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		handler.WithCoalesce(true),
+	)
+
+	// the first request makes the call and goes away
+	cx, cancel := context.WithCancel(context.Background())
+
+	first := make(chan int)
+
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/test/call", nil).WithContext(cx))
+		first <- w.Code
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan string)
+
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/test/call", nil))
+		second <- w.Body.String()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if code := <-first; code == http.StatusOK {
+		t.Fatal("Expected the cancelled request to fail")
+	}
+
+	close(c.release)
+
+	if b := <-second; b != `{"ok":true}` {
+		t.Fatalf("Expected the waiting request to get the response got %q", b)
+	}
+
+	if n := c.count(); n != 1 {
+		t.Fatalf("Expected 1 call got %d", n)
+	}
+
+	if c.err != nil {
+		t.Fatalf("Expected the shared call to outlive the first request got %v", c.err)
+	}
+}
+
+func TestDefaultContentType(t *testing.T) {
+	testData := []struct {
+		opts []handler.Option
+		want string
+	}{
+		{nil, "application/json"},
+		{[]handler.Option{handler.WithDefaultContentType("application/octet-stream")}, "application/octet-stream"},
+	}
+
+	for _, d := range testData {
+		for _, c := range []client.Client{
+			&testClient{Client: client.NewClient()},
+			&errClient{Client: client.NewClient(), code: 400},
+		} {
+			h := NewHandler(append(d.opts, handler.WithClient(c), handler.WithRouter(&testRouter{}))...)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+			if ct := w.Header().Get("Content-Type"); ct != d.want {
+				t.Fatalf("Expected content type %s got %s", d.want, ct)
+			}
+		}
+	}
+
+	// an empty default leaves the content type unset
+	h := NewHandler(
+		handler.WithClient(&testClient{Client: client.NewClient()}),
+		handler.WithRouter(&testRouter{}),
+		handler.WithDefaultContentType(""),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct == "application/json" {
+		t.Fatalf("Expected no forced content type got %s", ct)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	testData := []struct {
+		client client.Client
+		router router.Router
+	}{
+		{&testClient{Client: client.NewClient()}, &testRouter{}},
+		{&testClient{Client: client.NewClient()}, &testRouter{}},
+		{&errClient{Client: client.NewClient(), code: 500}, &testRouter{}},
+		{&errClient{Client: client.NewClient(), code: 408}, &testRouter{}},
+		{&testClient{Client: client.NewClient()}, &errRouter{}},
+	}
+
+	// the handlers share the metrics
+	for _, d := range testData {
+		h := NewHandler(
+			handler.WithClient(d.client),
+			handler.WithRouter(d.router),
+			handler.WithMetrics(reg),
+		)
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test/call", nil))
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the values of the metrics by name and labels
+	values := make(map[string]float64)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+
+			key := mf.GetName() + "{" + strings.Join(labels, ",") + "}"
+
+			switch {
+			case m.GetCounter() != nil:
+				values[key] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[key] = m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				values[key] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	want := map[string]float64{
+		"micro_api_requests_total{endpoint=Test.Call,service=go.micro.srv.test,status=200}":           2,
+		"micro_api_requests_total{endpoint=Test.Call,service=go.micro.srv.test,status=500}":           1,
+		"micro_api_requests_total{endpoint=Test.Call,service=go.micro.srv.test,status=408}":           1,
+		"micro_api_requests_total{endpoint=unmatched,service=unmatched,status=500}":                   1,
+		"micro_api_request_duration_seconds{endpoint=Test.Call,service=go.micro.srv.test,status=200}": 2,
+		"micro_api_requests_in_flight{service=go.micro.srv.test}":                                     0,
+		"micro_api_request_errors_total{endpoint=Test.Call,reason=backend,service=go.micro.srv.test}": 1,
+		"micro_api_request_errors_total{endpoint=Test.Call,reason=timeout,service=go.micro.srv.test}": 1,
+		"micro_api_request_errors_total{endpoint=unmatched,reason=routing,service=unmatched}":         1,
+	}
+
+	for k, v := range want {
+		got, ok := values[k]
+		if !ok {
+			t.Fatalf("Expected metric %s in %v", k, values)
+		}
+
+		if got != v {
+			t.Fatalf("Expected %s to be %v got %v", k, v, got)
+		}
+	}
+}
+
+type claimsKey struct{}
+
+func TestContextFunc(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		// copy the claims a middleware set
+		handler.WithContextFunc(func(cx context.Context, r *http.Request) context.Context {
+			return context.WithValue(cx, claimsKey{}, r.Context().Value(claimsKey{}))
+		}),
+		handler.WithContextFunc(func(cx context.Context, r *http.Request) context.Context {
+			return metadata.Set(cx, "Subject", cx.Value(claimsKey{}).(string))
+		}),
+	)
+
+	req := httptest.NewRequest("POST", "/test/call", nil)
+	req = req.WithContext(context.WithValue(req.Context(), claimsKey{}, "alice"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d got %d", http.StatusOK, w.Code)
+	}
+
+	if v, _ := c.ctx.Value(claimsKey{}).(string); v != "alice" {
+		t.Fatalf("Expected the claims in the call context got %q", v)
+	}
+
+	// the funcs run in order on the context with the headers
+	if v, _ := c.md.Get("Subject"); v != "alice" {
+		t.Fatalf("Expected the subject metadata got %q", v)
+	}
+}
+
+// headerClient returns a response with the headers, counting calls.
+type headerClient struct {
+	client.Client
+
+	header map[string]*api.Pair
+	calls  int
+}
+
+func (c *headerClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.calls++
+
+	rsp.(*api.Response).Header = c.header
+	rsp.(*api.Response).Body = `{"ok":true}`
+
+	return nil
+}
+
+func TestETagCache(t *testing.T) {
+	testData := []struct {
+		header map[string]*api.Pair
+		etag   string
+		// backend calls of the revalidation
+		calls int
+	}{
+		// the etag of the body
+		{nil, "", 1},
+		// the backend's etag
+		{map[string]*api.Pair{"etag": {Key: "ETag", Values: []string{`W/"v1"`}}}, `W/"v1"`, 1},
+		// revalidated by the backend
+		{map[string]*api.Pair{
+			"etag":          {Key: "ETag", Values: []string{`"v1"`}},
+			"cache-control": {Key: "Cache-Control", Values: []string{"no-cache"}},
+		}, `"v1"`, 2},
+	}
+
+	for _, d := range testData {
+		c := &headerClient{Client: client.NewClient(), header: d.header}
+
+		h := NewHandler(
+			handler.WithClient(c),
+			handler.WithRouter(&testRouter{}),
+			handler.WithETagCache(time.Minute),
+		)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/test/call", nil))
+
+		etag := w.Header().Get("Etag")
+		if w.Code != http.StatusOK || len(etag) == 0 {
+			t.Fatalf("Expected status 200 with an etag got %d %q", w.Code, etag)
+		}
+
+		if len(d.etag) > 0 && etag != d.etag {
+			t.Fatalf("Expected etag %q got %q", d.etag, etag)
+		}
+
+		req := httptest.NewRequest("GET", "/test/call", nil)
+		req.Header.Set("If-None-Match", `"other", `+etag)
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() > 0 {
+			t.Fatalf("Expected status 304 without a body got %d %q", w.Code, w.Body.String())
+		}
+
+		if w.Header().Get("Etag") != etag {
+			t.Fatalf("Expected etag %q on the 304 got %q", etag, w.Header().Get("Etag"))
+		}
+
+		if c.calls != d.calls {
+			t.Fatalf("Expected %d calls got %d", d.calls, c.calls)
+		}
+
+		// a stale etag gets the response
+		req.Header.Set("If-None-Match", `"stale"`)
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || c.calls != d.calls+1 {
+			t.Fatalf("Expected status 200 from the backend got %d after %d calls", w.Code, c.calls)
+		}
+	}
+}
+
+type streamRouter struct {
+	router.Router
+}
+
+func (r *streamRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Query"},
+		Versions: []*registry.Service{{
+			Name: "go.micro.srv.test",
+			Endpoints: []*registry.Endpoint{{
+				Name:     "Test.Query",
+				Metadata: map[string]string{"stream": "server"},
+			}},
+		}},
+	}, nil
+}
+
+// responseClient streams the bodies then the error.
+type responseClient struct {
+	client.Client
+
+	bodies []string
+	err    error
+	req    *api.Request
+}
+
+func (c *responseClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return &responseStream{c: c}, nil
+}
+
+type responseStream struct {
+	client.Stream
+
+	c *responseClient
+	n int
+}
+
+func (s *responseStream) Send(v interface{}) error {
+	s.c.req = v.(*api.Request)
+	return nil
+}
+
+func (s *responseStream) CloseSend() error {
+	return nil
+}
+
+func (s *responseStream) Recv(v interface{}) error {
+	if s.n == len(s.c.bodies) {
+		if s.c.err != nil {
+			return s.c.err
+		}
+
+		return io.EOF
+	}
+
+	v.(*api.Response).Body = s.c.bodies[s.n]
+	s.n++
+
+	return nil
+}
+
+func (s *responseStream) Close() error {
+	return nil
+}
+
+func TestServerStream(t *testing.T) {
+	testData := []struct {
+		accept string
+		bodies []string
+		err    error
+		status int
+		want   string
+	}{
+		{"", []string{`{"a": 1}`, "{\n\"b\": 2\n}"}, nil, 200, "{\"a\":1}\n{\"b\":2}\n"},
+		{"text/event-stream", []string{`{"a":1}`, "{\n\"b\":2}"}, nil, 200, "data: {\"a\":1}\n\ndata: {\ndata: \"b\":2}\n\n"},
+		// errors after the first response are written in the stream
+		{"", []string{`{"a":1}`}, merrors.NotFound("test", "gone"), 200,
+			"{\"a\":1}\n{\"error\":{\"id\":\"test\",\"code\":404,\"detail\":\"gone\",\"status\":\"Not Found\"}}\n"},
+		{"text/event-stream", []string{`{"a":1}`}, merrors.NotFound("test", "gone"), 200,
+			"data: {\"a\":1}\n\nevent: error\ndata: {\"id\":\"test\",\"code\":404,\"detail\":\"gone\",\"status\":\"Not Found\"}\n\n"},
+		// and before it with the status
+		{"", nil, merrors.NotFound("test", "gone"), 404, `{"id":"test","code":404,"detail":"gone","status":"Not Found"}`},
+	}
+
+	for i, d := range testData {
+		c := &responseClient{Client: client.NewClient(), bodies: d.bodies, err: d.err}
+
+		h := NewHandler(
+			handler.WithClient(c),
+			handler.WithRouter(&streamRouter{}),
+		)
+
+		req := httptest.NewRequest("POST", "/test/query", strings.NewReader(`{"q":"x"}`))
+		if len(d.accept) > 0 {
+			req.Header.Set("Accept", d.accept)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != d.status {
+			t.Fatalf("%d: expected status %d got %d", i, d.status, w.Code)
+		}
+
+		if w.Body.String() != d.want {
+			t.Fatalf("%d: expected body %q got %q", i, d.want, w.Body.String())
+		}
+
+		if c.req == nil || c.req.Body != `{"q":"x"}` {
+			t.Fatalf("%d: expected the request to be sent got %v", i, c.req)
+		}
+
+		if d.status == 200 && !w.Flushed {
+			t.Fatalf("%d: expected the responses to be flushed", i)
+		}
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	c := &headerClient{Client: client.NewClient(), header: map[string]*api.Pair{
+		"trailer":     {Key: "Trailer", Values: []string{"grpc-status, Grpc-Message"}},
+		"grpc-status": {Key: "Grpc-Status", Values: []string{"0"}},
+		"x-foo":       {Key: "X-Foo", Values: []string{"bar"}},
+	}}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/call", nil))
+
+	rsp := w.Result()
+
+	if rsp.Header.Get("Grpc-Status") != "" || rsp.Header.Get("X-Foo") != "bar" {
+		t.Fatalf("Expected the trailers to be sent after the body got %v", rsp.Header)
+	}
+
+	if got := rsp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("Expected the Grpc-Status trailer got %q", got)
+	}
+
+	// the stream trailers are set from the last response
+	sc := &responseClient{Client: client.NewClient(), bodies: []string{`{"a":1}`, `{"a":2}`}}
+
+	h = NewHandler(
+		handler.WithClient(&trailerClient{responseClient: sc}),
+		handler.WithRouter(&streamRouter{}),
+	)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test/query", nil))
+
+	rsp = w.Result()
+
+	if got := rsp.Trailer.Get("Grpc-Status"); got != "2" {
+		t.Fatalf("Expected the Grpc-Status trailer of the last response got %q", got)
+	}
+}
+
+// trailerClient sets the status of each response as a trailer.
+type trailerClient struct {
+	*responseClient
+}
+
+func (c *trailerClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return &trailerStream{responseStream: &responseStream{c: c.responseClient}}, nil
+}
+
+type trailerStream struct {
+	*responseStream
+}
+
+func (s *trailerStream) Recv(v interface{}) error {
+	if err := s.responseStream.Recv(v); err != nil {
+		return err
+	}
+
+	v.(*api.Response).Header = map[string]*api.Pair{
+		"trailer":     {Key: "Trailer", Values: []string{"Grpc-Status"}},
+		"grpc-status": {Key: "Grpc-Status", Values: []string{fmt.Sprint(s.n)}},
+	}
+
+	return nil
+}
+
+func TestErrorEncoder(t *testing.T) {
+	encoder := handler.WithErrorEncoder(func(w http.ResponseWriter, r *http.Request, err error) {
+		ce, ok := err.(*merrors.Error)
+		if !ok {
+			t.Fatalf("Expected a parsed error got %T", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(ce.Code))
+
+		fmt.Fprintf(w, `{"error":%s,"requestId":%q}`, ce.Error(), w.Header().Get(handler.DefaultRequestIDHeader))
+	})
+
+	testData := []struct {
+		name string
+		opts []handler.Option
+		code int
+		id   string
+	}{
+		{"call", []handler.Option{handler.WithClient(&errClient{Client: client.NewClient(), code: 404}), handler.WithRouter(&testRouter{})}, 404, "go.micro.srv.test"},
+		{"route", []handler.Option{handler.WithRouter(&errRouter{})}, 500, "go.micro.api"},
+	}
+
+	for _, d := range testData {
+		h := NewHandler(append(d.opts, encoder)...)
+
+		req := httptest.NewRequest("POST", "/test/call", nil)
+		req.Header.Set(handler.DefaultRequestIDHeader, "abc")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != d.code {
+			t.Fatalf("%s: expected status %d got %d", d.name, d.code, w.Code)
+		}
+
+		var rsp struct {
+			Error     merrors.Error `json:"error"`
+			RequestID string        `json:"requestId"`
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatalf("%s: %v %s", d.name, err, w.Body.String())
+		}
+
+		if rsp.Error.Id != d.id || rsp.Error.Code != int32(d.code) || rsp.RequestID != "abc" {
+			t.Fatalf("%s: unexpected envelope %s", d.name, w.Body.String())
+		}
+	}
+}
+
+type transcodeRouter struct {
+	router.Router
+
+	body string
+}
+
+func (r *transcodeRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Update"},
+		Versions: []*registry.Service{{
+			Name: "go.micro.srv.test",
+			Endpoints: []*registry.Endpoint{{
+				Name:     "Test.Update",
+				Metadata: map[string]string{"transcode": "true", "body": r.body},
+				Request: &registry.Value{
+					Name: "UpdateRequest",
+					Type: "UpdateRequest",
+					Values: []*registry.Value{
+						{Name: "bookId", Type: "int64"},
+						{Name: "force", Type: "bool"},
+						{Name: "tags", Type: "[]string"},
+						{Name: "book", Type: "Book", Values: []*registry.Value{
+							{Name: "title", Type: "string"},
+						}},
+						{Name: "options", Type: "Options", Values: []*registry.Value{
+							{Name: "limit", Type: "int32"},
+						}},
+					},
+				},
+			}},
+		}},
+	}, nil
+}
+
+func TestTranscode(t *testing.T) {
+	testData := []struct {
+		body string
+		url  string
+		data string
+		want string
+	}{
+		// the body is a field, query params set the others
+		{
+			"book",
+			"/v1/books/42?force=true&tags=a&tags=b&options.limit=10",
+			`{"title":"Go"}`,
+			`{"book":{"title":"Go"},"bookId":42,"force":true,"options":{"limit":10},"tags":["a","b"]}`,
+		},
+		// the body is the message, the query is ignored
+		{
+			"*",
+			"/v1/books/42?force=true",
+			`{"book":{"title":"Go"},"bookId":1}`,
+			`{"book":{"title":"Go"},"bookId":42}`,
+		},
+		// no body, query params bound by the path are ignored
+		{
+			"",
+			"/v1/books/42?bookId=7&book.title=Go&force=maybe",
+			`{"ignored":true}`,
+			`{"book":{"title":"Go"},"bookId":42,"force":"maybe"}`,
+		},
+	}
+
+	for _, d := range testData {
+		c := &testClient{Client: client.NewClient()}
+
+		h := NewHandler(
+			handler.WithClient(c),
+			handler.WithRouter(&transcodeRouter{body: d.body}),
+		)
+
+		req := httptest.NewRequest("POST", d.url, strings.NewReader(d.data))
+		req.Header.Set("Content-Type", "application/json")
+		// set by the router matching /v1/books/{bookid}
+		req = req.WithContext(metadata.NewContext(req.Context(), metadata.Metadata{"x-api-field-bookid": "42"}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d %s", d.url, http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if body := c.req.Body().(*api.Request).Body; body != d.want {
+			t.Fatalf("%s: expected body %s got %s", d.url, d.want, body)
+		}
+	}
+
+	// an invalid body is a bad request
+	h := NewHandler(
+		handler.WithClient(&testClient{Client: client.NewClient()}),
+		handler.WithRouter(&transcodeRouter{body: "*"}),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/v1/books", strings.NewReader(`[1]`)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// textMarshaler encodes values with fmt.
+type textMarshaler struct{}
+
+func (textMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(v)), nil
+}
+
+func (textMarshaler) Unmarshal(b []byte, v interface{}) error {
+	return nil
+}
+
+func (textMarshaler) String() string {
+	return "text"
+}
+
+func TestRegisteredMarshaler(t *testing.T) {
+	codec.RegisterMarshaler("application/x-api-test", textMarshaler{})
+
+	testData := []struct {
+		opts   []handler.Option
+		accept string
+		ct     string
+		body   string
+	}{
+		{nil, "application/x-api-test", "application/x-api-test", "map[ok:true]"},
+		{nil, "", "application/json", `{"ok":true}`},
+		// proto can't encode the json body
+		{nil, "application/protobuf", "application/json", `{"ok":true}`},
+		{[]handler.Option{handler.WithContentTypes("application/json", "application/x-api-test")}, "application/x-api-test;q=0.9, application/json", "application/json", `{"ok":true}`},
+		// unregistered types aren't offered
+		{[]handler.Option{handler.WithContentTypes("application/x-unknown")}, "application/x-unknown", "application/json", `{"ok":true}`},
+	}
+
+	for _, d := range testData {
+		h := NewHandler(append(d.opts,
+			handler.WithClient(&headerClient{Client: client.NewClient()}),
+			handler.WithRouter(&testRouter{}),
+		)...)
+
+		r := httptest.NewRequest("POST", "/test/call", nil)
+		r.Header.Set("Accept", d.accept)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if ct := w.Header().Get("Content-Type"); ct != d.ct || w.Body.String() != d.body {
+			t.Fatalf("Accept %q: expected %s %s got %s %s", d.accept, d.ct, d.body, ct, w.Body.String())
+		}
+	}
+}
+
+func TestMetricsConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// a collector of the same name with other labels
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "micro",
+		Subsystem: "api",
+		Name:      "requests_total",
+		Help:      "Requests handled by the api handler.",
+	}, []string{"service"}))
+
+	// the metrics are disabled rather than unregistered
+	if m := newMetrics(reg, log.NewLogger()); m != nil {
+		t.Fatal("Expected the conflicting metrics to be disabled")
+	}
+}
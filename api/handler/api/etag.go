@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// etagCacheSize bounds the number of cached etags.
+	etagCacheSize = 4096
+
+	// headers sent with a 304 as they'd be with the full response.
+	validatorHeaders = []string{
+		"Etag",
+		"Cache-Control",
+		"Content-Location",
+		"Expires",
+		"Vary",
+	}
+)
+
+// etagEntry is the etag of a route's last response.
+type etagEntry struct {
+	header  http.Header
+	expires time.Time
+}
+
+// etagCache keeps the etags of GET and HEAD responses so revalidations
+// are answered with a 304 without calling the backend.
+type etagCache struct {
+	ttl time.Duration
+
+	sync.Mutex
+	// entries by coalesce key
+	entries map[string]*etagEntry
+}
+
+// get returns the unexpired entry of the key.
+func (c *etagCache) get(key string) *etagEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	return e
+}
+
+// put caches the etag of the response headers. Responses the backend
+// marked no-cache or no-store are always sent to the backend.
+func (c *etagCache) put(key string, header http.Header) {
+	cc := strings.ToLower(strings.Join(header.Values("Cache-Control"), ","))
+	if strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store") {
+		return
+	}
+
+	e := &etagEntry{
+		header:  make(http.Header),
+		expires: time.Now().Add(c.ttl),
+	}
+
+	for _, k := range validatorHeaders {
+		if v := header.Values(k); len(v) > 0 {
+			e.header[k] = append([]string(nil), v...)
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.entries) >= etagCacheSize {
+		c.sweep()
+	}
+
+	c.entries[key] = e
+}
+
+// sweep drops the expired entries, or any entry if there are none, to
+// make room for a new one.
+func (c *etagCache) sweep() {
+	now := time.Now()
+
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	for k := range c.entries {
+		if len(c.entries) < etagCacheSize {
+			return
+		}
+
+		delete(c.entries, k)
+	}
+}
+
+// notModified writes a 304 with the cached validator headers.
+func (e *etagEntry) notModified(w http.ResponseWriter) {
+	for k, v := range e.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// setETag sets the etag of the response to a hash of the body
+// unless the backend supplied one, returning it.
+func setETag(header http.Header, body string) string {
+	if etag := header.Get("Etag"); len(etag) > 0 {
+		return etag
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	header.Set("Etag", etag)
+
+	return etag
+}
+
+// etagMatch reports whether the If-None-Match header matches the etag,
+// using the weak comparison of RFC 9110.
+func etagMatch(inm, etag string) bool {
+	if len(inm) == 0 || len(etag) == 0 {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, v := range strings.Split(inm, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newETagCache returns the cache, nil if disabled.
+func newETagCache(ttl time.Duration) *etagCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &etagCache{
+		ttl:     ttl,
+		entries: make(map[string]*etagEntry),
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-micro.org/v5/api/handler"
+	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcRouter routes requests to the greeter service at the address.
+type grpcRouter struct {
+	router.Router
+
+	service *registry.Service
+}
+
+func (r *grpcRouter) Route(req *http.Request) (*router.Route, error) {
+	name := "Say.Hello"
+	if req.URL.Path == "/upload" {
+		name = "Say.Upload"
+	}
+
+	return &router.Route{
+		Service:  r.service.Name,
+		Endpoint: &router.Endpoint{Name: name},
+		Versions: []*registry.Service{r.service},
+	}, nil
+}
+
+// serveGRPC answers api requests with the grpc method and path called.
+func serveGRPC(_ interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+
+	var req api.Request
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	if req.Path == "/missing" {
+		return status.Error(codes.NotFound, "no such greeting")
+	}
+
+	return stream.SendMsg(&api.Response{
+		StatusCode: http.StatusOK,
+		Body:       method + " " + req.Method + " " + req.Path,
+	})
+}
+
+func TestGRPCHandler(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(serveGRPC))
+	defer srv.Stop()
+
+	go srv.Serve(l)
+
+	service := &registry.Service{
+		Name:    "greeter",
+		Version: "latest",
+		Nodes:   []*registry.Node{{Id: "greeter-1", Address: l.Addr().String()}},
+		Endpoints: []*registry.Endpoint{
+			{Name: "Say.Upload", Metadata: map[string]string{"stream": "client"}},
+		},
+	}
+
+	reg := registry.NewMemoryRegistry()
+	if err := reg.Register(service); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewGRPCHandler(
+		handler.WithClient(client.NewClient(
+			client.Registry(reg),
+			client.Selector(selector.NewSelector(selector.Registry(reg))),
+		)),
+		handler.WithRouter(&grpcRouter{service: service}),
+	)
+
+	testData := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/hello", http.StatusOK, "/greeter.Say/Hello GET /hello"},
+		{"/missing", http.StatusNotFound, "no such greeting"},
+		// streams can't be called
+		{"/upload", http.StatusNotImplemented, "streaming endpoint Say.Upload is not supported"},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", d.path, nil))
+
+		if w.Code != d.code {
+			t.Fatalf("%s: expected status %d got %d: %s", d.path, d.code, w.Code, w.Body.String())
+		}
+
+		if !strings.Contains(w.Body.String(), d.body) {
+			t.Fatalf("%s: expected body %q got %q", d.path, d.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	errs "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-micro.org/v5/api/handler"
+	"go-micro.org/v5/errors"
+	log "go-micro.org/v5/logger"
+	pmetrics "go-micro.org/v5/util/metrics"
+)
+
+// The kinds of failure counted by the errors metric.
+const (
+	failureRouting = "routing"
+	failureBackend = "backend"
+	failureTimeout = "timeout"
+)
+
+// unmatched labels requests the router couldn't match.
+const unmatched = "unmatched"
+
+// record is the access log entry of a request
+// and the failure counted by the metrics.
+type record struct {
+	handler.AccessLog
+
+	failure string
+}
+
+// metrics of the requests labeled by the route rather
+// than the path to bound the label cardinality.
+type metrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	errors   *prometheus.CounterVec
+}
+
+// newMetrics registers the metrics with the registerer, returning
+// nil if it is. Metrics already registered by another handler are
+// shared. If others conflict with them the error is logged and the
+// metrics are disabled.
+func newMetrics(reg prometheus.Registerer, logger log.Logger) *metrics {
+	if reg == nil {
+		return nil
+	}
+
+	labels := []string{"service", "endpoint", "status"}
+
+	var err error
+
+	register := func(c prometheus.Collector) prometheus.Collector {
+		if err != nil {
+			return c
+		}
+
+		var rc prometheus.Collector
+		if rc, err = pmetrics.RegisterCollector(reg, c); err != nil {
+			return c
+		}
+
+		return rc
+	}
+
+	m := &metrics{
+		requests: register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "requests_total",
+			Help:      "Requests handled by the api handler.",
+		}, labels)).(*prometheus.CounterVec),
+		latency: register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests handled by the api handler.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels)).(*prometheus.HistogramVec),
+		inFlight: register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "requests_in_flight",
+			Help:      "Requests the api handler is sending to the backend service.",
+		}, []string{"service"})).(*prometheus.GaugeVec),
+		errors: register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "request_errors_total",
+			Help:      "Requests which failed to route, failed in the backend or timed out.",
+		}, []string{"service", "endpoint", "reason"})).(*prometheus.CounterVec),
+	}
+
+	if err != nil {
+		logger.Logf(log.ErrorLevel, "api handler failed to register metrics: %v", err)
+		return nil
+	}
+
+	return m
+}
+
+// begin tracks the request in flight to the service,
+// the returned func is called when it completes.
+func (m *metrics) begin(service string) func() {
+	if m == nil {
+		return func() {}
+	}
+
+	g := m.inFlight.WithLabelValues(service)
+	g.Inc()
+
+	return g.Dec
+}
+
+// observe records the completed request.
+func (m *metrics) observe(r *record) {
+	if m == nil {
+		return
+	}
+
+	service, endpoint := r.Service, r.Endpoint
+	if len(service) == 0 {
+		service, endpoint = unmatched, unmatched
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	code := strconv.Itoa(status)
+
+	m.requests.WithLabelValues(service, endpoint, code).Inc()
+	m.latency.WithLabelValues(service, endpoint, code).Observe(r.Latency.Seconds())
+
+	if len(r.failure) > 0 {
+		m.errors.WithLabelValues(service, endpoint, r.failure).Inc()
+	}
+}
+
+// callFailure returns the kind of failure of a backend call.
+func callFailure(err error) string {
+	if errs.Is(err, context.DeadlineExceeded) {
+		return failureTimeout
+	}
+
+	switch errors.FromError(err).Code {
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return failureTimeout
+	}
+
+	return failureBackend
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/selector"
+)
+
+// isServerStream reports whether the endpoint streams its responses,
+// marked by setting the "stream" endpoint metadata to "server".
+func isServerStream(srv *router.Route) bool {
+	for _, service := range srv.Versions {
+		for _, ep := range service.Endpoints {
+			if ep.Name == srv.Endpoint.Name && ep.Metadata["stream"] == "server" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isEventStream reports whether the client asked for server-sent events.
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamWriter writes the messages of a stream as newline delimited
+// JSON or server-sent events, flushing each.
+type streamWriter struct {
+	w   http.ResponseWriter
+	sse bool
+}
+
+func (s *streamWriter) write(event string, data []byte) {
+	var buf bytes.Buffer
+
+	if s.sse {
+		if len(event) > 0 {
+			buf.WriteString("event: " + event + "\n")
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	} else {
+		// a message per line
+		if err := json.Compact(&buf, data); err != nil {
+			buf.Reset()
+
+			b, _ := json.Marshal(string(data))
+			buf.Write(b)
+		}
+	}
+
+	buf.WriteString("\n")
+
+	s.w.Write(buf.Bytes())
+
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeError writes the error as an error event, or a line with the
+// error in the "error" field.
+func (s *streamWriter) writeError(err error) {
+	ce := errors.Parse(err.Error())
+
+	if s.sse {
+		s.write("error", []byte(ce.Error()))
+		return
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"error": ce})
+	s.write("", b)
+}
+
+// stream sends the request to a server streaming endpoint and writes the
+// body of each response as it's received, as server-sent events if the
+// client accepts them and newline delimited JSON otherwise. Errors before
+// the first response are written with their status, later ones in the
+// stream. The stream is closed when the client goes away.
+//
+// Trailers declared by the Trailer header of the first response are set
+// from the headers of the last.
+func (a *apiHandler) stream(cx context.Context, w http.ResponseWriter, r *http.Request, service *router.Route, request *api.Request, so selector.SelectOption, pn *pickedNode) error {
+	c := a.opts.Client
+
+	req := c.NewRequest(
+		service.Service,
+		service.Endpoint.Name,
+		request,
+		client.StreamingRequest(),
+	)
+
+	// the call context isn't derived from the request's
+	cCtx, cancel := context.WithCancel(cx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-r.Context().Done():
+			cancel()
+		case <-cCtx.Done():
+		}
+	}()
+
+	fail := func(err error) error {
+		setRouteHeaders(w, service, pn)
+		a.writeError(w, r, err)
+
+		return err
+	}
+
+	stream, err := c.Stream(cCtx, req, client.WithSelectOption(so))
+	if err != nil {
+		return fail(err)
+	}
+	defer stream.Close()
+
+	if err := stream.Send(request); err != nil {
+		return fail(err)
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return fail(err)
+	}
+
+	recv := func() (*api.Response, error) {
+		rsp := &api.Response{}
+		if err := stream.Recv(rsp); err != nil {
+			return nil, err
+		}
+
+		for _, fn := range a.opts.ResponseTransforms {
+			if err := fn(r, rsp); err != nil {
+				return nil, errors.InternalServerError("go.micro.api", err.Error())
+			}
+		}
+
+		return rsp, nil
+	}
+
+	// the status is sent with the first response
+	rsp, err := recv()
+	if err != nil && err != io.EOF {
+		return fail(err)
+	}
+
+	sw := &streamWriter{w: w, sse: isEventStream(r)}
+
+	if sw.sse {
+		setContentType(w, "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		setContentType(w, "application/x-ndjson")
+	}
+
+	trailers := trailerNames(rsp)
+	for name := range trailers {
+		w.Header().Add("Trailer", name)
+	}
+
+	setRouteHeaders(w, service, pn)
+	w.WriteHeader(http.StatusOK)
+
+	for rsp != nil {
+		sw.write("", []byte(rsp.Body))
+
+		last := rsp
+
+		rsp, err = recv()
+		if err == io.EOF {
+			setTrailers(w, last, trailers)
+			break
+		} else if err != nil {
+			// a client which went away isn't written to
+			if r.Context().Err() == nil {
+				sw.writeError(err)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/metadata"
+	"go-micro.org/v5/registry"
+)
+
+// transcodeEndpoint returns the endpoint of the route if it's transcoded,
+// marked by setting the "transcode" endpoint metadata to "true".
+func transcodeEndpoint(srv *router.Route) *registry.Endpoint {
+	for _, service := range srv.Versions {
+		for _, ep := range service.Endpoints {
+			if ep.Name == srv.Endpoint.Name && ep.Metadata["transcode"] == "true" {
+				return ep
+			}
+		}
+	}
+
+	return nil
+}
+
+// transcode maps the request onto the fields of the request message as
+// google.api.http does, replacing the body with the JSON message. The
+// variables of the route's path template set the fields they name. The
+// "body" endpoint metadata is the field the body is set to, "*" for the
+// whole message, and the body is ignored if it's empty. The query params
+// set the fields not bound by the path or body, dots separating those of
+// nested messages, unless the body is the whole message. Values are
+// converted to the types of the endpoint's request, repeated fields take
+// each value of a param.
+func transcode(r *http.Request, srv *router.Route, req *api.Request) error {
+	ep := transcodeEndpoint(srv)
+	if ep == nil {
+		return nil
+	}
+
+	msg := make(map[string]interface{})
+	body := ep.Metadata["body"]
+
+	if b := strings.TrimSpace(req.Body); len(b) > 0 && len(body) > 0 {
+		var v interface{}
+		if err := json.Unmarshal([]byte(b), &v); err != nil {
+			return fmt.Errorf("invalid request body: %w", err)
+		}
+
+		if body == "*" {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("request body is not an object")
+			}
+
+			msg = m
+		} else {
+			setField(msg, resolveField(ep.Request, body), v)
+		}
+	}
+
+	// bound fields aren't set by the query
+	bound := make(map[string]bool)
+	if len(body) > 0 {
+		bound[resolveField(ep.Request, body)] = true
+	}
+
+	// the router stores the path variables in the metadata
+	md, _ := metadata.FromContext(r.Context())
+
+	for k, v := range md {
+		if !strings.HasPrefix(strings.ToLower(k), "x-api-field-") {
+			continue
+		}
+
+		// the metadata keys are title cased
+		field := resolveField(ep.Request, strings.ToLower(k[len("x-api-field-"):]))
+		bound[field] = true
+
+		setField(msg, field, fieldValue(fieldType(ep.Request, field), []string{v}))
+	}
+
+	// a field is bound with its nested fields
+	isBound := func(field string) bool {
+		for f := range bound {
+			if field == f || strings.HasPrefix(field, f+".") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if body != "*" {
+		for k, vals := range r.URL.Query() {
+			field := resolveField(ep.Request, k)
+			if isBound(field) {
+				continue
+			}
+
+			setField(msg, field, fieldValue(fieldType(ep.Request, field), vals))
+		}
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req.Body = string(b)
+	req.Header["Content-Type"] = &api.Pair{
+		Key:    "Content-Type",
+		Values: []string{"application/json"},
+	}
+
+	return nil
+}
+
+// resolveField returns the dot separated field path with the names of the
+// request's fields, which are matched case insensitively, and the names
+// of unknown fields as is.
+func resolveField(v *registry.Value, path string) string {
+	parts := strings.Split(path, ".")
+
+	for i, p := range parts {
+		if v == nil {
+			break
+		}
+
+		var next *registry.Value
+
+		for _, f := range v.Values {
+			if strings.EqualFold(f.Name, p) {
+				next = f
+				break
+			}
+		}
+
+		if next != nil {
+			parts[i] = next.Name
+		}
+
+		v = next
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// fieldType returns the type of the request field, empty if unknown.
+func fieldType(v *registry.Value, path string) string {
+	for _, p := range strings.Split(path, ".") {
+		if v == nil {
+			return ""
+		}
+
+		var next *registry.Value
+
+		for _, f := range v.Values {
+			if f.Name == p {
+				next = f
+				break
+			}
+		}
+
+		v = next
+	}
+
+	if v == nil {
+		return ""
+	}
+
+	return v.Type
+}
+
+// fieldValue converts the values to the field type, a list if it's
+// repeated or there are several. Values which don't parse as the type
+// are left as strings for the backend to reject.
+func fieldValue(typ string, vals []string) interface{} {
+	elem := strings.TrimPrefix(typ, "[]")
+
+	conv := func(s string) interface{} {
+		switch elem {
+		case "bool":
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+			// json.Number rejects e.g. NaN or hex floats
+			if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) && s == strings.TrimSpace(s) {
+				return json.Number(s)
+			}
+		}
+
+		return s
+	}
+
+	if len(vals) == 1 && elem == typ {
+		return conv(vals[0])
+	}
+
+	list := make([]interface{}, 0, len(vals))
+	for _, s := range vals {
+		list = append(list, conv(s))
+	}
+
+	return list
+}
+
+// setField sets the dot separated field of the message,
+// creating the nested messages on the way.
+func setField(msg map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+
+	for _, p := range parts[:len(parts)-1] {
+		m, ok := msg[p].(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+			msg[p] = m
+		}
+
+		msg = m
+	}
+
+	msg[parts[len(parts)-1]] = v
+}
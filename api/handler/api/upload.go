@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	errs "errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	raw "go-micro.org/v5/codec/bytes"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/selector"
+)
+
+var (
+	// DefaultChunkSize is the size of the chunks an upload is streamed in.
+	DefaultChunkSize = 64 * 1024
+)
+
+// isClientStream reports whether the endpoint accepts a client stream,
+// marked by setting the "stream" endpoint metadata to "client".
+func isClientStream(srv *router.Route) bool {
+	for _, service := range srv.Versions {
+		for _, ep := range service.Endpoints {
+			if ep.Name == srv.Endpoint.Name && ep.Metadata["stream"] == "client" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// upload streams the request body to the endpoint in chunks of raw bytes
+// and writes the single response. The request headers are passed in the
+// call metadata so the backend can decode e.g. a multipart body. Only the
+// MaxRecvSize of the route limits the body, not that of the handler.
+func (a *apiHandler) upload(cx context.Context, w http.ResponseWriter, r *http.Request, service *router.Route, so selector.SelectOption, pn *pickedNode) error {
+	c := a.opts.Client
+
+	req := c.NewRequest(
+		service.Service,
+		service.Endpoint.Name,
+		&raw.Frame{},
+		client.WithContentType("application/octet-stream"),
+		client.StreamingRequest(),
+	)
+
+	cCtx, cancel := context.WithCancel(cx)
+	defer cancel()
+
+	stream, err := c.Stream(cCtx, req, client.WithSelectOption(so))
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	buf := make([]byte, DefaultChunkSize)
+
+	for {
+		n, err := r.Body.Read(buf)
+		if n > 0 {
+			if serr := stream.Send(&raw.Frame{Data: buf[:n]}); serr != nil {
+				return serr
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		var mbe *http.MaxBytesError
+		if errs.As(err, &mbe) {
+			return errors.New("go.micro.api", fmt.Sprintf("request body exceeds limit of %d bytes", mbe.Limit), http.StatusRequestEntityTooLarge)
+		} else if err != nil {
+			return errors.InternalServerError("go.micro.api", err.Error())
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	rsp := &raw.Frame{}
+	if err := stream.Recv(rsp); err != nil {
+		return err
+	}
+
+	ct, body := encodeBody(contentType(r, nil, a.opts.ContentTypes, a.opts.DefaultContentType), a.opts.DefaultContentType, rsp.Data)
+
+	setContentType(w, ct)
+	setRouteHeaders(w, service, pn)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+
+	return nil
+}
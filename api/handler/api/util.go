@@ -1,21 +1,44 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"mime"
-	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/google/uuid"
 	"github.com/oxtoacart/bpool"
 	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/codec"
+	"go-micro.org/v5/metadata"
 	"go-micro.org/v5/registry"
 	"go-micro.org/v5/selector"
+	"go-micro.org/v5/transport/headers"
 )
 
 var (
 	// need to calculate later to specify useful defaults.
 	bufferPool = bpool.NewSizedBufferPool(1024, 8)
+
+	// w3c and b3 tracing headers propagated to the backend.
+	traceHeaders = []string{
+		"traceparent",
+		"tracestate",
+		"b3",
+		"x-b3-traceid",
+		"x-b3-spanid",
+		"x-b3-parentspanid",
+		"x-b3-sampled",
+		"x-b3-flags",
+	}
 )
 
 func requestToProto(r *http.Request) (*api.Request, error) {
@@ -55,19 +78,6 @@ func requestToProto(r *http.Request) (*api.Request, error) {
 		}
 	}
 
-	// Set X-Forwarded-For if it does not exist
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		if prior, ok := r.Header["X-Forwarded-For"]; ok {
-			ip = strings.Join(prior, ", ") + ", " + ip
-		}
-
-		// Set the header
-		req.Header["X-Forwarded-For"] = &api.Pair{
-			Key:    "X-Forwarded-For",
-			Values: []string{ip},
-		}
-	}
-
 	// Host is stripped from net/http Headers so let's add it
 	req.Header["Host"] = &api.Pair{
 		Key:    "Host",
@@ -115,10 +125,338 @@ func requestToProto(r *http.Request) (*api.Request, error) {
 	return req, nil
 }
 
-// strategy is a hack for selection.
-func strategy(services []*registry.Service) selector.Strategy {
-	return func(_ []*registry.Service) selector.Next {
-		// ignore input to this function, use services above
-		return selector.Random(services)
+// statusCode returns the code as an http status. Codes which aren't
+// valid statuses, such as zero or internal error codes, are a 500.
+func statusCode(code int32) int {
+	if code < 100 || code > 599 {
+		return http.StatusInternalServerError
+	}
+
+	return int(code)
+}
+
+// contentType negotiates the response content type. The types declared by
+// the backend are preferred. The body of a backend which declares none is
+// json, so the configured json types are offered then, along with those
+// it's transcoded to by a registered marshaler, or the default type and
+// all registered types if none are configured. If nothing matches the
+// Accept header the first declared type is used since the body is already
+// encoded, falling back to the default type.
+func contentType(r *http.Request, declared, configured []string, fallback string) string {
+	offers := declared
+	if len(offers) == 0 {
+		if len(configured) == 0 && len(fallback) > 0 {
+			// the default type is always offered
+			offers = append(offers, fallback)
+			configured = codec.ContentTypes()
+		}
+
+		// the body isn't relabeled as another type
+		for _, ct := range configured {
+			if _, ok := marshaler(ct); ok || isJSON(ct) {
+				offers = append(offers, ct)
+			}
+		}
+	}
+
+	if ct := negotiate(r.Header.Get("Accept"), offers); len(ct) > 0 {
+		return ct
+	}
+
+	if len(declared) > 0 {
+		return declared[0]
+	}
+
+	return fallback
+}
+
+// isJSON reports whether the media type is json, e.g. application/json
+// or application/problem+json.
+func isJSON(ct string) bool {
+	typ, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	return typ == "application/json" || strings.HasSuffix(typ, "+json")
+}
+
+// marshaler returns the marshaler registered for the media type.
+func marshaler(ct string) (codec.Marshaler, bool) {
+	if m, ok := codec.GetMarshaler(ct); ok {
+		return m, ok
+	}
+
+	typ, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, false
+	}
+
+	return codec.GetMarshaler(typ)
+}
+
+// encodeBody transcodes the json body of a response whose type the backend
+// didn't declare to the negotiated type with its registered marshaler. The
+// fallback type and the body as is are returned if it can't be transcoded,
+// e.g. by a proto marshaler.
+func encodeBody(ct, fallback string, body []byte) (string, []byte) {
+	if len(body) == 0 || isJSON(ct) || ct == fallback {
+		return ct, body
+	}
+
+	m, ok := marshaler(ct)
+	if !ok {
+		return fallback, body
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fallback, body
+	}
+
+	b, err := m.Marshal(v)
+	if err != nil {
+		return fallback, body
+	}
+
+	return ct, b
+}
+
+// setContentType sets the content type unless it's empty.
+func setContentType(w http.ResponseWriter, ct string) {
+	if len(ct) > 0 {
+		w.Header().Set("Content-Type", ct)
+	}
+}
+
+type acceptRange struct {
+	typ string
+	q   float64
+}
+
+// negotiate returns the first offer acceptable to the Accept header
+// honouring quality values. Returns an empty string if none match.
+func negotiate(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if len(strings.TrimSpace(accept)) == 0 {
+		return offers[0]
+	}
+
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, acceptRange{typ: typ, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, ar := range ranges {
+		for _, offer := range offers {
+			typ, _, err := mime.ParseMediaType(offer)
+			if err != nil {
+				continue
+			}
+
+			switch {
+			case ar.typ == "*/*", ar.typ == typ:
+				return offer
+			case strings.HasSuffix(ar.typ, "/*") && strings.HasPrefix(typ, strings.TrimSuffix(ar.typ, "*")):
+				return offer
+			}
+		}
+	}
+
+	return ""
+}
+
+// traceContext copies the tracing headers of the request into the call
+// metadata so a backend's tracing middleware can continue the span.
+func traceContext(cx context.Context, r *http.Request) context.Context {
+	// metadata set by http wrappers e.g tracing middleware
+	md, ok := metadata.FromContext(r.Context())
+	if ok {
+		cx = metadata.MergeContext(cx, md, false)
+	}
+
+	md = make(metadata.Metadata)
+
+	for _, h := range traceHeaders {
+		if v := r.Header.Get(h); len(v) > 0 {
+			md[h] = v
+		}
+	}
+
+	// continue the w3c trace as a micro trace
+	// traceparent is version-traceid-parentid-flags
+	if parts := strings.Split(md["traceparent"], "-"); len(parts) == 4 {
+		md[headers.TraceIDKey] = parts[1]
+		md[headers.SpanID] = parts[2]
+	}
+
+	return metadata.MergeContext(cx, md, true)
+}
+
+// requestID returns the id of the request, generating one and
+// setting the header if the request doesn't carry one.
+func requestID(r *http.Request, header string) string {
+	id := r.Header.Get(header)
+	if len(id) == 0 {
+		id = uuid.New().String()
+		r.Header.Set(header, id)
+	}
+
+	return id
+}
+
+// coalesceKey returns the key identical requests share, varied by the
+// headers, false if the request may not be coalesced as its method isn't
+// safe.
+func coalesceKey(r *http.Request, route *router.Route, req *api.Request, headers []string) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+
+	h := sha256.New()
+
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	write(route.Service)
+	write(r.Method)
+	write(r.Host)
+	write(r.URL.RequestURI())
+	write(req.Body)
+
+	for _, k := range headers {
+		write(strings.Join(r.Header.Values(k), ","))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// pickedNode records the last node selected by a strategy.
+type pickedNode struct {
+	sync.Mutex
+	node *registry.Node
+}
+
+func (p *pickedNode) wrap(st selector.Strategy) selector.Strategy {
+	return func(services []*registry.Service) selector.Next {
+		next := st(services)
+
+		return func() (*registry.Node, error) {
+			node, err := next()
+			if err == nil {
+				p.set(node)
+			}
+
+			return node, err
+		}
+	}
+}
+
+func (p *pickedNode) set(node *registry.Node) {
+	p.Lock()
+	p.node = node
+	p.Unlock()
+}
+
+func (p *pickedNode) get() *registry.Node {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.node
+}
+
+// setRouteHeaders sets the service, endpoint and version of the route
+// unless already set by the backend. Nothing is set for a nil node.
+func setRouteHeaders(w http.ResponseWriter, route *router.Route, pn *pickedNode) {
+	if pn == nil {
+		return
+	}
+
+	set := func(k, v string) {
+		if len(v) > 0 && len(w.Header().Values(k)) == 0 {
+			w.Header().Set(k, v)
+		}
+	}
+
+	set("X-Micro-Service", route.Service)
+
+	if route.Endpoint != nil {
+		set("X-Micro-Endpoint", route.Endpoint.Name)
+	}
+
+	node := pn.get()
+	if node == nil {
+		return
+	}
+
+	for _, service := range route.Versions {
+		for _, n := range service.Nodes {
+			if n.Id == node.Id {
+				set("X-Micro-Version", service.Version)
+				return
+			}
+		}
+	}
+}
+
+// trailerNames returns the canonical names of the headers the response
+// declares in its Trailer header.
+func trailerNames(rsp *api.Response) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, header := range rsp.GetHeader() {
+		if http.CanonicalHeaderKey(header.Key) != "Trailer" {
+			continue
+		}
+
+		for _, v := range header.Values {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); len(name) > 0 {
+					names[http.CanonicalHeaderKey(name)] = true
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// setTrailers sets the declared trailers of the response once the body
+// is written, the Trailer header having announced them.
+func setTrailers(w http.ResponseWriter, rsp *api.Response, names map[string]bool) {
+	for _, header := range rsp.GetHeader() {
+		if !names[http.CanonicalHeaderKey(header.Key)] {
+			continue
+		}
+
+		for _, val := range header.Values {
+			w.Header().Add(header.Key, val)
+		}
 	}
 }
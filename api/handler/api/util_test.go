@@ -44,3 +44,49 @@ func TestRequestToProto(t *testing.T) {
 		}
 	}
 }
+
+func TestNegotiate(t *testing.T) {
+	testData := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", []string{"text/html"}, "text/html"},
+		{"application/json", nil, ""},
+		{"text/html", []string{"application/json", "text/html"}, "text/html"},
+		{"text/*", []string{"application/json", "text/plain; charset=utf-8"}, "text/plain; charset=utf-8"},
+		{"application/json;q=0.5, text/html", []string{"application/json", "text/html"}, "text/html"},
+		{"application/json;q=0", []string{"application/json"}, ""},
+		{"*/*", []string{"application/x-www-form-urlencoded"}, "application/x-www-form-urlencoded"},
+		{"text/html", []string{"application/json"}, ""},
+	}
+
+	for _, d := range testData {
+		if have := negotiate(d.accept, d.offers); have != d.want {
+			t.Fatalf("Accept %q offers %v: expected %q got %q", d.accept, d.offers, d.want, have)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	testData := []struct {
+		accept     string
+		declared   []string
+		configured []string
+		want       string
+	}{
+		{"text/html", []string{"text/html"}, nil, "text/html"},
+		{"application/hal+json", nil, []string{"application/json", "application/hal+json"}, "application/hal+json"},
+		// a json body isn't relabeled
+		{"text/html", nil, []string{"text/html", "application/json"}, "application/json"},
+		{"application/x-www-form-urlencoded", nil, []string{"application/x-www-form-urlencoded"}, "application/json"},
+	}
+
+	for _, d := range testData {
+		r := &http.Request{Header: http.Header{"Accept": []string{d.accept}}}
+
+		if have := contentType(r, d.declared, d.configured, "application/json"); have != d.want {
+			t.Fatalf("Accept %q declared %v configured %v: expected %q got %q", d.accept, d.declared, d.configured, d.want, have)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// ContextFunc adds values to the context of the backend call, e.g. the
+// claims a middleware parsed into the request context. The call context
+// is created from the request headers, not the request context.
+type ContextFunc func(context.Context, *http.Request) context.Context
+
+// CallContext applies the context funcs to the call context in the order
+// they were added.
+func CallContext(opts Options, cx context.Context, r *http.Request) context.Context {
+	for _, fn := range opts.ContextFuncs {
+		cx = fn(cx, r)
+	}
+
+	return cx
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultCORSMethods are allowed if the config sets none.
+	DefaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+	// DefaultCORSHeaders are allowed if the config sets none.
+	DefaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Type", "Authorization", "X-Requested-With"}
+)
+
+// CORSConfig is the cross-origin policy of the handlers.
+type CORSConfig struct {
+	// AllowedOrigins are exact origins, e.g. https://example.com,
+	// wildcards of their subdomains, e.g. https://*.example.com,
+	// or * for any origin
+	AllowedOrigins []string
+	// AllowedMethods default to DefaultCORSMethods
+	AllowedMethods []string
+	// AllowedHeaders default to DefaultCORSHeaders,
+	// * allows any header
+	AllowedHeaders []string
+	// ExposedHeaders the browser may read from responses
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization,
+	// except for origins allowed by *
+	AllowCredentials bool
+	// MaxAge is how long preflights are cached, the
+	// browser's default if zero
+	MaxAge time.Duration
+}
+
+// ErrCORSCredentials is returned by Validate if the * origin is allowed
+// with credentials, which would let any site make credentialed requests.
+var ErrCORSCredentials = errors.New("the * origin can't be allowed with credentials")
+
+// Validate returns an error if the config is unsafe.
+func (c *CORSConfig) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return ErrCORSCredentials
+		}
+	}
+
+	return nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin of the origin,
+// empty if it's not allowed.
+func (c *CORSConfig) allowOrigin(origin string) string {
+	origin = strings.ToLower(origin)
+
+	for _, o := range c.AllowedOrigins {
+		o = strings.ToLower(o)
+
+		switch {
+		case o == "*":
+			return "*"
+		case o == origin:
+			return origin
+		case strings.Contains(o, "://*."):
+			i := strings.Index(o, "*")
+			if strings.HasPrefix(origin, o[:i]) && strings.HasSuffix(origin, o[i+1:]) && len(origin) > len(o)-1 {
+				return origin
+			}
+		}
+	}
+
+	return ""
+}
+
+func (c *CORSConfig) methods() []string {
+	if len(c.AllowedMethods) == 0 {
+		return DefaultCORSMethods
+	}
+
+	return c.AllowedMethods
+}
+
+// allowHeaders reports whether the requested headers are allowed.
+func (c *CORSConfig) allowHeaders(requested string) bool {
+	allowed := c.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = DefaultCORSHeaders
+	}
+
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if len(h) == 0 {
+			continue
+		}
+
+		ok := false
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(a, h) {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsCORSHeader reports whether the header is set by the CORS policy, the
+// handlers drop those of the backend's responses when a policy is set.
+func IsCORSHeader(k string) bool {
+	return strings.HasPrefix(http.CanonicalHeaderKey(k), "Access-Control-")
+}
+
+// CORS sets the Access-Control-Allow-* headers of requests from allowed
+// origins and answers preflight requests with a 204 before they're
+// routed, returning true if it did. Preflights of origins, methods or
+// headers which aren't allowed are answered without the headers so the
+// browser blocks the request.
+func CORS(opts Options, w http.ResponseWriter, r *http.Request) bool {
+	c := opts.CORS
+	if c == nil {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	preflight := r.Method == http.MethodOptions && len(method) > 0
+
+	if preflight {
+		w.Header().Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	} else {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	allow := ""
+	if len(origin) > 0 {
+		allow = c.allowOrigin(origin)
+	}
+
+	if !preflight {
+		if len(allow) > 0 {
+			w.Header().Set("Access-Control-Allow-Origin", allow)
+
+			// browsers reject credentials with a wildcard
+			if c.AllowCredentials && allow != "*" {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if len(c.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+			}
+		}
+
+		return false
+	}
+
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if len(allow) == 0 {
+		return true
+	}
+
+	allowed := false
+	for _, m := range c.methods() {
+		if strings.EqualFold(m, method) {
+			allowed = true
+			break
+		}
+	}
+
+	headers := r.Header.Get("Access-Control-Request-Headers")
+
+	if !allowed || !c.allowHeaders(headers) {
+		return true
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allow)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.methods(), ", "))
+
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+
+	if c.AllowCredentials && allow != "*" {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+
+	return true
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	opts := NewOptions(WithCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowedHeaders:   []string{"Content-Type", "X-Api-Key"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+
+	testData := []struct {
+		method  string
+		origin  string
+		headers map[string]string
+		// whether the request is answered
+		preflight bool
+		allow     string
+	}{
+		{"GET", "https://example.com", nil, false, "https://example.com"},
+		{"GET", "https://api.example.org", nil, false, "https://api.example.org"},
+		{"GET", "https://example.org", nil, false, ""},
+		{"GET", "https://evil.com", nil, false, ""},
+		{"GET", "", nil, false, ""},
+		// plain OPTIONS requests are routed
+		{"OPTIONS", "https://example.com", nil, false, "https://example.com"},
+		{"OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "content-type, x-api-key",
+		}, true, "https://example.com"},
+		{"OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "X-Other",
+		}, true, ""},
+		{"OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method": "CONNECT",
+		}, true, ""},
+		{"OPTIONS", "https://evil.com", map[string]string{
+			"Access-Control-Request-Method": "GET",
+		}, true, ""},
+	}
+
+	for i, d := range testData {
+		r := httptest.NewRequest(d.method, "/foo", nil)
+		if len(d.origin) > 0 {
+			r.Header.Set("Origin", d.origin)
+		}
+
+		for k, v := range d.headers {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		if CORS(opts, w, r) != d.preflight {
+			t.Fatalf("%d: expected preflight %t", i, d.preflight)
+		}
+
+		if d.preflight && w.Code != http.StatusNoContent {
+			t.Fatalf("%d: expected status 204 got %d", i, w.Code)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != d.allow {
+			t.Fatalf("%d: expected origin %q got %q", i, d.allow, got)
+		}
+
+		if len(d.allow) == 0 {
+			continue
+		}
+
+		if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Fatalf("%d: expected credentials to be allowed", i)
+		}
+
+		if d.preflight {
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "3600" {
+				t.Fatalf("%d: expected max age 3600 got %q", i, got)
+			}
+		} else if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+			t.Fatalf("%d: expected exposed headers got %q", i, got)
+		}
+	}
+
+	// any origin is allowed by a wildcard without credentials
+	opts = NewOptions(WithCORS(CORSConfig{AllowedOrigins: []string{"*"}}))
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Origin", "https://evil.com")
+
+	w := httptest.NewRecorder()
+	CORS(opts, w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Expected origin * got %q", got)
+	}
+
+	// a wildcard with credentials is logged and loses the credentials
+	l := &testLogger{}
+	opts = NewOptions(WithLogger(l), WithCORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}))
+
+	if l.logged != 1 {
+		t.Fatalf("Expected the invalid config to be logged got %d logs", l.logged)
+	}
+
+	if opts.CORS.AllowCredentials {
+		t.Fatal("Expected the credentials to be dropped")
+	}
+
+	if err := (&CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Validate(); err != ErrCORSCredentials {
+		t.Fatalf("Expected %v got %v", ErrCORSCredentials, err)
+	}
+
+	// and doesn't echo the origin or allow credentials if set directly
+	opts.CORS = &CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+
+	for _, method := range []string{"GET", "OPTIONS"} {
+		r := httptest.NewRequest(method, "/foo", nil)
+		r.Header.Set("Origin", "https://evil.com")
+		r.Header.Set("Access-Control-Request-Method", "GET")
+
+		w := httptest.NewRecorder()
+		CORS(opts, w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Fatalf("%s: expected origin * got %q", method, got)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); len(got) > 0 {
+			t.Fatalf("%s: expected no credentials got %q", method, got)
+		}
+	}
+}
@@ -128,7 +128,7 @@ func (e *event) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 	p := c.NewMessage(topic, event)
 
 	// publish event
-	if err := c.Publish(ctx.FromRequest(req), p); err != nil {
+	if err := c.Publish(handler.CallContext(e.opts, ctx.FromRequest(req), req), p); err != nil {
 		http.Error(rsp, err.Error(), http.StatusInternalServerError)
 
 		return
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs parses a list of CIDRs or IP addresses into networks,
+// returning an error for the first invalid entry.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address %q", c)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// trusted checks whether the upstream is in the trusted networks,
+// none are trusted if there are no networks.
+func trusted(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetForwarded prepares the forwarding headers of a request before it's
+// passed to a backend. The client address is appended to X-Forwarded-For,
+// X-Real-IP is set if absent and X-Forwarded-Proto is set based on TLS.
+// With trusted networks, existing headers are dropped unless the request
+// came from one of them to prevent spoofing. Without any the existing
+// chain is kept, e.g. for gateways behind a load balancer.
+func SetForwarded(r *http.Request, nets []*net.IPNet) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return
+	}
+
+	if len(nets) > 0 && !trusted(net.ParseIP(host), nets) {
+		r.Header.Del("X-Forwarded-For")
+		r.Header.Del("X-Forwarded-Proto")
+		r.Header.Del("X-Real-IP")
+	}
+
+	if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		r.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+host)
+	} else {
+		r.Header.Set("X-Forwarded-For", host)
+	}
+
+	if len(r.Header.Get("X-Real-IP")) == 0 {
+		r.Header.Set("X-Real-IP", host)
+	}
+
+	if len(r.Header.Get("X-Forwarded-Proto")) == 0 {
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+
+		r.Header.Set("X-Forwarded-Proto", proto)
+	}
+}
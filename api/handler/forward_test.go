@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetForwarded(t *testing.T) {
+	testData := []struct {
+		remote  string
+		prior   string
+		trusted []string
+		want    string
+	}{
+		{"10.0.0.1:1234", "", nil, "10.0.0.1"},
+		// the chain is kept without trusted proxies
+		{"10.0.0.1:1234", "1.1.1.1", nil, "1.1.1.1, 10.0.0.1"},
+		{"10.0.0.1:1234", "1.1.1.1", []string{"10.0.0.0/8"}, "1.1.1.1, 10.0.0.1"},
+		{"10.0.0.1:1234", "1.1.1.1", []string{"10.0.0.1"}, "1.1.1.1, 10.0.0.1"},
+		{"192.168.0.1:1234", "1.1.1.1", []string{"10.0.0.0/8"}, "192.168.0.1"},
+	}
+
+	for _, d := range testData {
+		r := &http.Request{RemoteAddr: d.remote, Header: make(http.Header)}
+		if len(d.prior) > 0 {
+			r.Header.Set("X-Forwarded-For", d.prior)
+			r.Header.Set("X-Forwarded-Proto", "https")
+		}
+
+		nets, err := ParseCIDRs(d.trusted...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		SetForwarded(r, nets)
+
+		if have := r.Header.Get("X-Forwarded-For"); have != d.want {
+			t.Fatalf("Expected X-Forwarded-For %q got %q", d.want, have)
+		}
+
+		if len(r.Header.Get("X-Forwarded-Proto")) == 0 {
+			t.Fatal("Expected X-Forwarded-Proto to be set")
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	for _, cidrs := range [][]string{{"10.0.0.300"}, {"10.0.0.0/8", "10.0.0.0/33"}, {"localhost"}} {
+		if nets, err := ParseCIDRs(cidrs...); err == nil {
+			t.Fatalf("%v: expected an error got %v", cidrs, nets)
+		}
+	}
+
+	if _, err := NewTrustedProxies("10.0.0.0/8", "10.0.0.0/33"); err == nil {
+		t.Fatal("Expected an error for an invalid trusted proxy")
+	}
+
+	o, err := NewTrustedProxies("10.0.0.0/8", "192.168.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts := NewOptions(o); len(opts.TrustedProxies) != 2 {
+		t.Fatalf("Expected 2 trusted proxies got %v", opts.TrustedProxies)
+	}
+
+	// an invalid proxy is logged and trusts no upstream
+	l := &testLogger{}
+	opts := NewOptions(WithLogger(l), WithTrustedProxies("10.0.0.0/33"))
+
+	if l.logged != 1 {
+		t.Fatalf("Expected the invalid proxy to be logged got %d logs", l.logged)
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{"X-Forwarded-For": {"1.1.1.1"}}}
+	SetForwarded(r, opts.TrustedProxies)
+
+	if have := r.Header.Get("X-Forwarded-For"); have != "10.0.0.1" {
+		t.Fatalf("Expected the chain to be dropped got %q", have)
+	}
+}
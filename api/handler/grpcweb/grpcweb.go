@@ -0,0 +1,315 @@
+// Package grpcweb provides a gRPC-Web handler which translates browser
+// gRPC-Web requests into go-micro rpc calls
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-micro.org/v5/api/handler"
+	"go-micro.org/v5/api/internal/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	raw "go-micro.org/v5/codec/bytes"
+	"go-micro.org/v5/errors"
+	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/selector"
+	"go-micro.org/v5/util/ctx"
+)
+
+const (
+	// Handler is the name of this handler.
+	Handler   = "grpcweb"
+	packageID = "go.micro.api"
+
+	// frame flags
+	dataFlag    byte = 0x00
+	trailerFlag byte = 0x80
+)
+
+type grpcwebHandler struct {
+	opts handler.Options
+}
+
+// frameWriter writes gRPC-Web frames, base64 encoding each
+// frame for the text format.
+type frameWriter struct {
+	w    http.ResponseWriter
+	text bool
+}
+
+func (f *frameWriter) write(flag byte, b []byte) error {
+	frame := make([]byte, 5+len(b))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(b)))
+	copy(frame[5:], b)
+
+	if f.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+
+	if _, err := f.w.Write(frame); err != nil {
+		return err
+	}
+
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+
+	return nil
+}
+
+// trailer writes the trailer frame with the status of the call.
+func (f *frameWriter) trailer(err error) error {
+	code, msg := 0, ""
+
+	if err != nil {
+		ce := errors.Parse(err.Error())
+		code = Code(ce.Code)
+		msg = ce.Detail
+	}
+
+	return f.write(trailerFlag, []byte(fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", code, encodeMessage(msg))))
+}
+
+// encodeMessage percent-encodes the bytes of the grpc-message which
+// aren't printable ascii, and the percent sign, as the spec requires.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// Code translates the http status code of a go-micro
+// error into a grpc status code.
+func Code(code int32) int {
+	switch code {
+	case 0, http.StatusOK:
+		return 0 // OK
+	case http.StatusBadRequest:
+		return 3 // InvalidArgument
+	case http.StatusUnauthorized:
+		return 16 // Unauthenticated
+	case http.StatusForbidden:
+		return 7 // PermissionDenied
+	case http.StatusNotFound:
+		return 5 // NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return 12 // Unimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return 4 // DeadlineExceeded
+	case http.StatusConflict:
+		return 6 // AlreadyExists
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return 8 // ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return 14 // Unavailable
+	case http.StatusInternalServerError:
+		return 13 // Internal
+	}
+
+	return 2 // Unknown
+}
+
+// readMessage reads the first message frame of the request body,
+// rejecting frames longer than max before they're allocated.
+func readMessage(r io.Reader, text bool, max int64) ([]byte, error) {
+	if text {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if hdr[0]&trailerFlag != 0 {
+		return nil, nil
+	}
+
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if int64(size) > max {
+		return nil, errors.New(packageID, fmt.Sprintf("message of %d bytes exceeds the limit of %d", size, max), http.StatusRequestEntityTooLarge)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func isStream(srv *router.Route) bool {
+	if srv.Endpoint.Stream {
+		return true
+	}
+
+	for _, service := range srv.Versions {
+		for _, ep := range service.Endpoints {
+			if ep.Name == srv.Endpoint.Name && ep.Metadata["stream"] == "true" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (h *grpcwebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.opts.Logger
+
+	bsize := handler.DefaultMaxRecvSize
+	if h.opts.MaxRecvSize > 0 {
+		bsize = h.opts.MaxRecvSize
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, bsize)
+	defer r.Body.Close()
+
+	ct := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "application/grpc-web") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	text := strings.HasPrefix(ct, "application/grpc-web-text")
+	if text {
+		w.Header().Set("Content-Type", "application/grpc-web-text+proto")
+	} else {
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+	}
+
+	fw := &frameWriter{w: w, text: text}
+
+	// errors after this point are sent as trailers
+	w.WriteHeader(http.StatusOK)
+
+	if err := h.serve(r, fw, bsize); err != nil {
+		logger.Logf(log.DebugLevel, "grpcweb call error: %v", err)
+	}
+}
+
+// serve makes the call and writes the responses and trailer. The
+// request message is at most max bytes.
+func (h *grpcwebHandler) serve(r *http.Request, fw *frameWriter, max int64) error {
+	if h.opts.Router == nil {
+		return fw.trailer(errors.InternalServerError(packageID, "no route found"))
+	}
+
+	service, err := h.opts.Router.Route(r)
+	if err != nil {
+		return fw.trailer(errors.InternalServerError(packageID, err.Error()))
+	}
+
+	msg, err := readMessage(r.Body, fw.text, max)
+	if err != nil {
+		if _, ok := err.(*errors.Error); ok {
+			return fw.trailer(err)
+		}
+
+		return fw.trailer(errors.BadRequest(packageID, err.Error()))
+	}
+
+	c := h.opts.Client
+	cx := handler.CallContext(h.opts, ctx.FromRequest(r), r)
+	so := selector.WithStrategy(handler.Strategy(service.Versions, h.opts))
+
+	if isStream(service) {
+		return fw.trailer(h.stream(cx, c, service, msg, fw, so))
+	}
+
+	req := c.NewRequest(
+		service.Service,
+		service.Endpoint.Name,
+		proto.NewMessage(msg),
+		client.WithContentType("application/grpc+proto"),
+	)
+
+	rsp := &proto.Message{}
+
+	if err := c.Call(cx, req, rsp, client.WithSelectOption(so)); err != nil {
+		return fw.trailer(err)
+	}
+
+	b, err := rsp.Marshal()
+	if err != nil {
+		return fw.trailer(err)
+	}
+
+	if err := fw.write(dataFlag, b); err != nil {
+		return err
+	}
+
+	return fw.trailer(nil)
+}
+
+// stream makes a server streaming call writing a frame per message.
+func (h *grpcwebHandler) stream(cx context.Context, c client.Client, service *router.Route, msg []byte, fw *frameWriter, so selector.SelectOption) error {
+	req := c.NewRequest(
+		service.Service,
+		service.Endpoint.Name,
+		&raw.Frame{Data: msg},
+		client.WithContentType("application/grpc+proto"),
+		client.StreamingRequest(),
+	)
+
+	cCtx, cancel := context.WithCancel(cx)
+	defer cancel()
+
+	stream, err := c.Stream(cCtx, req, client.WithSelectOption(so))
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if err := stream.Send(&raw.Frame{Data: msg}); err != nil {
+		return err
+	}
+
+	rsp := stream.Response()
+
+	for {
+		buf, err := rsp.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fw.write(dataFlag, buf); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *grpcwebHandler) String() string {
+	return Handler
+}
+
+// NewHandler returns a gRPC-Web handler. Unary and server streaming
+// calls are supported for both the binary and text formats.
+func NewHandler(opts ...handler.Option) handler.Handler {
+	return &grpcwebHandler{
+		opts: handler.NewOptions(opts...),
+	}
+}
@@ -0,0 +1,168 @@
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-micro.org/v5/api/handler"
+	"go-micro.org/v5/api/internal/proto"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+)
+
+type testRouter struct {
+	router.Router
+}
+
+func (r *testRouter) Route(req *http.Request) (*router.Route, error) {
+	return &router.Route{
+		Service:  "go.micro.srv.test",
+		Endpoint: &router.Endpoint{Name: "Test.Call"},
+	}, nil
+}
+
+type testClient struct {
+	client.Client
+
+	err   error
+	calls int
+	// node is the one the call's strategy selects
+	node *registry.Node
+}
+
+// Call echoes the request back.
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.calls++
+
+	var co client.CallOptions
+	for _, o := range opts {
+		o(&co)
+	}
+
+	var so selector.SelectOptions
+	for _, o := range co.SelectOptions {
+		o(&so)
+	}
+
+	if so.Strategy != nil {
+		c.node, _ = so.Strategy(nil)()
+	}
+
+	if c.err != nil {
+		return c.err
+	}
+
+	b, _ := req.Body().(*proto.Message).Marshal()
+
+	return rsp.(*proto.Message).Unmarshal(b)
+}
+
+func frame(flag byte, b []byte) []byte {
+	return append([]byte{flag, 0, 0, 0, byte(len(b))}, b...)
+}
+
+func TestGRPCWeb(t *testing.T) {
+	testData := []struct {
+		contentType string
+		err         error
+		want        []byte
+	}{
+		{
+			contentType: "application/grpc-web+proto",
+			want:        append(frame(dataFlag, []byte("hello")), frame(trailerFlag, []byte("grpc-status: 0\r\ngrpc-message: \r\n"))...),
+		},
+		{
+			contentType: "application/grpc-web-text",
+			want: []byte(base64.StdEncoding.EncodeToString(frame(dataFlag, []byte("hello"))) +
+				base64.StdEncoding.EncodeToString(frame(trailerFlag, []byte("grpc-status: 0\r\ngrpc-message: \r\n")))),
+		},
+		{
+			contentType: "application/grpc-web+proto",
+			err:         errors.NotFound("go.micro.srv.test", "not found"),
+			want:        frame(trailerFlag, []byte("grpc-status: 5\r\ngrpc-message: not found\r\n")),
+		},
+		{
+			contentType: "application/grpc-web+proto",
+			err:         errors.NotFound("go.micro.srv.test", "%s", "100% médaille\r\n"),
+			want:        frame(trailerFlag, []byte("grpc-status: 5\r\ngrpc-message: 100%25 m%C3%A9daille%0D%0A\r\n")),
+		},
+	}
+
+	for _, d := range testData {
+		h := NewHandler(
+			handler.WithClient(&testClient{Client: client.NewClient(), err: d.err}),
+			handler.WithRouter(&testRouter{}),
+		)
+
+		body := frame(dataFlag, []byte("hello"))
+		if strings.HasPrefix(d.contentType, "application/grpc-web-text") {
+			body = []byte(base64.StdEncoding.EncodeToString(body))
+		}
+
+		req := httptest.NewRequest("POST", "/Test/Call", bytes.NewReader(body))
+		req.Header.Set("Content-Type", d.contentType)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if !bytes.Equal(w.Body.Bytes(), d.want) {
+			t.Fatalf("%s: expected %q got %q", d.contentType, d.want, w.Body.Bytes())
+		}
+	}
+}
+
+func TestMessageLimit(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		handler.WithMaxRecvSize(1024),
+	)
+
+	// the length is rejected before the message is allocated or read
+	req := httptest.NewRequest("POST", "/Test/Call", bytes.NewReader([]byte{dataFlag, 0xff, 0xff, 0xff, 0xff}))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if !bytes.Contains(w.Body.Bytes(), []byte("grpc-status: 8\r\n")) {
+		t.Fatalf("Expected grpc-status 8 got %q", w.Body.Bytes())
+	}
+
+	if c.calls != 0 {
+		t.Fatalf("Expected no calls got %d", c.calls)
+	}
+}
+
+func TestStrategy(t *testing.T) {
+	c := &testClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		handler.WithStrategy(func([]*registry.Service) selector.Next {
+			return func() (*registry.Node, error) {
+				return &registry.Node{Id: "picked"}, nil
+			}
+		}),
+	)
+
+	req := httptest.NewRequest("POST", "/Test/Call", bytes.NewReader(frame(dataFlag, []byte("hello"))))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if c.node == nil || c.node.Id != "picked" {
+		t.Fatalf("Expected the configured strategy to pick the node got %v", c.node)
+	}
+}
@@ -2,14 +2,19 @@
 package http
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"mime"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 
 	"go-micro.org/v5/api/handler"
 	"go-micro.org/v5/api/router"
+	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/registry"
 	"go-micro.org/v5/selector"
 )
 
@@ -20,67 +25,318 @@ const (
 
 type httpHandler struct {
 	options handler.Options
+
+	// the proxy shared by all requests so
+	// connections to the nodes are pooled
+	proxy *httputil.ReverseProxy
+	// the proxy for event streams and clients
+	// of trailers which flushes each write
+	events *httputil.ReverseProxy
+
+	// compiled rewrite regexes
+	rewrites sync.Map
+
+	// sem holds a token for each request
+	// proxied, nil if they're unlimited
+	sem chan struct{}
+}
+
+type proxyKey struct{}
+
+// proxyRequest is the routing state of a request being proxied.
+type proxyRequest struct {
+	route *router.Route
+	next  selector.Next
+	// number of nodes the request may be tried against
+	attempts int
+	// the node the request was last sent to
+	node *registry.Node
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	service, err := h.getService(r)
+	if handler.CORS(h.options, w, r) {
+		return
+	}
+
+	if handler.Throttled(h.options, w, r) {
+		return
+	}
+
+	route, err := h.getRoute(r)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if len(service) == 0 {
+	var nodes int
+	for _, s := range route.Versions {
+		nodes += len(s.Nodes)
+	}
+
+	if nodes == 0 {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	rp, err := url.Parse(service)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	handler.SetForwarded(r, h.options.TrustedProxies)
+
+	pr := &proxyRequest{
+		route:    route,
+		next:     h.strategy()(route.Versions),
+		attempts: h.attempts(r.Method),
+	}
+
+	if pr.attempts > nodes {
+		pr.attempts = nodes
+	}
+
+	// the forwarding chain is already set, clear the remote
+	// address so the proxy doesn't append the client again
+	req := r.WithContext(context.WithValue(r.Context(), proxyKey{}, pr))
+	req.RemoteAddr = ""
+
+	// the slot is taken before the body is buffered so
+	// rejected requests don't hold their bodies in memory
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+			defer func() { <-h.sem }()
+		default:
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	// buffer the body so it can be replayed, bodies
+	// above the size limit are only sent once
+	if pr.attempts > 1 {
+		ok, err := rewind(req, h.maxRecvSize(route))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !ok {
+			pr.attempts = 1
+		}
+	}
+
+	if isEventStream(r) || acceptsTrailers(r) {
+		h.events.ServeHTTP(w, req)
 		return
 	}
 
-	httputil.NewSingleHostReverseProxy(rp).ServeHTTP(w, r)
+	h.proxy.ServeHTTP(w, req)
 }
 
-// getService returns the service for this request from the selector.
-func (h *httpHandler) getService(r *http.Request) (string, error) {
-	var service *router.Route
+// isEventStream reports whether the client requested server-sent events.
+// Responses with a text/event-stream content type are always flushed
+// immediately by the reverse proxy.
+func isEventStream(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, ct := range strings.Split(v, ",") {
+			if mt, _, err := mime.ParseMediaType(ct); err == nil && mt == "text/event-stream" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
 
-	if h.options.Router != nil {
-		// try get service from router
-		s, err := h.options.Router.Route(r)
-		if err != nil {
-			return "", err
+// acceptsTrailers reports whether the client accepts trailers, e.g. a
+// gRPC client reading the final status. Their responses are flushed as
+// they're written and the trailers the backend announces in the Trailer
+// header are copied once the body is.
+func acceptsTrailers(r *http.Request) bool {
+	for _, v := range r.Header.Values("Te") {
+		for _, te := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(te), "trailers") {
+				return true
+			}
 		}
+	}
 
-		service = s
-	} else {
-		// we have no way of routing the request
-		return "", errors.New("no route found")
+	return false
+}
+
+// director selects the node for each request and rewrites the path.
+func (h *httpHandler) director(req *http.Request) {
+	pr, ok := req.Context().Value(proxyKey{}).(*proxyRequest)
+	if !ok {
+		return
 	}
 
-	// create a random selector
-	next := selector.Random(service.Versions)
+	req.URL.Scheme = "http"
 
-	// get the next node
-	s, err := next()
-	if err != nil {
-		return "", nil
+	if node, err := pr.next(); err == nil {
+		req.URL.Host = node.Address
+		pr.node = node
+	}
+
+	if p := h.rewrite(pr.route.Endpoint, req.URL.Path); p != req.URL.Path {
+		req.URL.Path = p
+		req.URL.RawPath = ""
+	}
+
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// explicitly disable User-Agent so it's not set to default value
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// setUpstream sets the X-Micro-Upstream header
+// to the node the request was last sent to.
+func setUpstream(hdr http.Header, r *http.Request) {
+	pr, ok := r.Context().Value(proxyKey{}).(*proxyRequest)
+	if !ok || pr.node == nil {
+		return
+	}
+
+	hdr.Set("X-Micro-Upstream", pr.node.Address)
+}
+
+// strategy returns the configured selector strategy, splitting
+// between versions by weight if set or at random otherwise.
+func (h *httpHandler) strategy() selector.Strategy {
+	if h.options.Strategy != nil {
+		return h.options.Strategy
+	}
+
+	if h.options.VersionWeights != nil {
+		return selector.Weighted(h.options.VersionWeights)
+	}
+
+	return selector.Random
+}
+
+// attempts returns the number of nodes a request may be tried
+// against. Only idempotent methods are retried.
+func (h *httpHandler) attempts(method string) int {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	case http.MethodPost:
+		if !h.options.RetryPost {
+			return 1
+		}
+	default:
+		return 1
+	}
+
+	return h.options.Retries + 1
+}
+
+// maxRecvSize returns the size of the largest body buffered for
+// retries, the route's limit if set or the handler's otherwise.
+func (h *httpHandler) maxRecvSize(route *router.Route) int64 {
+	if route.Endpoint != nil && route.Endpoint.MaxRecvSize > 0 {
+		return route.Endpoint.MaxRecvSize
 	}
 
-	return fmt.Sprintf("http://%s", s.Address), nil
+	if h.options.MaxRecvSize > 0 {
+		return h.options.MaxRecvSize
+	}
+
+	return handler.DefaultMaxRecvSize
+}
+
+// rewrite applies the path rewriting of the matched endpoint.
+// Without any configured the path is returned unchanged.
+func (h *httpHandler) rewrite(ep *router.Endpoint, path string) string {
+	if ep == nil {
+		return path
+	}
+
+	// the prefix is stripped by whole segments so /foo leaves /foobar as is
+	if prefix := strings.TrimSuffix(ep.StripPrefix, "/"); len(prefix) > 0 {
+		if path == prefix {
+			path = "/"
+		} else if strings.HasPrefix(path, prefix+"/") {
+			path = path[len(prefix):]
+		}
+	}
+
+	if len(ep.Rewrite) > 0 {
+		v, ok := h.rewrites.Load(ep.Rewrite)
+		if !ok {
+			re, err := regexp.CompilePOSIX(ep.Rewrite)
+			if err != nil {
+				return path
+			}
+
+			v, _ = h.rewrites.LoadOrStore(ep.Rewrite, re)
+		}
+
+		path = v.(*regexp.Regexp).ReplaceAllString(path, ep.RewriteTo)
+	}
+
+	return path
+}
+
+// getRoute returns the route for this request from the router.
+func (h *httpHandler) getRoute(r *http.Request) (*router.Route, error) {
+	if h.options.Router == nil {
+		// we have no way of routing the request
+		return nil, errors.New("no route found")
+	}
+
+	return h.options.Router.Route(r)
 }
 
 func (h *httpHandler) String() string {
 	return "http"
 }
 
-// NewHandler returns a http proxy handler.
+// NewHandler returns a http proxy handler. Each request is sent to a
+// node picked by the selector strategy, see handler.WithStrategy.
+// Server-sent events and the responses of clients accepting trailers are
+// streamed to the client as they arrive, followed by the trailers.
 func NewHandler(opts ...handler.Option) handler.Handler {
 	options := handler.NewOptions(opts...)
 
-	return &httpHandler{
+	h := &httpHandler{
 		options: options,
 	}
+
+	if options.MaxConcurrent > 0 {
+		h.sem = make(chan struct{}, options.MaxConcurrent)
+	}
+
+	h.proxy = &httputil.ReverseProxy{
+		Director:  h.director,
+		Transport: &retryTransport{RoundTripper: http.DefaultTransport},
+		ModifyResponse: func(rsp *http.Response) error {
+			if options.UpstreamHeader {
+				setUpstream(rsp.Header, rsp.Request)
+			}
+
+			// the gateway's policy replaces the backend's
+			if options.CORS != nil {
+				for k := range rsp.Header {
+					if handler.IsCORSHeader(k) {
+						rsp.Header.Del(k)
+					}
+				}
+			}
+
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			options.Logger.Logf(log.ErrorLevel, "http proxy error: %v", err)
+
+			if options.UpstreamHeader {
+				setUpstream(w.Header(), r)
+			}
+
+			// the error may reveal the nodes, which are only
+			// exposed to the client by the upstream header
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		},
+	}
+
+	events := *h.proxy
+	events.FlushInterval = -1
+	h.events = &events
+
+	return h
 }
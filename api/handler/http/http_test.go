@@ -1,10 +1,15 @@
 package http
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"go-micro.org/v5/api/handler"
 	"go-micro.org/v5/api/resolver"
@@ -124,3 +129,552 @@ func TestHttpHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRewrite(t *testing.T) {
+	h := &httpHandler{}
+
+	testData := []struct {
+		ep   *router.Endpoint
+		path string
+		want string
+	}{
+		{nil, "/foo/bar", "/foo/bar"},
+		{&router.Endpoint{}, "/foo/bar", "/foo/bar"},
+		{&router.Endpoint{StripPrefix: "/foo"}, "/foo/bar", "/bar"},
+		{&router.Endpoint{StripPrefix: "/foo"}, "/foo", "/"},
+		{&router.Endpoint{StripPrefix: "/foo"}, "/baz/bar", "/baz/bar"},
+		{&router.Endpoint{StripPrefix: "/foo"}, "/foobar", "/foobar"},
+		{&router.Endpoint{StripPrefix: "/foo"}, "/foobar/baz", "/foobar/baz"},
+		{&router.Endpoint{StripPrefix: "/foo/"}, "/foo/bar", "/bar"},
+		{&router.Endpoint{StripPrefix: "/foo/"}, "/foo", "/"},
+		{&router.Endpoint{StripPrefix: "/v1/api"}, "/v1/api/users", "/users"},
+		{&router.Endpoint{Rewrite: "^/v1/(.*)$", RewriteTo: "/api/$1"}, "/v1/users", "/api/users"},
+		{&router.Endpoint{StripPrefix: "/svc", Rewrite: "^/old", RewriteTo: "/new"}, "/svc/old/x", "/new/x"},
+	}
+
+	for _, d := range testData {
+		if got := h.rewrite(d.ep, d.path); got != d.want {
+			t.Fatalf("rewrite %s: expected %s got %s", d.path, d.want, got)
+		}
+	}
+}
+
+func TestRewind(t *testing.T) {
+	testData := []struct {
+		body   string
+		length int64
+		ok     bool
+	}{
+		{"hello", 5, true},
+		{"hello", -1, true},
+		// too large bodies aren't buffered
+		{"hello world", 11, false},
+		{"hello world", -1, false},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("POST", "/foo", strings.NewReader(d.body))
+		r.ContentLength = d.length
+
+		ok, err := rewind(r, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok != d.ok {
+			t.Fatalf("rewind %q: expected %v got %v", d.body, d.ok, ok)
+		}
+
+		if ok == (r.GetBody == nil) {
+			t.Fatalf("rewind %q: expected the body to be replayable %v", d.body, ok)
+		}
+
+		// the body is sent whole either way
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != d.body {
+			t.Fatalf("rewind %q: expected the body to be unchanged, got %q", d.body, b)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// a registered node which no longer accepts connections
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Close()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: l.Addr().String()},
+			{Id: "foo-2", Address: dead.Addr().String()},
+		},
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	m := http.NewServeMux()
+	m.HandleFunc("/foo/bar", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`you got served`))
+	})
+
+	go http.Serve(l, m)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	p := NewHandler(handler.WithRouter(rt), handler.WithRetries(1))
+
+	// both nodes are tried in random order, so repeat
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Expected 200 response got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	if n := p.(*httpHandler).attempts(http.MethodPost); n != 1 {
+		t.Fatalf("Expected POST to be attempted once got %d", n)
+	}
+
+	p = NewHandler(handler.WithRetries(1), handler.WithRetryPost(true))
+	if n := p.(*httpHandler).attempts(http.MethodPost); n != 2 {
+		t.Fatalf("Expected POST to be attempted twice got %d", n)
+	}
+}
+
+func TestStrategy(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+	}
+
+	for _, id := range []string{"foo-1", "foo-2"} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		m := http.NewServeMux()
+		m.HandleFunc("/foo/bar", func(id string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(id))
+			}
+		}(id))
+
+		go http.Serve(l, m)
+
+		s.Nodes = append(s.Nodes, &registry.Node{Id: id, Address: l.Addr().String()})
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	p := NewHandler(handler.WithRouter(rt))
+	seen := make(map[string]bool)
+
+	// requests are spread across the nodes by the one proxy
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Expected 200 response got %d %s", w.Code, w.Body.String())
+		}
+
+		seen[w.Body.String()] = true
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("Expected requests to reach both nodes, got %v", seen)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: l.Addr().String()},
+		},
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	// the second event is only sent once the first is received
+	next := make(chan bool)
+
+	m := http.NewServeMux()
+	m.HandleFunc("/foo/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-next:
+		case <-r.Context().Done():
+			return
+		}
+
+		w.Write([]byte("data: 2\n\n"))
+	})
+
+	go http.Serve(l, m)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	ts := httptest.NewServer(NewHandler(handler.WithRouter(rt)))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/foo/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	lines := make(chan string)
+
+	go func() {
+		br := bufio.NewReader(rsp.Body)
+
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+
+			lines <- line
+		}
+	}()
+
+	for _, want := range []string{"data: 1\n", "\n"} {
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Fatalf("expected %q got %q", want, line)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("event was not streamed")
+		}
+	}
+
+	close(next)
+
+	select {
+	case line := <-lines:
+		if line != "data: 2\n" {
+			t.Fatalf("expected %q got %q", "data: 2\n", line)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("event was not streamed")
+	}
+}
+
+type readCounter struct {
+	io.Reader
+	reads int
+}
+
+func (r *readCounter) Read(b []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(b)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: l.Addr().String()},
+			// a second node so idempotent requests are retried
+			{Id: "foo-2", Address: l.Addr().String()},
+		},
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	started := make(chan bool, 1)
+	release := make(chan bool)
+
+	m := http.NewServeMux()
+	m.HandleFunc("/foo/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- true:
+		default:
+		}
+
+		<-release
+		w.Write([]byte(`done`))
+	})
+
+	go http.Serve(l, m)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	h := NewHandler(handler.WithRouter(rt), handler.WithMaxConcurrent(1), handler.WithRetries(1))
+
+	done := make(chan int)
+
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/foo/slow", nil))
+		done <- w.Code
+	}()
+
+	<-started
+
+	// the limit is reached while the first request is in flight
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/foo/slow", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 response got %d", w.Code)
+	}
+
+	// the body of a rejected request isn't buffered
+	body := &readCounter{Reader: strings.NewReader("hello")}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/foo/slow", body))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 response got %d", w.Code)
+	}
+
+	if body.reads > 0 {
+		t.Fatal("Expected the body of the rejected request not to be read")
+	}
+
+	close(release)
+
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("Expected 200 response got %d", code)
+	}
+
+	// the slot is released
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/foo/slow", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 response got %d", w.Code)
+	}
+}
+
+func TestUpstreamError(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	// a registered node which no longer accepts connections
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Close()
+
+	addr := dead.Addr().String()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: addr},
+		},
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	for _, upstream := range []bool{false, true} {
+		p := NewHandler(handler.WithRouter(rt), handler.WithUpstreamHeader(upstream))
+
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest("GET", "/foo/bar", nil))
+
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("Expected 502 response got %d", w.Code)
+		}
+
+		// the error is only logged
+		if strings.Contains(w.Body.String(), addr) {
+			t.Fatalf("Expected a generic error got %s", w.Body.String())
+		}
+
+		want := ""
+		if upstream {
+			want = addr
+		}
+
+		if got := w.Header().Get("X-Micro-Upstream"); got != want {
+			t.Fatalf("Expected upstream header %q got %q", want, got)
+		}
+	}
+
+	err = (&proxyRequest{node: s.Nodes[0]}).fail(errors.New("failed"))
+
+	var ue *UpstreamError
+	if !errors.As(err, &ue) || ue.Id != "foo-1" || ue.Address != addr {
+		t.Fatalf("Expected an upstream error got %v", err)
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &registry.Service{
+		Name: "go.micro.api.foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: l.Addr().String()},
+		},
+	}
+
+	r.Register(s)
+	defer r.Deregister(s)
+
+	m := http.NewServeMux()
+	m.HandleFunc("/foo/bar", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("body"))
+		w.(http.Flusher).Flush()
+
+		w.Header().Set("Grpc-Status", "0")
+		// undeclared trailers are also copied
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+	})
+
+	go http.Serve(l, m)
+
+	rt := regRouter.NewRouter(
+		router.WithHandler("http"),
+		router.WithRegistry(r),
+		router.WithResolver(vpath.NewResolver(
+			resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+		)),
+	)
+
+	ts := httptest.NewServer(NewHandler(handler.WithRouter(rt)))
+	defer ts.Close()
+
+	for _, te := range []string{"", "trailers"} {
+		req, err := http.NewRequest("POST", ts.URL+"/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(te) > 0 {
+			req.Header.Set("TE", te)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != "body" {
+			t.Fatalf("expected body got %q", b)
+		}
+
+		if got := rsp.Trailer.Get("Grpc-Status"); got != "0" {
+			t.Fatalf("expected the Grpc-Status trailer got %q", got)
+		}
+
+		if got := rsp.Trailer.Get("Grpc-Message"); got != "ok" {
+			t.Fatalf("expected the Grpc-Message trailer got %q", got)
+		}
+	}
+}
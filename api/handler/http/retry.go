@@ -0,0 +1,157 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"go-micro.org/v5/registry"
+)
+
+// retryTransport retries a request against another node
+// when the connection to a node can't be established.
+type retryTransport struct {
+	http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rsp, err := t.RoundTripper.RoundTrip(req)
+
+	pr, ok := req.Context().Value(proxyKey{}).(*proxyRequest)
+	if !ok || err == nil {
+		return rsp, err
+	}
+
+	if !isDialError(err) {
+		return rsp, pr.fail(err)
+	}
+
+	tried := map[string]bool{req.URL.Host: true}
+
+	for len(tried) < pr.attempts {
+		node, nerr := t.untried(pr, tried)
+		if nerr != nil {
+			break
+		}
+
+		tried[node.Address] = true
+		pr.node = node
+
+		r := req.Clone(req.Context())
+		r.URL.Host = node.Address
+
+		if req.GetBody != nil {
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return nil, gerr
+			}
+
+			r.Body = body
+		}
+
+		rsp, err = t.RoundTripper.RoundTrip(r)
+		if err == nil {
+			return rsp, nil
+		}
+
+		if !isDialError(err) {
+			return rsp, pr.fail(err)
+		}
+	}
+
+	return nil, pr.fail(fmt.Errorf("all %d nodes failed, last error: %w", len(tried), err))
+}
+
+// UpstreamError is the error of a request the http handler
+// failed to proxy, with the node it was last sent to.
+type UpstreamError struct {
+	Id      string
+	Address string
+	Err     error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("upstream %s (%s): %v", e.Address, e.Id, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// fail returns the error with the node the request was last sent to.
+func (pr *proxyRequest) fail(err error) error {
+	if pr.node == nil {
+		return err
+	}
+
+	return &UpstreamError{
+		Id:      pr.node.Id,
+		Address: pr.node.Address,
+		Err:     err,
+	}
+}
+
+// untried selects a node the request hasn't been sent to.
+func (t *retryTransport) untried(pr *proxyRequest, tried map[string]bool) (*registry.Node, error) {
+	// the strategy may pick a node more than once
+	for i := 0; i < pr.attempts*3; i++ {
+		node, err := pr.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if !tried[node.Address] {
+			return node, nil
+		}
+	}
+
+	return nil, errors.New("no untried nodes")
+}
+
+// isDialError reports whether the request failed before
+// reaching the node, in which case it's safe to retry.
+func isDialError(err error) bool {
+	var oe *net.OpError
+
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// rewind buffers the request body so it can be sent again, reporting
+// whether it did. Bodies larger than max aren't buffered, what was read
+// of them is put back in front of the rest of the body.
+func rewind(r *http.Request, max int64) (bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true, nil
+	}
+
+	if r.ContentLength > max {
+		return false, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		r.Body.Close()
+		return false, err
+	}
+
+	if int64(len(b)) > max {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+
+		return false, nil
+	}
+
+	r.Body.Close()
+
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,368 @@
+// Package jsonrpc provides a JSON-RPC 2.0 handler which serves
+// go-micro rpc calls from a single http endpoint
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go-micro.org/v5/api/handler"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/selector"
+	"go-micro.org/v5/util/ctx"
+)
+
+const (
+	// Handler is the name of this handler.
+	Handler = "jsonrpc"
+
+	version = "2.0"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+	// ServerError is used for go-micro errors
+	// without a matching JSON-RPC code
+	ServerError = -32000
+)
+
+var (
+	// DefaultMaxBatch is the most calls a batch may hold.
+	DefaultMaxBatch = 100
+	// DefaultBatchConcurrency is the most calls of a batch served at once.
+	DefaultBatchConcurrency = 10
+)
+
+// Request is a JSON-RPC request envelope.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC response envelope.
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error is a JSON-RPC error object. Data holds
+// the go-micro error the call failed with.
+type Error struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *errors.Error `json:"data,omitempty"`
+}
+
+type jsonrpcHandler struct {
+	opts handler.Options
+}
+
+// Code translates the http status code of a go-micro
+// error into a JSON-RPC error code.
+func Code(code int32) int {
+	switch code {
+	case http.StatusBadRequest:
+		return InvalidParams
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return MethodNotFound
+	case 0, http.StatusInternalServerError:
+		return InternalError
+	}
+
+	return ServerError
+}
+
+func newError(code int, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// fromError converts the error of a call.
+func fromError(err error) *Error {
+	e := errors.FromError(err)
+
+	msg := e.Detail
+	if len(msg) == 0 {
+		msg = e.Status
+	}
+
+	return &Error{
+		Code:    Code(e.Code),
+		Message: msg,
+		Data:    e,
+	}
+}
+
+// path maps a method such as greeter.Say.Hello to the path
+// /greeter/Say/Hello the router resolves. Methods which are
+// already paths are used as is.
+func path(method string) string {
+	if strings.HasPrefix(method, "/") {
+		return method
+	}
+
+	return "/" + strings.ReplaceAll(method, ".", "/")
+}
+
+// params returns the request body of the call. Named params are
+// passed through, positional params must hold a single value.
+func params(p json.RawMessage) (json.RawMessage, bool) {
+	p = bytes.TrimSpace(p)
+
+	switch {
+	case len(p) == 0, bytes.Equal(p, []byte("null")):
+		return json.RawMessage("{}"), true
+	case p[0] == '{':
+		return p, true
+	case p[0] == '[':
+		var args []json.RawMessage
+		if err := json.Unmarshal(p, &args); err != nil || len(args) != 1 {
+			return nil, false
+		}
+
+		return args[0], true
+	}
+
+	return nil, false
+}
+
+func (h *jsonrpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.opts.Logger
+
+	bsize := handler.DefaultMaxRecvSize
+	if h.opts.MaxRecvSize > 0 {
+		bsize = h.opts.MaxRecvSize
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, bsize)
+	defer r.Body.Close()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.write(w, &Response{Version: version, Error: newError(ParseError, err.Error())})
+		return
+	}
+
+	b = bytes.TrimSpace(b)
+
+	// a single call
+	if len(b) == 0 || b[0] != '[' {
+		var req Request
+		if err := json.Unmarshal(b, &req); err != nil {
+			h.write(w, &Response{Version: version, Error: newError(ParseError, "parse error")})
+			return
+		}
+
+		rsp := h.call(r, &req)
+		if rsp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.write(w, rsp)
+
+		return
+	}
+
+	// a batch of calls
+	var batch []json.RawMessage
+	if err := json.Unmarshal(b, &batch); err != nil {
+		h.write(w, &Response{Version: version, Error: newError(ParseError, "parse error")})
+		return
+	}
+
+	if len(batch) == 0 {
+		h.write(w, &Response{Version: version, Error: newError(InvalidRequest, "empty batch")})
+		return
+	}
+
+	if len(batch) > DefaultMaxBatch {
+		h.write(w, &Response{Version: version, Error: newError(InvalidRequest, "batch too large")})
+		return
+	}
+
+	rsps := make([]*Response, len(batch))
+
+	var wg sync.WaitGroup
+
+	// bounds the calls in flight
+	sem := make(chan struct{}, DefaultBatchConcurrency)
+
+	for i, msg := range batch {
+		var req Request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			rsps[i] = &Response{Version: version, Error: newError(InvalidRequest, "invalid request"), ID: json.RawMessage("null")}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, req *Request) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			rsps[i] = h.call(r, req)
+		}(i, &req)
+	}
+
+	wg.Wait()
+
+	// notifications are not answered
+	out := make([]*Response, 0, len(rsps))
+
+	for _, rsp := range rsps {
+		if rsp != nil {
+			out = append(out, rsp)
+		}
+	}
+
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.encode(w, out); err != nil {
+		logger.Log(log.ErrorLevel, err)
+	}
+}
+
+// call serves a single request. A nil response
+// is returned for notifications.
+func (h *jsonrpcHandler) call(r *http.Request, req *Request) *Response {
+	rsp := &Response{Version: version, ID: req.ID}
+
+	if req.Version != version || len(req.Method) == 0 {
+		rsp.Error = newError(InvalidRequest, "invalid request")
+
+		// invalid requests are answered with a null id
+		if len(rsp.ID) == 0 {
+			rsp.ID = json.RawMessage("null")
+		}
+
+		return rsp
+	}
+
+	result, err := h.invoke(r, req)
+
+	// no id is a notification
+	if len(req.ID) == 0 {
+		if err != nil {
+			h.opts.Logger.Logf(log.DebugLevel, "jsonrpc notification %s error: %v", req.Method, err.Message)
+		}
+
+		return nil
+	}
+
+	rsp.Result = result
+	rsp.Error = err
+
+	return rsp
+}
+
+// invoke routes the method and calls the backend.
+func (h *jsonrpcHandler) invoke(r *http.Request, req *Request) (json.RawMessage, *Error) {
+	if h.opts.Router == nil {
+		return nil, newError(InternalError, "no route found")
+	}
+
+	// route the method as if it were the request path
+	hr := r.Clone(r.Context())
+	hr.URL.Path = path(req.Method)
+	hr.URL.RawPath = ""
+
+	service, err := h.opts.Router.Route(hr)
+	if err != nil {
+		return nil, newError(MethodNotFound, "method not found")
+	}
+
+	body, ok := params(req.Params)
+	if !ok {
+		return nil, newError(InvalidParams, "invalid params")
+	}
+
+	c := h.opts.Client
+	cx := handler.CallContext(h.opts, ctx.FromRequest(r), r)
+	so := selector.WithStrategy(handler.Strategy(service.Versions, h.opts))
+
+	request := c.NewRequest(
+		service.Service,
+		service.Endpoint.Name,
+		&body,
+		client.WithContentType("application/json"),
+	)
+
+	var response json.RawMessage
+
+	if err := c.Call(cx, request, &response, client.WithSelectOption(so)); err != nil {
+		return nil, fromError(err)
+	}
+
+	if len(response) == 0 {
+		response = json.RawMessage("null")
+	}
+
+	return response, nil
+}
+
+func (h *jsonrpcHandler) write(w http.ResponseWriter, rsp *Response) {
+	if len(rsp.ID) == 0 {
+		rsp.ID = json.RawMessage("null")
+	}
+
+	if err := h.encode(w, rsp); err != nil {
+		h.opts.Logger.Log(log.ErrorLevel, err)
+	}
+}
+
+func (h *jsonrpcHandler) encode(w http.ResponseWriter, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+func (h *jsonrpcHandler) String() string {
+	return Handler
+}
+
+// NewHandler returns a JSON-RPC 2.0 handler. Methods are routed as paths,
+// so greeter.Say.Hello is resolved as /greeter/Say/Hello. Batches of up
+// to DefaultMaxBatch calls, served DefaultBatchConcurrency at a time, and
+// notifications are supported and go-micro errors are returned as
+// JSON-RPC error objects.
+func NewHandler(opts ...handler.Option) handler.Handler {
+	return &jsonrpcHandler{
+		opts: handler.NewOptions(opts...),
+	}
+}
@@ -0,0 +1,242 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/api/handler"
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+)
+
+type testRouter struct {
+	router.Router
+}
+
+// Route maps /greeter/Say/Hello to the greeter service.
+func (r *testRouter) Route(req *http.Request) (*router.Route, error) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "greeter" {
+		return nil, errors.NotFound("go.micro.api", "not found")
+	}
+
+	return &router.Route{
+		Service:  parts[0],
+		Endpoint: &router.Endpoint{Name: parts[1] + "." + parts[2]},
+	}, nil
+}
+
+type testClient struct {
+	client.Client
+}
+
+// Call echoes the request back, failing for the Say.Fail endpoint.
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if req.Endpoint() == "Say.Fail" {
+		return errors.BadRequest("greeter", "missing name")
+	}
+
+	*rsp.(*json.RawMessage) = *req.Body().(*json.RawMessage)
+
+	return nil
+}
+
+func TestJSONRPC(t *testing.T) {
+	h := NewHandler(
+		handler.WithClient(&testClient{Client: client.NewClient()}),
+		handler.WithRouter(&testRouter{}),
+	)
+
+	testData := []struct {
+		body string
+		code int
+		want string
+	}{
+		{
+			body: `{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{"name":"john"},"id":1}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","result":{"name":"john"},"id":1}`,
+		},
+		{
+			body: `{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":[{"name":"john"}],"id":"a"}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","result":{"name":"john"},"id":"a"}`,
+		},
+		{
+			body: `{"jsonrpc":"2.0","method":"greeter.Say.Fail","id":2}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"missing name","data":{"id":"greeter","code":400,"detail":"missing name","status":"Bad Request"}},"id":2}`,
+		},
+		{
+			body: `{"jsonrpc":"2.0","method":"foo.Bar","id":3}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":3}`,
+		},
+		{
+			body: `{"jsonrpc":"2.0","method"`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`,
+		},
+		{
+			body: `{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{}}`,
+			code: http.StatusNoContent,
+		},
+		{
+			body: `[{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{"a":1},"id":1},` +
+				`{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{}},` +
+				`1,` +
+				`{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{"b":2},"id":2}]`,
+			code: http.StatusOK,
+			want: `[{"jsonrpc":"2.0","result":{"a":1},"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null},` +
+				`{"jsonrpc":"2.0","result":{"b":2},"id":2}]`,
+		},
+		{
+			body: `[{"jsonrpc":"2.0","method":"greeter.Say.Hello"}]`,
+			code: http.StatusNoContent,
+		},
+		{
+			body: `[]`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`,
+		},
+	}
+
+	for _, d := range testData {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(d.body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != d.code {
+			t.Fatalf("%s: expected status %d got %d", d.body, d.code, w.Code)
+		}
+
+		if got := w.Body.String(); got != d.want {
+			t.Fatalf("%s: expected %s got %s", d.body, d.want, got)
+		}
+	}
+}
+
+type countClient struct {
+	client.Client
+
+	sync.Mutex
+	active, max int
+}
+
+// Call records the most calls in flight at once.
+func (c *countClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.Lock()
+	c.active++
+	if c.active > c.max {
+		c.max = c.active
+	}
+	c.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	c.Lock()
+	c.active--
+	c.Unlock()
+
+	*rsp.(*json.RawMessage) = json.RawMessage(`{}`)
+
+	return nil
+}
+
+func TestBatchLimits(t *testing.T) {
+	c := &countClient{Client: client.NewClient()}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+	)
+
+	batch := func(n int) string {
+		calls := make([]string, n)
+		for i := range calls {
+			calls[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":"greeter.Say.Hello","id":%d}`, i)
+		}
+
+		return "[" + strings.Join(calls, ",") + "]"
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(batch(DefaultMaxBatch+1))))
+
+	if want := `{"jsonrpc":"2.0","error":{"code":-32600,"message":"batch too large"},"id":null}`; w.Body.String() != want {
+		t.Fatalf("expected %s got %s", want, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(batch(DefaultMaxBatch))))
+
+	var rsps []Response
+	if err := json.Unmarshal(w.Body.Bytes(), &rsps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rsps) != DefaultMaxBatch {
+		t.Fatalf("expected %d responses got %d", DefaultMaxBatch, len(rsps))
+	}
+
+	if c.max > DefaultBatchConcurrency {
+		t.Fatalf("expected at most %d calls at once got %d", DefaultBatchConcurrency, c.max)
+	}
+}
+
+// nodeClient records the node the strategy of the call selects.
+type nodeClient struct {
+	testClient
+
+	node *registry.Node
+}
+
+func (c *nodeClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	var co client.CallOptions
+	for _, o := range opts {
+		o(&co)
+	}
+
+	var so selector.SelectOptions
+	for _, o := range co.SelectOptions {
+		o(&so)
+	}
+
+	c.node, _ = so.Strategy(nil)()
+
+	return c.testClient.Call(ctx, req, rsp, opts...)
+}
+
+func TestStrategy(t *testing.T) {
+	c := &nodeClient{testClient: testClient{Client: client.NewClient()}}
+
+	h := NewHandler(
+		handler.WithClient(c),
+		handler.WithRouter(&testRouter{}),
+		handler.WithStrategy(func([]*registry.Service) selector.Next {
+			return func() (*registry.Node, error) {
+				return &registry.Node{Id: "picked"}, nil
+			}
+		}),
+	)
+
+	body := `{"jsonrpc":"2.0","method":"greeter.Say.Hello","params":{},"id":1}`
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+
+	if c.node == nil || c.node.Id != "picked" {
+		t.Fatalf("Expected the configured strategy to pick the node got %v", c.node)
+	}
+}
@@ -1,14 +1,38 @@
 package handler
 
 import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	api "go-micro.org/v5/api/proto"
 	"go-micro.org/v5/api/router"
 	"go-micro.org/v5/client"
 	"go-micro.org/v5/logger"
+	"go-micro.org/v5/selector"
 )
 
 var (
 	// DefaultMaxRecvSize is 10MiB.
 	DefaultMaxRecvSize int64 = 1024 * 1024 * 100
+
+	// DefaultRequestIDHeader carries the id of each request.
+	DefaultRequestIDHeader = "X-Request-Id"
+
+	// DefaultContentType is the content type of responses
+	// and errors whose type the backend doesn't declare.
+	DefaultContentType = "application/json"
+
+	// DefaultCoalesceHeaders vary the key of coalesced and etag cached
+	// requests, identity headers so responses aren't shared between users.
+	DefaultCoalesceHeaders = []string{
+		"Authorization",
+		"Cookie",
+		"Accept",
+		"Accept-Language",
+	}
 )
 
 // Options is the list of api Options.
@@ -18,15 +42,96 @@ type Options struct {
 	Logger      logger.Logger
 	Namespace   string
 	MaxRecvSize int64
+	// ContentTypes offered for negotiation when the backend
+	// does not declare the response content type
+	ContentTypes []string
+	// TrustedProxies are the upstream networks whose forwarding
+	// headers are kept, all if empty. The rate limiter only
+	// trusts them if set.
+	TrustedProxies []*net.IPNet
+	// AccessLogger logs requests, disabled if nil
+	AccessLogger     logger.Logger
+	AccessLogRedact  RedactFunc
+	AccessLogVerbose bool
+	// VersionWeights split traffic between service versions
+	VersionWeights map[string]int
+	// Retries is the number of other nodes an idempotent
+	// request is retried against on connection failure
+	Retries   int
+	RetryPost bool
+	// Strategy selects the node each request is sent
+	// to by the api, http, grpcweb and jsonrpc handlers
+	Strategy selector.Strategy
+	// RequestTransforms and ResponseTransforms adapt the
+	// messages of the api handler in the order added
+	RequestTransforms  []RequestTransform
+	ResponseTransforms []ResponseTransform
+	// DefaultRoute serves requests the router doesn't match
+	DefaultRoute *router.Route
+	// RouteHeaders exposes the resolved route in
+	// the X-Micro-* response headers
+	RouteHeaders bool
+	// RequestIDHeader is the header the request id is read
+	// from and echoed in, DefaultRequestIDHeader if empty
+	RequestIDHeader string
+	// Coalesce shares a single backend call between
+	// concurrent identical GET and HEAD requests
+	Coalesce bool
+	// CoalesceHeaders vary the key of coalesced requests,
+	// DefaultCoalesceHeaders and those added by WithCoalesceHeaders
+	CoalesceHeaders []string
+	// DefaultContentType of responses without one,
+	// none is set if empty
+	DefaultContentType string
+	// Metrics the api handler registers its
+	// request metrics with, disabled if nil
+	Metrics prometheus.Registerer
+	// RateLimiter limits the requests of each client
+	// by RateLimitKey, disabled if nil
+	RateLimiter  RateLimiter
+	RateLimitKey RateLimitKey
+	// MaxConcurrent bounds the requests the http
+	// handler proxies at once, unlimited if zero
+	MaxConcurrent int
+	// UpstreamHeader exposes the node the http handler
+	// proxied the request to in X-Micro-Upstream
+	UpstreamHeader bool
+	// ContextFuncs add values to the context
+	// of the backend call in the order added
+	ContextFuncs []ContextFunc
+	// ETagTTL is how long the api handler answers
+	// revalidations of an etag, disabled if zero
+	ETagTTL time.Duration
+	// CORS is the cross-origin policy of the
+	// api and http handlers, disabled if nil
+	CORS *CORSConfig
+	// ErrorEncoder writes the error responses of the
+	// api handler, the errors are written as is if nil
+	ErrorEncoder ErrorEncoder
 }
 
 // Option is a api Option.
 type Option func(o *Options)
 
+// RequestTransform modifies the request the api handler sends, e.g. to
+// unwrap an envelope or inject a tenant id.
+type RequestTransform func(*http.Request, *api.Request) error
+
+// ResponseTransform modifies the backend response before it's written.
+type ResponseTransform func(*http.Request, *api.Response) error
+
+// ErrorEncoder writes the error response of a request, e.g. to wrap the
+// error in an envelope. The error is a parsed *errors.Error with the Code
+// of the backend, which is the status it's written with by default if
+// it's a valid HTTP status, 100 to 599, and 500 otherwise.
+type ErrorEncoder func(w http.ResponseWriter, r *http.Request, err error)
+
 // NewOptions fills in the blanks.
 func NewOptions(opts ...Option) Options {
 	options := Options{
-		Logger: logger.DefaultLogger,
+		Logger:             logger.DefaultLogger,
+		DefaultContentType: DefaultContentType,
+		CoalesceHeaders:    append([]string(nil), DefaultCoalesceHeaders...),
 	}
 
 	for _, o := range opts {
@@ -37,6 +142,10 @@ func NewOptions(opts ...Option) Options {
 		WithClient(client.DefaultClient)(&options)
 	}
 
+	if len(options.RequestIDHeader) == 0 {
+		options.RequestIDHeader = DefaultRequestIDHeader
+	}
+
 	if options.MaxRecvSize == 0 {
 		options.MaxRecvSize = DefaultMaxRecvSize
 	}
@@ -45,6 +154,15 @@ func NewOptions(opts ...Option) Options {
 		options.Logger = logger.LoggerOrDefault(options.Logger)
 	}
 
+	if c := options.CORS; c != nil {
+		if err := c.Validate(); err != nil {
+			options.Logger.Logf(logger.ErrorLevel, "handler: invalid CORS config: %v", err)
+			cors := *c
+			cors.AllowCredentials = false
+			options.CORS = &cors
+		}
+	}
+
 	return options
 }
 
@@ -69,16 +187,278 @@ func WithClient(c client.Client) Option {
 	}
 }
 
-// WithMaxRecvSize specifies max body size.
+// WithMaxRecvSize specifies max body size. The uploads of client
+// streaming endpoints are only limited by the MaxRecvSize of the route.
 func WithMaxRecvSize(size int64) Option {
 	return func(o *Options) {
 		o.MaxRecvSize = size
 	}
 }
 
+// WithContentTypes specifies the response content types offered for
+// negotiation against the Accept header when the backend declares none.
+// The body is json then, so only json types such as application/json or
+// application/hal+json are offered.
+func WithContentTypes(ct ...string) Option {
+	return func(o *Options) {
+		o.ContentTypes = append(o.ContentTypes, ct...)
+	}
+}
+
+// WithTrustedProxies specifies the upstream CIDRs or IPs whose existing
+// X-Forwarded-For chain is trusted. Headers from other clients are dropped.
+// Without trusted proxies the chain of every client is kept. Invalid
+// entries are logged and trust no upstream, use NewTrustedProxies to
+// handle the error instead.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *Options) {
+		for _, c := range cidrs {
+			nets, err := ParseCIDRs(c)
+			if err != nil {
+				logger.LoggerOrDefault(o.Logger).Logf(logger.ErrorLevel, "handler: invalid trusted proxy: %v", err)
+				// a network matching no address so the headers are still dropped
+				nets = []*net.IPNet{{}}
+			}
+
+			o.TrustedProxies = append(o.TrustedProxies, nets...)
+		}
+	}
+}
+
+// NewTrustedProxies returns the WithTrustedProxies option of the CIDRs or
+// IPs, or an error for the first invalid entry.
+func NewTrustedProxies(cidrs ...string) (Option, error) {
+	nets, err := ParseCIDRs(cidrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(o *Options) {
+		o.TrustedProxies = append(o.TrustedProxies, nets...)
+	}, nil
+}
+
+// WithAccessLog enables logging of each request method, path, route,
+// status, size and latency. The RedactedHeaders are always scrubbed and
+// the redact func, if set, is called to scrub the rest of the entry
+// before it's logged. Access logging is disabled by default.
+func WithAccessLog(l logger.Logger, fn RedactFunc) Option {
+	return func(o *Options) {
+		o.AccessLogger = l
+		o.AccessLogRedact = fn
+	}
+}
+
+// WithAccessLogVerbose includes the request headers and the request and
+// response bodies in the access log. Response bodies are buffered.
+func WithAccessLogVerbose(b bool) Option {
+	return func(o *Options) {
+		o.AccessLogVerbose = b
+	}
+}
+
+// WithVersionWeights splits traffic between service versions by weight,
+// overriding the "weight" service metadata. Nodes are selected at random
+// when no weights are set.
+func WithVersionWeights(w map[string]int) Option {
+	return func(o *Options) {
+		o.VersionWeights = w
+	}
+}
+
 // WithLogger specifies the logger.
 func WithLogger(l logger.Logger) Option {
 	return func(o *Options) {
 		o.Logger = l
 	}
 }
+
+// WithRetries specifies how many other nodes an idempotent request is
+// retried against when the connection to a node fails.
+func WithRetries(n int) Option {
+	return func(o *Options) {
+		o.Retries = n
+	}
+}
+
+// WithRetryPost marks POST requests as idempotent so they're also
+// retried, use only when the backends handle duplicate requests.
+func WithRetryPost(b bool) Option {
+	return func(o *Options) {
+		o.RetryPost = b
+	}
+}
+
+// WithStrategy sets the selector strategy the api, http, grpcweb and
+// jsonrpc handlers use to pick the node for each request, e.g.
+// selector.RoundRobin or selector.NodeWeighted. It takes precedence over
+// WithVersionWeights.
+func WithStrategy(s selector.Strategy) Option {
+	return func(o *Options) {
+		o.Strategy = s
+	}
+}
+
+// WithRequestIDHeader sets the header the api handler reads the request
+// id from, generating one if missing, and echoes in the response.
+func WithRequestIDHeader(name string) Option {
+	return func(o *Options) {
+		o.RequestIDHeader = name
+	}
+}
+
+// WithRouteHeaders sets the X-Micro-Service, X-Micro-Endpoint and
+// X-Micro-Version response headers of the api handler to the route the
+// request was sent to, for debugging. Headers set by the backend are
+// kept. This is off by default to avoid exposing the topology.
+func WithRouteHeaders(b bool) Option {
+	return func(o *Options) {
+		o.RouteHeaders = b
+	}
+}
+
+// WithDefaultRoute sends requests the router can't match to the service
+// endpoint, e.g. a legacy monolith, rather than failing with a 500. The
+// nodes are looked up by the client's selector.
+func WithDefaultRoute(service, endpoint string) Option {
+	return func(o *Options) {
+		o.DefaultRoute = &router.Route{
+			Service: service,
+			Endpoint: &router.Endpoint{
+				Name: endpoint,
+			},
+		}
+	}
+}
+
+// WithRequestTransform adds a hook run by the api handler after the http
+// request is converted and before the call. An error fails the request
+// with a 400.
+func WithRequestTransform(fn RequestTransform) Option {
+	return func(o *Options) {
+		o.RequestTransforms = append(o.RequestTransforms, fn)
+	}
+}
+
+// WithResponseTransform adds a hook run by the api handler on the backend
+// response before it's written. An error fails the request with a 500.
+func WithResponseTransform(fn ResponseTransform) Option {
+	return func(o *Options) {
+		o.ResponseTransforms = append(o.ResponseTransforms, fn)
+	}
+}
+
+// WithCoalesce makes the api handler send concurrent identical GET and
+// HEAD requests to the backend once, sharing the response. Requests are
+// identical if their host, path, query, body and CoalesceHeaders match.
+// The shared call isn't bound to the context of any of the requests and
+// times out after the client's request timeout. Off by default.
+func WithCoalesce(b bool) Option {
+	return func(o *Options) {
+		o.Coalesce = b
+	}
+}
+
+// WithCoalesceHeaders adds headers which vary the response, e.g. a
+// tenant header, to the key of coalesced and etag cached requests.
+func WithCoalesceHeaders(headers ...string) Option {
+	return func(o *Options) {
+		o.CoalesceHeaders = append(o.CoalesceHeaders, headers...)
+	}
+}
+
+// WithDefaultContentType sets the content type of the api handler's
+// responses and errors when the backend declares none and none of the
+// negotiated types match. An empty type leaves the header unset.
+// This defaults to DefaultContentType.
+func WithDefaultContentType(ct string) Option {
+	return func(o *Options) {
+		o.DefaultContentType = ct
+	}
+}
+
+// WithMetrics registers the request count, latency, in-flight and error
+// metrics of the api handler with the registerer. Requests are labeled by
+// the service, endpoint and status rather than the path, errors by whether
+// routing, the backend call or a timeout failed. Disabled by default.
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(o *Options) {
+		o.Metrics = reg
+	}
+}
+
+// WithRateLimit limits the requests of the api and http handlers with the
+// limiter, e.g. NewRateLimiter(10, 20), before they're routed. Requests are
+// limited by the key func, such as RateLimitByHeader("X-Api-Key"), or the
+// client address if it's nil. Exceeding the limit fails with a 429 and a
+// Retry-After header.
+func WithRateLimit(l RateLimiter, key RateLimitKey) Option {
+	return func(o *Options) {
+		o.RateLimiter = l
+		o.RateLimitKey = key
+	}
+}
+
+// WithMaxConcurrent limits the requests each http handler proxies at once,
+// e.g. to bound the upstream connections during a spike. Requests beyond
+// the limit fail with a 503 rather than queueing. Unlimited by default.
+func WithMaxConcurrent(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrent = n
+	}
+}
+
+// WithUpstreamHeader sets the X-Micro-Upstream header of the http handler's
+// responses, including proxy errors, to the address of the node the request
+// was sent to, to identify a failing replica. Off by default to avoid
+// exposing the topology.
+func WithUpstreamHeader(b bool) Option {
+	return func(o *Options) {
+		o.UpstreamHeader = b
+	}
+}
+
+// WithContextFunc adds a hook run on the call context after it's created
+// from the request headers and before the backend is called, so values a
+// middleware stored in the request context reach the backend's wrappers.
+// It applies to the api, rpc, jsonrpc, grpcweb and event handlers.
+func WithContextFunc(fn ContextFunc) Option {
+	return func(o *Options) {
+		o.ContextFuncs = append(o.ContextFuncs, fn)
+	}
+}
+
+// WithETagCache makes the api handler set an ETag on successful GET and
+// HEAD responses, hashing the body if the backend sets none, and answer
+// requests whose If-None-Match matches the last etag of the route with a
+// 304 for ttl without calling the backend. Responses are keyed as for
+// WithCoalesce and those marked no-cache or no-store aren't cached. The
+// backend isn't asked whether the resource changed within ttl, so it
+// bounds how stale a client's copy may be. Disabled by default.
+func WithETagCache(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ETagTTL = ttl
+	}
+}
+
+// WithCORS makes the api and http handlers answer preflight requests
+// before routing and set the Access-Control-Allow-* headers of requests
+// from the allowed origins, replacing those of the backends. It's an
+// alternative to the server's EnableCORS for per handler policies. If
+// the config doesn't Validate, the error is logged and credentials are
+// never allowed.
+func WithCORS(config CORSConfig) Option {
+	return func(o *Options) {
+		o.CORS = &config
+	}
+}
+
+// WithErrorEncoder sets the func the api handler writes its error
+// responses with, including those of routing, rate limiting and failed
+// calls, so they can take any shape or status. Errors after the status
+// of a streaming response is sent are still written in the stream.
+func WithErrorEncoder(fn ErrorEncoder) Option {
+	return func(o *Options) {
+		o.ErrorEncoder = fn
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.org/v5/api/router"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/logger"
+)
+
+// RateLimiter decides whether the requests of a client may proceed. The
+// in-memory limiter returned by NewRateLimiter limits each gateway on its
+// own, implement it with a shared store to limit the gateways together.
+type RateLimiter interface {
+	// Allow takes a token for the key. If there are none it
+	// returns false and how long until one is available.
+	Allow(key string) (bool, time.Duration)
+}
+
+// RateLimitKey returns the key a request is rate limited by. Requests
+// with the same key, including an empty one, share the rate limit.
+type RateLimitKey func(*http.Request) string
+
+// RateLimitByIP keys requests by the client address. The X-Real-IP and
+// X-Forwarded-For headers are only used for requests from the trusted
+// proxies, the address of the connection is used otherwise. Invalid
+// proxies are logged and not trusted, see NewRateLimitByIP.
+func RateLimitByIP(proxies ...string) RateLimitKey {
+	var nets []*net.IPNet
+
+	for _, p := range proxies {
+		n, err := ParseCIDRs(p)
+		if err != nil {
+			logger.Logf(logger.ErrorLevel, "handler: invalid trusted proxy: %v", err)
+			continue
+		}
+
+		nets = append(nets, n...)
+	}
+
+	return func(r *http.Request) string {
+		return clientIP(r, nets)
+	}
+}
+
+// NewRateLimitByIP returns the RateLimitByIP key of the proxies, or an
+// error for the first invalid proxy.
+func NewRateLimitByIP(proxies ...string) (RateLimitKey, error) {
+	nets, err := ParseCIDRs(proxies...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r *http.Request) string {
+		return clientIP(r, nets)
+	}, nil
+}
+
+// RateLimitByHeader keys requests by a header, e.g. an API key.
+func RateLimitByHeader(name string) RateLimitKey {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimitByRoute keys requests by the service and endpoint the router
+// resolves them to, so the paths of a route share its limit. Requests
+// which don't resolve share an empty key.
+func RateLimitByRoute(rt router.Router) RateLimitKey {
+	return func(r *http.Request) string {
+		// routing sets the path variables of the request
+		route, err := rt.Route(r.Clone(r.Context()))
+		if err != nil || route.Endpoint == nil {
+			return ""
+		}
+
+		return route.Service + " " + route.Endpoint.Name
+	}
+}
+
+// clientIP returns the address of the client. The forwarding headers
+// are only used for requests from the trusted networks as they can be
+// spoofed to evade the limit.
+func clientIP(r *http.Request, nets []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !trusted(net.ParseIP(host), nets) {
+		return host
+	}
+
+	if ip := r.Header.Get("X-Real-IP"); len(ip) > 0 {
+		return ip
+	}
+
+	if fwd := r.Header.Get("X-Forwarded-For"); len(fwd) > 0 {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+
+	return host
+}
+
+// Throttled checks the request against the rate limiter, writing a 429
+// with a Retry-After header and returning true if it's exceeded.
+// Requests are keyed by the client address if no key func is set.
+func Throttled(opts Options, w http.ResponseWriter, r *http.Request) bool {
+	if opts.RateLimiter == nil {
+		return false
+	}
+
+	var key string
+	if opts.RateLimitKey != nil {
+		key = opts.RateLimitKey(r)
+	} else {
+		key = clientIP(r, opts.TrustedProxies)
+	}
+
+	ok, wait := opts.RateLimiter.Allow(key)
+	if ok {
+		return false
+	}
+
+	// whole seconds, at least one
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+
+	er := errors.New("go.micro.api", "rate limit exceeded", http.StatusTooManyRequests)
+
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+
+	if opts.ErrorEncoder != nil {
+		opts.ErrorEncoder(w, r, er)
+		return true
+	}
+
+	if len(opts.DefaultContentType) > 0 {
+		w.Header().Set("Content-Type", opts.DefaultContentType)
+	}
+
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(er.Error()))
+
+	return true
+}
+
+// bucket is the token bucket of a key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type memoryLimiter struct {
+	rate  float64
+	burst float64
+
+	sync.Mutex
+	buckets map[string]*bucket
+	// when full buckets were last dropped
+	swept time.Time
+}
+
+// sweep drops the buckets which have refilled, they're
+// equivalent to the new bucket created on the next request.
+func (m *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+
+	m.swept = now
+
+	for k, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*m.rate >= m.burst {
+			delete(m.buckets, k)
+		}
+	}
+}
+
+func (m *memoryLimiter) Allow(key string) (bool, time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: m.burst, last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(m.burst, b.tokens+now.Sub(b.last).Seconds()*m.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if m.rate <= 0 {
+		return false, time.Minute
+	}
+
+	return false, time.Duration((1 - b.tokens) / m.rate * float64(time.Second))
+}
+
+// NewRateLimiter returns an in-memory token bucket rate limiter which
+// allows each key rate requests per second on average and bursts of up
+// to burst requests.
+func NewRateLimiter(rate float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &memoryLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-micro.org/v5/api/router"
+)
+
+func TestThrottled(t *testing.T) {
+	opts := NewOptions(WithRateLimit(NewRateLimiter(1, 2), nil))
+
+	request := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = remote
+
+		w := httptest.NewRecorder()
+		if !Throttled(opts, w, r) {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		return w
+	}
+
+	// the burst is allowed
+	for i := 0; i < 2; i++ {
+		if w := request("10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to be allowed got %d", i, w.Code)
+		}
+	}
+
+	w := request("10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 got %d", w.Code)
+	}
+
+	if ra := w.Header().Get("Retry-After"); ra != "1" {
+		t.Fatalf("Expected Retry-After 1 got %q", ra)
+	}
+
+	// other clients have their own limit
+	if w := request("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("Expected another client to be allowed got %d", w.Code)
+	}
+}
+
+// pathRouter routes the paths to their endpoints.
+type pathRouter struct {
+	router.Router
+
+	routes map[string]string
+}
+
+func (p *pathRouter) Route(r *http.Request) (*router.Route, error) {
+	ep, ok := p.routes[r.URL.Path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	r.URL.Path = "/routed"
+
+	return &router.Route{Service: "test", Endpoint: &router.Endpoint{Name: ep}}, nil
+}
+
+func TestRateLimitByRoute(t *testing.T) {
+	key := RateLimitByRoute(&pathRouter{routes: map[string]string{
+		"/foo":  "Foo.Get",
+		"/foo/": "Foo.Get",
+		"/bar":  "Bar.Get",
+	}})
+
+	testData := []struct {
+		path string
+		want string
+	}{
+		// the paths of a route share its key
+		{"/foo", "test Foo.Get"},
+		{"/foo/", "test Foo.Get"},
+		{"/bar", "test Bar.Get"},
+		{"/baz", ""},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", d.path, nil)
+
+		if got := key(r); got != d.want {
+			t.Fatalf("%s: expected key %q got %q", d.path, d.want, got)
+		}
+
+		// the request isn't modified by the router
+		if r.URL.Path != d.path {
+			t.Fatalf("%s: request path changed to %s", d.path, r.URL.Path)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 10.0.0.1")
+	r.Header.Set("X-Api-Key", "secret")
+
+	testData := []struct {
+		key  RateLimitKey
+		want string
+	}{
+		// the forwarding headers are only trusted from the proxies
+		{RateLimitByIP(), "10.0.0.1"},
+		{RateLimitByIP("192.168.0.0/16"), "10.0.0.1"},
+		{RateLimitByIP("10.0.0.0/8"), "1.1.1.1"},
+		// invalid proxies aren't trusted
+		{RateLimitByIP("10.0.0.0/33"), "10.0.0.1"},
+		{RateLimitByHeader("X-Api-Key"), "secret"},
+	}
+
+	for i, d := range testData {
+		if got := d.key(r); got != d.want {
+			t.Fatalf("%d: expected key %s got %s", i, d.want, got)
+		}
+	}
+
+	if _, err := NewRateLimitByIP("10.0.0.0/33"); err == nil {
+		t.Fatal("Expected an error for an invalid proxy")
+	}
+
+	key, err := NewRateLimitByIP("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := key(r); got != "1.1.1.1" {
+		t.Fatalf("Expected key 1.1.1.1 got %s", got)
+	}
+}
@@ -2,6 +2,7 @@
 package rpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -138,6 +139,8 @@ func (h *rpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// merge context with overwrite
 	myContext = metadata.MergeContext(myContext, md, true)
+	// add the values of the context funcs
+	myContext = handler.CallContext(h.opts, myContext, r)
 
 	// set merged context to request
 	*r = *r.Clone(myContext)
@@ -156,6 +159,18 @@ func (h *rpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// create strategy
 	mySelector := selector.WithStrategy(strategy(service.Versions))
 
+	// transcode registered marshalers to json
+	m, transcode := marshaler(contentType)
+	if transcode {
+		if err := transcodeRequest(r, m); err != nil {
+			if werr := writeError(w, r, errors.BadRequest(packageID, err.Error())); werr != nil {
+				logger.Log(log.ErrorLevel, werr)
+			}
+
+			return
+		}
+	}
+
 	// walk the standard call path
 	// get payload
 	br, err := requestPayload(r)
@@ -249,6 +264,17 @@ func (h *rpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if transcode {
+		rsp, err = transcodeResponse(r, rsp, m, contentType)
+		if err != nil {
+			if werr := writeError(w, r, err); werr != nil {
+				logger.Log(log.ErrorLevel, werr)
+			}
+
+			return
+		}
+	}
+
 	// write the response
 	if err := writeResponse(w, r, rsp); err != nil {
 		logger.Log(log.ErrorLevel, err)
@@ -269,6 +295,59 @@ func hasCodec(ct string, codecs []string) bool {
 	return false
 }
 
+// marshaler returns the registered marshaler for a content type
+// not natively supported by the handler.
+func marshaler(ct string) (codec.Marshaler, bool) {
+	if hasCodec(ct, jsonCodecs) || hasCodec(ct, protoCodecs) {
+		return nil, false
+	}
+
+	return codec.GetMarshaler(ct)
+}
+
+// transcodeRequest decodes the request body with the marshaler
+// and replaces it with the json equivalent.
+func transcodeRequest(r *http.Request, m codec.Marshaler) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(b) > 0 {
+		var v interface{}
+		if err := m.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set("Content-Type", "application/json")
+
+	return nil
+}
+
+// transcodeResponse encodes the json response with the marshaler
+// and restores the original content type of the request.
+func transcodeResponse(r *http.Request, rsp []byte, m codec.Marshaler, ct string) ([]byte, error) {
+	r.Header.Set("Content-Type", ct)
+
+	if len(rsp) == 0 {
+		return rsp, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(rsp, &v); err != nil {
+		return nil, err
+	}
+
+	return m.Marshal(v)
+}
+
 // requestPayload takes a *http.Request.
 // If the request is a GET the query string parameters are extracted and marshaled to JSON and the raw bytes are returned.
 // If the request method is a POST the request body is read and returned.
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+)
+
+// Strategy returns the strategy the handlers select the nodes of a route
+// with, the Strategy option or else one splitting traffic between the
+// versions by VersionWeights. The services passed to it are ignored in
+// favour of those of the route, unless the route has none e.g. the
+// default route.
+func Strategy(services []*registry.Service, opts Options) selector.Strategy {
+	st := opts.Strategy
+	if st == nil {
+		st = selector.Weighted(opts.VersionWeights)
+	}
+
+	return func(in []*registry.Service) selector.Next {
+		if len(services) == 0 {
+			return st(in)
+		}
+
+		return st(services)
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"testing"
+
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+)
+
+func TestStrategy(t *testing.T) {
+	services := []*registry.Service{{
+		Name: "foo",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Metadata: map[string]string{"weight": "0"}},
+			{Id: "foo-2", Metadata: map[string]string{"weight": "1"}},
+		},
+	}}
+
+	opts := NewOptions(WithStrategy(selector.NodeWeighted("weight")))
+
+	// the services passed in are ignored
+	next := Strategy(services, opts)(nil)
+
+	for i := 0; i < 10; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if node.Id != "foo-2" {
+			t.Fatalf("Expected foo-2 got %s", node.Id)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package router
 
 import (
 	"errors"
+	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +25,37 @@ func slice(s string) []string {
 	return sl
 }
 
+// joinHeader encodes headers as a sorted list of key=value pairs.
+func joinHeader(h map[string]string) string {
+	hs := make([]string, 0, len(h))
+	for k, v := range h {
+		hs = append(hs, k+"="+v)
+	}
+
+	sort.Strings(hs)
+
+	return strings.Join(hs, ",")
+}
+
+func splitHeader(s string) map[string]string {
+	var h map[string]string
+
+	for _, kv := range slice(s) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if h == nil {
+			h = make(map[string]string)
+		}
+
+		h[strip(parts[0])] = strip(parts[1])
+	}
+
+	return h
+}
+
 // Encode encodes an endpoint to endpoint metadata.
 func Encode(e *Endpoint) map[string]string {
 	if e == nil {
@@ -45,6 +79,14 @@ func Encode(e *Endpoint) map[string]string {
 	set("method", strings.Join(e.Method, ","))
 	set("path", strings.Join(e.Path, ","))
 	set("host", strings.Join(e.Host, ","))
+	set("header", joinHeader(e.Header))
+	set("strip_prefix", e.StripPrefix)
+	set("rewrite", e.Rewrite)
+	set("rewrite_to", e.RewriteTo)
+
+	if e.MaxRecvSize > 0 {
+		set("max_recv_size", strconv.FormatInt(e.MaxRecvSize, 10))
+	}
 
 	return ep
 }
@@ -55,14 +97,49 @@ func Decode(e map[string]string) *Endpoint {
 		return nil
 	}
 
+	// ignore an invalid size, the handler default applies
+	size, _ := strconv.ParseInt(e["max_recv_size"], 10, 64)
+
 	return &Endpoint{
 		Name:        e["endpoint"],
 		Description: e["description"],
 		Method:      slice(e["method"]),
 		Path:        slice(e["path"]),
 		Host:        slice(e["host"]),
+		Header:      splitHeader(e["header"]),
 		Handler:     e["handler"],
+		MaxRecvSize: size,
+		StripPrefix: e["strip_prefix"],
+		Rewrite:     e["rewrite"],
+		RewriteTo:   e["rewrite_to"],
+	}
+}
+
+// SortEndpoints orders the keys of endpoints in the order they're matched,
+// those with more header constraints first so one without any doesn't
+// shadow one which requires a header for the same path. Endpoints are
+// ordered by key otherwise so matching is deterministic.
+func SortEndpoints(keys []string, endpoint func(key string) *Endpoint) {
+	sort.Slice(keys, func(i, j int) bool {
+		hi, hj := len(endpoint(keys[i]).Header), len(endpoint(keys[j]).Header)
+		if hi != hj {
+			return hi > hj
+		}
+
+		return keys[i] < keys[j]
+	})
+}
+
+// MatchHeader reports whether the request header carries all the
+// header values required by an endpoint.
+func MatchHeader(want map[string]string, h http.Header) bool {
+	for k, v := range want {
+		if h.Get(k) != v {
+			return false
+		}
 	}
+
+	return true
 }
 
 // Validate validates an endpoint to guarantee it won't blow up when being served.
@@ -91,6 +168,12 @@ func Validate(e *Endpoint) error {
 		}
 	}
 
+	if len(e.Rewrite) > 0 {
+		if _, err := regexp.CompilePOSIX(e.Rewrite); err != nil {
+			return err
+		}
+	}
+
 	if len(e.Handler) == 0 {
 		return errors.New("invalid handler")
 	}
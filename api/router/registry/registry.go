@@ -36,6 +36,8 @@ type registryRouter struct {
 	eps  map[string]*router.Route
 	// compiled regexp for host and path
 	ceps map[string]*endpoint
+	// order the eps are matched in
+	order []string
 
 	sync.RWMutex
 }
@@ -216,6 +218,15 @@ func (r *registryRouter) store(services []*registry.Service) {
 
 		r.ceps[name] = cep
 	}
+
+	r.order = make([]string, 0, len(r.eps))
+	for name := range r.eps {
+		r.order = append(r.order, name)
+	}
+
+	router.SortEndpoints(r.order, func(name string) *router.Endpoint {
+		return r.eps[name].Endpoint
+	})
 }
 
 // watch for endpoint changes.
@@ -312,7 +323,9 @@ func (r *registryRouter) Endpoint(req *http.Request) (*router.Route, error) {
 
 	// use the first match
 	// TODO: weighted matching
-	for n, endpoint := range r.eps {
+	for _, n := range r.order {
+		endpoint := r.eps[n]
+
 		cep, ok := r.ceps[n]
 		if !ok {
 			continue
@@ -335,7 +348,12 @@ func (r *registryRouter) Endpoint(req *http.Request) (*router.Route, error) {
 
 		logger.Logf(log.DebugLevel, "api method match %s", req.Method)
 
-		// 2. try host
+		// 2. try header
+		if !router.MatchHeader(ep.Header, req.Header) {
+			continue
+		}
+
+		// 3. try host
 		if len(ep.Host) == 0 {
 			hMatch = true
 		} else {
@@ -356,7 +374,7 @@ func (r *registryRouter) Endpoint(req *http.Request) (*router.Route, error) {
 
 		logger.Logf(log.DebugLevel, "api host match %s", req.URL.Host)
 
-		// 3. try path via google.api path matching
+		// 4. try path via google.api path matching
 		for _, pathreg := range cep.pathregs {
 			matches, err := pathreg.Match(path, "")
 			if err != nil {
@@ -384,7 +402,7 @@ func (r *registryRouter) Endpoint(req *http.Request) (*router.Route, error) {
 		}
 
 		if !pMatch {
-			// 4. try path via pcre path matching
+			// 5. try path via pcre path matching
 			for _, pathreg := range cep.pcreregs {
 				if !pathreg.MatchString(req.URL.Path) {
 					logger.Logf(log.DebugLevel, "api pcre path not match %s != %v", path, pathreg)
@@ -445,7 +463,7 @@ func (r *registryRouter) Route(req *http.Request) (*router.Route, error) {
 	// only use endpoint matching when the meta handler is set aka api.Default
 	switch r.opts.Handler {
 	// rpc handlers
-	case "meta", "api", "rpc":
+	case "meta", "api", "rpc", "grpcweb", "jsonrpc":
 		handler := r.opts.Handler
 
 		// set default handler to api
@@ -470,6 +488,7 @@ func (r *registryRouter) Route(req *http.Request) (*router.Route, error) {
 			Endpoint: &router.Endpoint{
 				Name:    ep_name,
 				Handler: handler,
+				Method:  []string{req.Method},
 			},
 			Versions: services,
 		}, nil
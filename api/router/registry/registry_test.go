@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,3 +33,120 @@ func TestStoreRegex(t *testing.T) {
 
 	assert.Len(t, router.ceps["Foobar.foo"].pcreregs, 1)
 }
+
+func TestEndpointMethodHeader(t *testing.T) {
+	endpoint := func(name, method string, md map[string]string) *registry.Endpoint {
+		ep := &registry.Endpoint{
+			Name: name,
+			Metadata: map[string]string{
+				"endpoint": name,
+				"method":   method,
+				"path":     "/foo",
+				"handler":  "rpc",
+			},
+		}
+		for k, v := range md {
+			ep.Metadata[k] = v
+		}
+		return ep
+	}
+
+	router := newRouter()
+	defer router.Stop()
+
+	router.store([]*registry.Service{
+		{
+			Name:    "Foobar",
+			Version: "latest",
+			Endpoints: []*registry.Endpoint{
+				endpoint("Foo.Get", "GET", nil),
+				endpoint("Foo.Create", "POST", map[string]string{"header": "X-Api-Version=1"}),
+				endpoint("Foo.CreateV2", "POST", map[string]string{"header": "X-Api-Version=2"}),
+			},
+		},
+	})
+
+	testData := []struct {
+		method  string
+		version string
+		want    string
+	}{
+		{"GET", "", "Foo.Get"},
+		{"POST", "1", "Foo.Create"},
+		{"POST", "2", "Foo.CreateV2"},
+	}
+
+	for _, d := range testData {
+		req := httptest.NewRequest(d.method, "/foo", nil)
+		if len(d.version) > 0 {
+			req.Header.Set("X-Api-Version", d.version)
+		}
+
+		route, err := router.Endpoint(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, d.want, route.Endpoint.Name)
+	}
+
+	// no header match
+	_, err := router.Endpoint(httptest.NewRequest("POST", "/foo", nil))
+	assert.Error(t, err)
+}
+
+func TestEndpointHeaderPrecedence(t *testing.T) {
+	endpoint := func(name string, md map[string]string) *registry.Endpoint {
+		ep := &registry.Endpoint{
+			Name: name,
+			Metadata: map[string]string{
+				"endpoint": name,
+				"method":   "POST",
+				"path":     "/foo",
+				"handler":  "rpc",
+			},
+		}
+		for k, v := range md {
+			ep.Metadata[k] = v
+		}
+		return ep
+	}
+
+	router := newRouter()
+	defer router.Stop()
+
+	router.store([]*registry.Service{
+		{
+			Name:    "Foobar",
+			Version: "latest",
+			Endpoints: []*registry.Endpoint{
+				endpoint("Foo.Create", nil),
+				endpoint("Foo.CreateV2", map[string]string{"header": "X-Api-Version=2"}),
+				endpoint("Foo.CreateV2Beta", map[string]string{"header": "X-Api-Version=2,X-Beta=1"}),
+			},
+		},
+	})
+
+	// map iteration order differs between lookups
+	for i := 0; i < 100; i++ {
+		for want, hdr := range map[string]map[string]string{
+			"Foo.Create":       nil,
+			"Foo.CreateV2":     {"X-Api-Version": "2"},
+			"Foo.CreateV2Beta": {"X-Api-Version": "2", "X-Beta": "1"},
+		} {
+			req := httptest.NewRequest("POST", "/foo", nil)
+			for k, v := range hdr {
+				req.Header.Set(k, v)
+			}
+
+			route, err := router.Endpoint(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if route.Endpoint.Name != want {
+				t.Fatalf("%d: expected %s got %s", i, want, route.Endpoint.Name)
+			}
+		}
+	}
+}
@@ -42,8 +42,18 @@ type Endpoint struct {
 	Host []string
 	// HTTP Methods e.g GET, POST
 	Method []string
+	// HTTP Headers the request must carry e.g X-Api-Version: 2
+	Header map[string]string
 	// HTTP Path e.g /greeter. Expect POSIX regex
 	Path []string
 	// Stream flag
 	Stream bool
+	// MaxRecvSize overrides the handler max body size when non zero
+	MaxRecvSize int64
+	// StripPrefix is the leading path segments removed before proxying e.g /v1
+	StripPrefix string
+	// Rewrite is a POSIX regex matched against the proxied path e.g ^/v1/(.*)$
+	Rewrite string
+	// RewriteTo replaces the Rewrite matches e.g /$1
+	RewriteTo string
 }
@@ -28,6 +28,8 @@ type Router struct {
 	opts router.Options
 	exit chan bool
 	eps  map[string]*endpoint
+	// order the eps are matched in
+	order []string
 	sync.RWMutex
 }
 
@@ -148,6 +150,7 @@ func (r *Router) Register(route *router.Route) error {
 		pathregs: pathregs,
 		hostregs: hostregs,
 	}
+	r.sort()
 	r.Unlock()
 
 	return nil
@@ -161,11 +164,24 @@ func (r *Router) Deregister(route *router.Route) error {
 
 	r.Lock()
 	delete(r.eps, ep.Name)
+	r.sort()
 	r.Unlock()
 
 	return nil
 }
 
+// sort orders the endpoints for matching. The caller must hold the lock.
+func (r *Router) sort() {
+	r.order = make([]string, 0, len(r.eps))
+	for name := range r.eps {
+		r.order = append(r.order, name)
+	}
+
+	router.SortEndpoints(r.order, func(name string) *router.Endpoint {
+		return r.eps[name].apiep
+	})
+}
+
 func (r *Router) Options() router.Options {
 	return r.opts
 }
@@ -219,12 +235,17 @@ func (r *Router) Endpoint(req *http.Request) (*router.Route, error) {
 	svc := &router.Route{
 		Service: epf[0],
 		Endpoint: &router.Endpoint{
-			Name:    strings.Join(epf[1:], "."),
-			Handler: "rpc",
-			Host:    myEndpoint.apiep.Host,
-			Method:  myEndpoint.apiep.Method,
-			Path:    myEndpoint.apiep.Path,
-			Stream:  myEndpoint.apiep.Stream,
+			Name:        strings.Join(epf[1:], "."),
+			Handler:     "rpc",
+			Host:        myEndpoint.apiep.Host,
+			Method:      myEndpoint.apiep.Method,
+			Header:      myEndpoint.apiep.Header,
+			Path:        myEndpoint.apiep.Path,
+			Stream:      myEndpoint.apiep.Stream,
+			MaxRecvSize: myEndpoint.apiep.MaxRecvSize,
+			StripPrefix: myEndpoint.apiep.StripPrefix,
+			Rewrite:     myEndpoint.apiep.Rewrite,
+			RewriteTo:   myEndpoint.apiep.RewriteTo,
 		},
 		Versions: services,
 	}
@@ -251,7 +272,9 @@ func (r *Router) endpoint(req *http.Request) (*endpoint, error) {
 
 	// use the first match
 	// TODO: weighted matching
-	for _, myEndpoint := range r.eps {
+	for _, name := range r.order {
+		myEndpoint := r.eps[name]
+
 		var mMatch, hMatch, pMatch bool
 
 		// 1. try method
@@ -267,7 +290,12 @@ func (r *Router) endpoint(req *http.Request) (*endpoint, error) {
 		}
 		logger.Logf(log.DebugLevel, "api method match %s", req.Method)
 
-		// 2. try host
+		// 2. try header
+		if !router.MatchHeader(myEndpoint.apiep.Header, req.Header) {
+			continue
+		}
+
+		// 3. try host
 		if len(myEndpoint.apiep.Host) == 0 {
 			hMatch = true
 		} else {
@@ -288,7 +316,7 @@ func (r *Router) endpoint(req *http.Request) (*endpoint, error) {
 
 		logger.Logf(log.DebugLevel, "api host match %s", req.URL.Host)
 
-		// 3. try google.api path
+		// 4. try google.api path
 		for _, pathreg := range myEndpoint.pathregs {
 			matches, err := pathreg.Match(path, "")
 			if err != nil {
@@ -316,7 +344,7 @@ func (r *Router) endpoint(req *http.Request) (*endpoint, error) {
 		}
 
 		if !pMatch {
-			// 4. try path via pcre path matching
+			// 5. try path via pcre path matching
 			for _, pathreg := range myEndpoint.pcreregs {
 				if !pathreg.MatchString(req.URL.Path) {
 					logger.Logf(log.DebugLevel, "api pcre path not match %s != %v", req.URL.Path, pathreg)
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"net"
+	"time"
 )
 
 var (
@@ -21,6 +22,27 @@ type Provider interface {
 	TLSConfig(...string) (*tls.Config, error)
 }
 
+// CertInfo describes a certificate of a provider.
+type CertInfo struct {
+	// Host is the name the certificate was requested for
+	Host      string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// StatusProvider is implemented by providers which report the status of
+// their certificates, e.g. for expiry alerts:
+//
+//	if sp, ok := p.(acme.StatusProvider); ok {
+//		certs, err := sp.CertStatus()
+//		...
+//	}
+type StatusProvider interface {
+	// CertStatus returns the certificates of the provider's hosts
+	CertStatus() ([]CertInfo, error)
+}
+
 // The Let's Encrypt ACME endpoints.
 const (
 	LetsEncryptStagingCA    = "https://acme-staging-v02.api.letsencrypt.org/directory"
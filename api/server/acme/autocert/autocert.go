@@ -1,47 +1,158 @@
 // Package autocert is the ACME provider from golang.org/x/crypto/acme/autocert
-// This provider does not take any config.
+// This provider takes the acme.Cache, acme.CA, acme.ChallengeProvider,
+// acme.Logger, acme.OCSPStapling, acme.MustStaple and acme.Timeout
+// options. The CA only applies to the DNS challenge.
 package autocert
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net"
+	"net/http"
 	"os"
+	"sync"
 
 	"go-micro.org/v5/api/server/acme"
 	log "go-micro.org/v5/logger"
+	xacme "golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
 // autoCertACME is the ACME provider from golang.org/x/crypto/acme/autocert.
 type autocertProvider struct {
 	logger log.Logger
+	opts   acme.Options
+
+	sync.Mutex
+	// the cache and hosts of the managers
+	cache autocert.Cache
+	hosts []string
+	// the certificates served by host
+	certs map[string]*x509.Certificate
 }
 
-// Listen implements acme.Provider.
+// Listen implements acme.Provider. With a challenge provider it listens
+// on :443 with the config of TLSConfig.
 func (a *autocertProvider) Listen(hosts ...string) (net.Listener, error) {
-	return autocert.NewListener(hosts...), nil
+	if a.opts.ChallengeProvider == nil {
+		return autocert.NewListener(hosts...), nil
+	}
+	config, err := a.TLSConfig(hosts...)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen("tcp", ":443", config)
+}
+
+// newCache returns the acme.Cache option, or a cache in the
+// user's cache directory if it's not an autocert.Cache.
+func (a *autocertProvider) newCache() (autocert.Cache, error) {
+	if c, ok := a.opts.Cache.(autocert.Cache); ok {
+		return c, nil
+	}
+
+	dir := cacheDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return autocert.DirCache(dir), nil
 }
 
-// TLSConfig returns a new tls config.
+// TLSConfig returns a new tls config. Certificates are obtained, renewed
+// and stapled with their OCSP response by its GetCertificate, which must
+// be kept when the config is modified. Responses are fetched during the
+// first handshakes of a certificate and refreshed in the background
+// halfway through their validity, an old response is stapled until it
+// expires if the responder fails. Certificates of CAs without OCSP are
+// served without a response.
+//
+// With the acme.ChallengeProvider option certificates are obtained from
+// acme.CA, Let's Encrypt by default, with the dns-01 challenge, solved by
+// the provider creating the TXT record for the domain. Only this challenge
+// allows wildcard hosts e.g. *.example.com, whose certificate is served
+// for the subdomains. The hosts are required as names aren't issued on
+// demand.
 func (a *autocertProvider) TLSConfig(hosts ...string) (*tls.Config, error) {
 	logger := log.LoggerOrDefault(a.logger)
-	// create a new manager
-	m := &autocert.Manager{
-		Prompt: autocert.AcceptTOS,
+	client := http.DefaultClient
+	if a.opts.Timeout > 0 {
+		client = &http.Client{Timeout: a.opts.Timeout}
 	}
-	if len(hosts) > 0 {
-		m.HostPolicy = autocert.HostWhitelist(hosts...)
+	c, err := a.newCache()
+	if err != nil {
+		logger.Logf(log.WarnLevel, "autocert not using a cache: %v", err)
 	}
-	dir := cacheDir()
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		logger.Logf(log.InfoLevel, "warning: autocert not using a cache: %v", err)
+	a.track(c, hosts)
+
+	var config *tls.Config
+	if p := a.opts.ChallengeProvider; p != nil {
+		if len(hosts) == 0 {
+			return nil, errors.New("acme/autocert: the hosts are required for the DNS challenge")
+		}
+		dm := newDNSManager(p, &xacme.Client{HTTPClient: client, DirectoryURL: a.opts.CA}, c, hosts, a.opts.MustStaple, logger)
+		config = &tls.Config{
+			GetCertificate: dm.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
 	} else {
-		m.Cache = autocert.DirCache(dir)
+		// create a new manager
+		m := &autocert.Manager{
+			Prompt: autocert.AcceptTOS,
+			Cache:  c,
+		}
+		if a.opts.Timeout > 0 {
+			m.Client = &xacme.Client{HTTPClient: client}
+		}
+		if a.opts.MustStaple {
+			m.ExtraExtensions = append(m.ExtraExtensions, mustStaple)
+		}
+		if len(hosts) > 0 {
+			m.HostPolicy = autocert.HostWhitelist(hosts...)
+		}
+		config = m.TLSConfig()
 	}
-	return m.TLSConfig(), nil
+
+	var st *stapler
+	if a.opts.OCSPStapling {
+		stClient := client
+		if a.opts.Timeout <= 0 {
+			stClient = &http.Client{Timeout: DefaultOCSPTimeout}
+		}
+		st = newStapler(stClient, logger)
+	}
+
+	// log failures to obtain or renew certificates
+	getCertificate := config.GetCertificate
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := getCertificate(hello)
+		if err != nil {
+			logger.Logf(log.ErrorLevel, "autocert failed to get certificate for %q: %v", hello.ServerName, err)
+			return nil, err
+		}
+		a.served(hello, cert)
+		if st != nil {
+			cert = st.staple(hello.Context(), cert)
+		}
+		return cert, nil
+	}
+
+	return config, nil
 }
 
-// New returns an autocert acme.Provider.
-func NewProvider() acme.Provider {
-	return &autocertProvider{}
+// NewProvider returns an autocert acme.Provider. Logs go to the
+// acme.Logger option, or the default logger if it isn't set. OCSP
+// stapling is off unless enabled by acme.OCSPStapling or acme.MustStaple.
+func NewProvider(opts ...acme.Option) acme.Provider {
+	var options acme.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &autocertProvider{
+		logger: options.Logger,
+		opts:   options,
+		certs:  make(map[string]*x509.Certificate),
+	}
 }
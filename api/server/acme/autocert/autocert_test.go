@@ -1,7 +1,22 @@
 package autocert
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
 	"testing"
+	"time"
+
+	"go-micro.org/v5/api/server/acme"
+	log "go-micro.org/v5/logger"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func TestAutocert(t *testing.T) {
@@ -14,3 +29,120 @@ func TestAutocert(t *testing.T) {
 	// 	t.Error(err.Error())
 	// }
 }
+
+func TestAutocertLogger(t *testing.T) {
+	l := log.NewLogger()
+
+	p := NewProvider(acme.Logger(l)).(*autocertProvider)
+	if p.logger != l {
+		t.Error("NewProvider() didn't use the logger option")
+	}
+
+	if p := NewProvider().(*autocertProvider); p.logger != nil {
+		t.Error("NewProvider() set a logger without the option")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+
+	if _, err := c.Get(ctx, "foo"); err != autocert.ErrCacheMiss {
+		t.Fatalf("Expected a cache miss got %v", err)
+	}
+
+	if err := c.Put(ctx, "foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := c.Get(ctx, "foo"); err != nil || string(b) != "bar" {
+		t.Fatalf("Expected bar got %q %v", b, err)
+	}
+
+	if err := c.Delete(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get(ctx, "foo"); err != autocert.ErrCacheMiss {
+		t.Fatalf("Expected a cache miss got %v", err)
+	}
+
+	// the provider doesn't touch the filesystem with the cache
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+
+	p := NewProvider(acme.Cache(c)).(*autocertProvider)
+
+	if pc, err := p.newCache(); err != nil || pc != c {
+		t.Fatalf("Expected the memory cache got %v %v", pc, err)
+	}
+
+	if _, err := p.TLSConfig("example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(cacheDir()); !os.IsNotExist(err) {
+		t.Fatalf("Expected no cache directory got %v", err)
+	}
+}
+
+func TestCertStatus(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		Issuer:       pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{"example.com"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key and chain as autocert caches them
+	var data bytes.Buffer
+	pem.Encode(&data, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pem.Encode(&data, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	c := NewMemoryCache()
+	c.Put(context.Background(), "example.com", data.Bytes())
+
+	p := NewProvider(acme.Cache(c))
+
+	sp, ok := p.(acme.StatusProvider)
+	if !ok {
+		t.Fatal("Expected the provider to report its status")
+	}
+
+	if _, err := p.TLSConfig("example.com", "other.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := sp.CertStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 certificate got %d", len(certs))
+	}
+
+	if certs[0].Host != "example.com" || !certs[0].NotAfter.Equal(notAfter) {
+		t.Fatalf("Unexpected certificate %+v", certs[0])
+	}
+}
@@ -1,11 +1,65 @@
 package autocert
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/acme/autocert"
 )
 
+// memoryCache is an autocert.Cache which keeps the data in memory.
+type memoryCache struct {
+	sync.RWMutex
+	data map[string][]byte
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	b, ok := m.data[key]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return append([]byte(nil), b...), nil
+}
+
+func (m *memoryCache) Put(ctx context.Context, key string, data []byte) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.data[key] = append([]byte(nil), data...)
+
+	return nil
+}
+
+func (m *memoryCache) Delete(ctx context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.data, key)
+
+	return nil
+}
+
+// NewMemoryCache returns an autocert.Cache which keeps the account key
+// and certificates in memory, e.g. for tests. Pass it, or any other
+// autocert.Cache, with the acme.Cache option:
+//
+//	p := autocert.NewProvider(acme.Cache(autocert.NewMemoryCache()))
+//
+// Certificates are lost on restart so don't use it in production, the
+// CA's rate limits are quickly reached.
+func NewMemoryCache() autocert.Cache {
+	return &memoryCache{
+		data: make(map[string][]byte),
+	}
+}
+
 func homeDir() string {
 	if runtime.GOOS == "windows" {
 		return os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
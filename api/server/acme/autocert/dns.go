@@ -0,0 +1,512 @@
+package autocert
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge"
+	log "go-micro.org/v5/logger"
+	xacme "golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// DefaultRenewBefore is how long before they expire
+	// certificates obtained with the DNS challenge are renewed.
+	DefaultRenewBefore = 30 * 24 * time.Hour
+
+	// DefaultPropagationTimeout is how long to wait for the challenge
+	// record to be visible if the challenge provider doesn't set it.
+	DefaultPropagationTimeout = 60 * time.Second
+
+	// DefaultObtainBackoff is how long handshakes fail without another
+	// attempt after a certificate couldn't be obtained. It doubles with
+	// each consecutive failure up to an hour.
+	DefaultObtainBackoff = time.Minute
+
+	// accountKey is the cache key of the ACME account key,
+	// the same as the autocert.Manager's.
+	accountKey = "acme_account+key"
+
+	// lookupTXT resolves the challenge records.
+	lookupTXT = net.DefaultResolver.LookupTXT
+)
+
+// dnsManager obtains and renews certificates with the dns-01 challenge,
+// which the autocert.Manager doesn't support. The certificates of
+// wildcard hosts e.g. *.example.com are served for their subdomains.
+type dnsManager struct {
+	provider challenge.Provider
+	client   *xacme.Client
+	// cache is optional
+	cache      autocert.Cache
+	hosts      []string
+	mustStaple bool
+	logger     log.Logger
+
+	sync.Mutex
+	// certificates by host
+	certs map[string]*tls.Certificate
+	// when a renewal may next be attempted by host
+	retry map[string]time.Time
+	// the last failures to obtain a certificate by host
+	failures map[string]*failure
+
+	// regMu guards the registration of the account
+	regMu      sync.Mutex
+	registered bool
+
+	// group obtains the certificate of a host once
+	group singleflight.Group
+}
+
+// failure is the last of consecutive failures to obtain a certificate.
+type failure struct {
+	err   error
+	at    time.Time
+	count int
+}
+
+// backoff returns how long after the failure another attempt is made.
+func (f *failure) backoff() time.Duration {
+	d := DefaultObtainBackoff
+	for i := 1; i < f.count && d < time.Hour; i++ {
+		d *= 2
+	}
+
+	if d > time.Hour {
+		d = time.Hour
+	}
+
+	return d
+}
+
+// match returns the configured host the name is served by.
+func (m *dnsManager) match(name string) (string, bool) {
+	for _, h := range m.hosts {
+		if h == name {
+			return h, true
+		}
+	}
+
+	// a wildcard covers a single label
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		wildcard := "*" + name[i:]
+
+		for _, h := range m.hosts {
+			if h == wildcard {
+				return h, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// GetCertificate returns the certificate of the host matching the server
+// name, obtaining it on the first request and renewing it in the
+// background once it's due.
+func (m *dnsManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+
+	host, ok := m.match(name)
+	if !ok {
+		return nil, fmt.Errorf("acme/autocert: host %q not configured", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cert := m.cached(ctx, host)
+	if cert != nil && time.Until(cert.Leaf.NotAfter) > DefaultRenewBefore {
+		return cert, nil
+	}
+
+	// serve the current certificate while it's renewed
+	if cert != nil && time.Now().Before(cert.Leaf.NotAfter) {
+		m.Lock()
+		due := time.Now().After(m.retry[host])
+		if due {
+			// at most one attempt an hour
+			m.retry[host] = time.Now().Add(time.Hour)
+		}
+		m.Unlock()
+
+		if due {
+			go m.renew(host)
+		}
+
+		return cert, nil
+	}
+
+	// fail fast after a failure rather than hitting the CA's rate limits
+	m.Lock()
+	f := m.failures[host]
+	m.Unlock()
+
+	if f != nil && time.Since(f.at) < f.backoff() {
+		return nil, f.err
+	}
+
+	return m.obtainOnce(ctx, host)
+}
+
+// cached returns the certificate of the host from memory or the cache.
+func (m *dnsManager) cached(ctx context.Context, host string) *tls.Certificate {
+	m.Lock()
+	cert := m.certs[host]
+	m.Unlock()
+
+	if cert != nil || m.cache == nil {
+		return cert
+	}
+
+	data, err := m.cache.Get(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	cert, err = decodeCert(data)
+	if err != nil {
+		m.logger.Logf(log.WarnLevel, "autocert invalid cached certificate for %q: %v", host, err)
+		return nil
+	}
+
+	m.Lock()
+	m.certs[host] = cert
+	m.Unlock()
+
+	return cert
+}
+
+func (m *dnsManager) renew(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if _, err := m.obtainOnce(ctx, host); err != nil {
+		m.logger.Logf(log.ErrorLevel, "autocert failed to renew certificate for %q: %v", host, err)
+	}
+}
+
+// obtainOnce obtains the certificate, sharing the result between the
+// concurrent requests for the host, and records failures.
+func (m *dnsManager) obtainOnce(ctx context.Context, host string) (*tls.Certificate, error) {
+	v, err, _ := m.group.Do(host, func() (interface{}, error) {
+		cert, err := m.obtain(ctx, host)
+
+		m.Lock()
+		if err != nil {
+			f := m.failures[host]
+			if f == nil {
+				f = &failure{}
+				m.failures[host] = f
+			}
+
+			f.err = err
+			f.at = time.Now()
+			f.count++
+		} else {
+			delete(m.failures, host)
+		}
+		m.Unlock()
+
+		return cert, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*tls.Certificate), nil
+}
+
+// register loads or creates the account key and registers the account.
+func (m *dnsManager) register(ctx context.Context) error {
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+
+	if m.registered {
+		return nil
+	}
+
+	if m.client.Key == nil {
+		key, err := m.accountKey(ctx)
+		if err != nil {
+			return err
+		}
+
+		m.client.Key = key
+	}
+
+	_, err := m.client.Register(ctx, &xacme.Account{}, autocert.AcceptTOS)
+	if err != nil && err != xacme.ErrAccountAlreadyExists {
+		return err
+	}
+
+	m.registered = true
+
+	return nil
+}
+
+// accountKey returns the cached account key or a new one.
+func (m *dnsManager) accountKey(ctx context.Context) (crypto.Signer, error) {
+	if m.cache != nil {
+		if data, err := m.cache.Get(ctx, accountKey); err == nil {
+			if b, _ := pem.Decode(data); b != nil {
+				return x509.ParseECPrivateKey(b.Bytes)
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.cache != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		if err := m.cache.Put(ctx, accountKey, data); err != nil {
+			m.logger.Logf(log.WarnLevel, "autocert failed to cache the account key: %v", err)
+		}
+	}
+
+	return key, nil
+}
+
+// obtain orders a certificate for the host, solving its dns-01 challenges.
+func (m *dnsManager) obtain(ctx context.Context, host string) (*tls.Certificate, error) {
+	if err := m.register(ctx); err != nil {
+		return nil, err
+	}
+
+	order, err := m.client.AuthorizeOrder(ctx, xacme.DomainIDs(host))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range order.AuthzURLs {
+		if err := m.authorize(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err = m.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: host},
+		DNSNames: []string{host},
+	}
+	if m.mustStaple {
+		req.ExtraExtensions = append(req.ExtraExtensions, mustStaple)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, req, key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := m.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encodeCert(key, der)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := decodeCert(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.cache != nil {
+		if err := m.cache.Put(ctx, host, data); err != nil {
+			m.logger.Logf(log.WarnLevel, "autocert failed to cache certificate for %q: %v", host, err)
+		}
+	}
+
+	m.Lock()
+	m.certs[host] = cert
+	m.Unlock()
+
+	m.logger.Logf(log.InfoLevel, "autocert obtained certificate for %q", host)
+
+	return cert, nil
+}
+
+// authorize solves the dns-01 challenge of the authorization.
+func (m *dnsManager) authorize(ctx context.Context, url string) error {
+	z, err := m.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	if z.Status == xacme.StatusValid {
+		return nil
+	}
+
+	var chal *xacme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+			break
+		}
+	}
+
+	if chal == nil {
+		return fmt.Errorf("acme/autocert: no dns-01 challenge for %q", z.Identifier.Value)
+	}
+
+	thumb, err := xacme.JWKThumbprint(m.client.Key.Public())
+	if err != nil {
+		return err
+	}
+
+	// the identifier of wildcards is the domain without the *.
+	domain := z.Identifier.Value
+	keyAuth := chal.Token + "." + thumb
+
+	if err := m.provider.Present(domain, chal.Token, keyAuth); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := m.provider.CleanUp(domain, chal.Token, keyAuth); err != nil {
+			m.logger.Logf(log.WarnLevel, "autocert failed to clean up challenge for %q: %v", domain, err)
+		}
+	}()
+
+	value, err := m.client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := m.propagated(ctx, domain, value); err != nil {
+		return err
+	}
+
+	if _, err := m.client.Accept(ctx, chal); err != nil {
+		return err
+	}
+
+	_, err = m.client.WaitAuthorization(ctx, z.URI)
+
+	return err
+}
+
+// propagated waits until the challenge record resolves so the CA doesn't
+// validate it too early. The timeout and interval are the provider's if
+// it implements challenge.ProviderTimeout.
+func (m *dnsManager) propagated(ctx context.Context, domain, value string) error {
+	timeout, interval := DefaultPropagationTimeout, 2*time.Second
+	if pt, ok := m.provider.(challenge.ProviderTimeout); ok {
+		timeout, interval = pt.Timeout()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fqdn := "_acme-challenge." + domain
+
+	for {
+		records, _ := lookupTXT(ctx, fqdn)
+		for _, r := range records {
+			if r == value {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("acme/autocert: challenge record %s not found: %w", fqdn, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// encodeCert encodes the key and chain as the autocert.Manager caches them.
+func encodeCert(key *ecdsa.PrivateKey, der [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}); err != nil {
+		return nil, err
+	}
+
+	for _, c := range der {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeCert decodes a cached key and chain.
+func decodeCert(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return nil, errors.New("certificate expired")
+	}
+
+	return &cert, nil
+}
+
+func newDNSManager(provider challenge.Provider, client *xacme.Client, c autocert.Cache, hosts []string, mustStaple bool, logger log.Logger) *dnsManager {
+	lower := make([]string, len(hosts))
+	for i, h := range hosts {
+		lower[i] = strings.ToLower(h)
+	}
+
+	return &dnsManager{
+		provider:   provider,
+		client:     client,
+		cache:      c,
+		hosts:      lower,
+		mustStaple: mustStaple,
+		logger:     logger,
+		certs:      make(map[string]*tls.Certificate),
+		retry:      make(map[string]time.Time),
+		failures:   make(map[string]*failure),
+	}
+}
@@ -0,0 +1,375 @@
+package autocert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/api/server/acme"
+)
+
+// dnsProvider is a challenge provider keeping the TXT records in memory.
+type dnsProvider struct {
+	sync.Mutex
+	records map[string]string
+}
+
+func (p *dnsProvider) Present(domain, token, keyAuth string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	sum := sha256.Sum256([]byte(keyAuth))
+	p.records["_acme-challenge."+domain] = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return nil
+}
+
+func (p *dnsProvider) CleanUp(domain, token, keyAuth string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.records, "_acme-challenge."+domain)
+
+	return nil
+}
+
+func (p *dnsProvider) Timeout() (time.Duration, time.Duration) {
+	return time.Second, 10 * time.Millisecond
+}
+
+func (p *dnsProvider) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if v, ok := p.records[name]; ok {
+		return []string{v}, nil
+	}
+
+	return nil, fmt.Errorf("no record for %s", name)
+}
+
+// acmeServer is a minimal ACME CA which issues a certificate per order
+// once the dns-01 challenge record resolves.
+type acmeServer struct {
+	*httptest.Server
+
+	t        *testing.T
+	provider *dnsProvider
+	key      *ecdsa.PrivateKey
+	ca       *x509.Certificate
+
+	sync.Mutex
+	orders int
+	host   string
+	valid  bool
+	cert   []byte
+}
+
+func newACMEServer(t *testing.T, provider *dnsProvider) *acmeServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, _ := x509.ParseCertificate(der)
+
+	s := &acmeServer{t: t, provider: provider, key: key, ca: ca}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+// payload decodes the payload of the JWS request body.
+func (s *acmeServer) payload(r *http.Request, v interface{}) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil || len(jws.Payload) == 0 {
+		return
+	}
+
+	b, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	json.Unmarshal(b, v)
+}
+
+func (s *acmeServer) order(w http.ResponseWriter, status string) {
+	w.Header().Set("Location", s.URL+"/order/1")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": s.host}},
+		"authorizations": []string{s.URL + "/authz/1"},
+		"finalize":       s.URL + "/finalize/1",
+		"certificate":    s.URL + "/cert/1",
+	})
+}
+
+func (s *acmeServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+
+	switch r.URL.Path {
+	case "/dir":
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   s.URL + "/nonce",
+			"newAccount": s.URL + "/account",
+			"newOrder":   s.URL + "/order",
+		})
+	case "/nonce":
+	case "/account":
+		w.Header().Set("Location", s.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case "/order":
+		var req struct {
+			Identifiers []struct{ Value string }
+		}
+		s.payload(r, &req)
+
+		s.orders++
+		s.host = req.Identifiers[0].Value
+		s.valid = false
+
+		w.Header().Set("Location", s.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		s.order(w, "pending")
+	case "/authz/1":
+		status := "pending"
+		if s.valid {
+			status = "valid"
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": s.host[2:]},
+			"wildcard":   true,
+			"challenges": []map[string]string{
+				{"type": "http-01", "url": s.URL + "/chal/0", "token": "http", "status": "pending"},
+				{"type": "dns-01", "url": s.URL + "/chal/1", "token": "token", "status": status},
+			},
+		})
+	case "/chal/1":
+		// the record must resolve before the challenge is accepted
+		if _, err := s.provider.lookupTXT(r.Context(), "_acme-challenge."+s.host[2:]); err != nil {
+			s.t.Errorf("Challenge accepted before the record was created: %v", err)
+		}
+
+		s.valid = true
+		json.NewEncoder(w).Encode(map[string]string{
+			"type": "dns-01", "url": s.URL + "/chal/1", "token": "token", "status": "valid",
+		})
+	case "/order/1":
+		status := "pending"
+		if s.valid {
+			status = "ready"
+		}
+		if s.cert != nil {
+			status = "valid"
+		}
+		s.order(w, status)
+	case "/finalize/1":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		s.payload(r, &req)
+
+		b, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+
+		csr, err := x509.ParseCertificateRequest(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(s.orders + 1)),
+			Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+
+		s.cert, err = x509.CreateCertificate(rand.Reader, tmpl, s.ca, csr.PublicKey, s.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.order(w, "valid")
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.cert})
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Raw})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestDNSChallenge(t *testing.T) {
+	provider := &dnsProvider{records: make(map[string]string)}
+
+	lookup := lookupTXT
+	lookupTXT = provider.lookupTXT
+	defer func() { lookupTXT = lookup }()
+
+	srv := newACMEServer(t, provider)
+	defer srv.Close()
+
+	c := NewMemoryCache()
+
+	p := NewProvider(
+		acme.CA(srv.URL+"/dir"),
+		acme.ChallengeProvider(provider),
+		acme.Cache(c),
+	)
+
+	if _, err := p.TLSConfig(); err == nil {
+		t.Fatal("Expected an error without hosts")
+	}
+
+	config, err := p.TLSConfig("*.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the wildcard certificate is obtained once for the subdomains
+	for _, name := range []string{"foo.example.com", "bar.example.com"} {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cert.Leaf.VerifyHostname(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if srv.orders != 1 {
+		t.Fatalf("Expected 1 order got %d", srv.orders)
+	}
+
+	// the challenge record is removed
+	if len(provider.records) > 0 {
+		t.Fatalf("Expected the records to be cleaned up got %v", provider.records)
+	}
+
+	for _, name := range []string{"example.com", "a.foo.example.com"} {
+		if _, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: name}); err == nil {
+			t.Fatalf("Expected no certificate for %s", name)
+		}
+	}
+
+	// the certificate is cached
+	if _, err := c.Get(context.Background(), "*.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := p.(acme.StatusProvider).CertStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 1 || certs[0].Host != "*.example.com" || certs[0].Issuer != "test ca" {
+		t.Fatalf("Unexpected certificates %+v", certs)
+	}
+}
+
+// failingProvider fails to present the challenge records.
+type failingProvider struct {
+	*dnsProvider
+}
+
+func (p *failingProvider) Present(domain, token, keyAuth string) error {
+	return errors.New("dns provider failure")
+}
+
+func TestDNSBackoff(t *testing.T) {
+	provider := &dnsProvider{records: make(map[string]string)}
+
+	srv := newACMEServer(t, provider)
+	defer srv.Close()
+
+	p := NewProvider(
+		acme.CA(srv.URL+"/dir"),
+		acme.ChallengeProvider(&failingProvider{provider}),
+	)
+
+	config, err := p.TLSConfig("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello := &tls.ClientHelloInfo{ServerName: "example.com"}
+
+	// handshakes fail without another order until the backoff passes
+	for i := 0; i < 3; i++ {
+		if _, err := config.GetCertificate(hello); err == nil {
+			t.Fatal("Expected an error")
+		}
+	}
+
+	if srv.orders != 1 {
+		t.Fatalf("Expected 1 order got %d", srv.orders)
+	}
+
+	backoff := DefaultObtainBackoff
+	DefaultObtainBackoff = 0
+	defer func() { DefaultObtainBackoff = backoff }()
+
+	if _, err := config.GetCertificate(hello); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if srv.orders != 2 {
+		t.Fatalf("Expected 2 orders after the backoff got %d", srv.orders)
+	}
+
+	// the backoff doubles up to an hour
+	for _, d := range []struct {
+		count int
+		want  time.Duration
+	}{
+		{1, backoff},
+		{2, 2 * backoff},
+		{10, time.Hour},
+	} {
+		DefaultObtainBackoff = backoff
+
+		if got := (&failure{count: d.count}).backoff(); got != d.want {
+			t.Fatalf("%d failures: expected backoff %v got %v", d.count, d.want, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package autocert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "go-micro.org/v5/logger"
+	"golang.org/x/crypto/ocsp"
+)
+
+// mustStaple is the TLS feature extension requiring
+// the status_request feature, see RFC 7633.
+var mustStaple = pkix.Extension{
+	Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24},
+	// SEQUENCE { INTEGER 5 }
+	Value: []byte{0x30, 0x03, 0x02, 0x01, 0x05},
+}
+
+// DefaultOCSPTimeout bounds the requests to OCSP responders
+// if acme.Timeout isn't set.
+var DefaultOCSPTimeout = 10 * time.Second
+
+// staple is the OCSP response of a certificate.
+type staple struct {
+	der []byte
+	// when to fetch a new response
+	refresh time.Time
+	// when the response expires
+	expires time.Time
+}
+
+// stapler fetches and caches the OCSP responses of certificates.
+type stapler struct {
+	client *http.Client
+	logger log.Logger
+
+	sync.Mutex
+	// staples by certificate serial number
+	staples map[string]*staple
+	// fetches in flight by certificate serial number,
+	// closed once done
+	fetching map[string]chan struct{}
+}
+
+// staple returns a copy of the certificate with its OCSP response.
+// The certificate is returned as is if there's no valid response.
+// Responses due a refresh are stapled while a new one is fetched in
+// the background. Without a valid response the handshake waits for
+// the fetch until ctx is done. Concurrent handshakes share a fetch.
+func (s *stapler) staple(ctx context.Context, cert *tls.Certificate) *tls.Certificate {
+	if len(cert.Certificate) < 2 {
+		return cert
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return cert
+		}
+	}
+
+	if len(leaf.OCSPServer) == 0 {
+		return cert
+	}
+
+	now := time.Now()
+	key := leaf.SerialNumber.String()
+
+	s.Lock()
+	st := s.staples[key]
+
+	var done chan struct{}
+	if st == nil || now.After(st.refresh) {
+		done = s.refresh(key, leaf, cert.Certificate[1])
+	}
+	s.Unlock()
+
+	// an old response is stapled until it expires
+	if st == nil || now.After(st.expires) {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return cert
+		}
+
+		s.Lock()
+		st = s.staples[key]
+		s.Unlock()
+
+		if st == nil || time.Now().After(st.expires) {
+			return cert
+		}
+	}
+
+	c := *cert
+	c.OCSPStaple = st.der
+
+	return &c
+}
+
+// refresh fetches the certificate's response in the background unless a
+// fetch is in flight, returning the channel closed once it's done. The
+// caller must hold the lock.
+func (s *stapler) refresh(key string, leaf *x509.Certificate, issuerDER []byte) chan struct{} {
+	if done, ok := s.fetching[key]; ok {
+		return done
+	}
+
+	done := make(chan struct{})
+	s.fetching[key] = done
+
+	go func() {
+		defer close(done)
+
+		st, err := s.fetch(leaf, issuerDER)
+
+		s.Lock()
+		defer s.Unlock()
+
+		delete(s.fetching, key)
+
+		if err != nil {
+			s.logger.Logf(log.WarnLevel, "autocert failed to get OCSP response for %s: %v", leaf.Subject.CommonName, err)
+			return
+		}
+
+		s.staples[key] = st
+		s.sweep(time.Now())
+	}()
+
+	return done
+}
+
+// sweep drops the expired responses of replaced certificates.
+func (s *stapler) sweep(now time.Time) {
+	for k, st := range s.staples {
+		if now.After(st.expires) {
+			delete(s.staples, k)
+		}
+	}
+}
+
+// fetch requests the certificate's status from its OCSP responder.
+func (s *stapler) fetch(leaf *x509.Certificate, issuerDER []byte) (*staple, error) {
+	issuer, err := x509.ParseCertificate(issuerDER)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := s.client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responder returned %s", rsp.Status)
+	}
+
+	der, err := io.ReadAll(io.LimitReader(rsp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Status != ocsp.Good {
+		return nil, errors.New("certificate is not in good standing")
+	}
+
+	expires := res.NextUpdate
+	if expires.IsZero() {
+		// without a next update the response is always current
+		expires = time.Now().Add(time.Hour)
+	}
+
+	return &staple{
+		der: der,
+		// halfway through the validity period
+		refresh: res.ThisUpdate.Add(expires.Sub(res.ThisUpdate) / 2),
+		expires: expires,
+	}, nil
+}
+
+func newStapler(client *http.Client, logger log.Logger) *stapler {
+	return &stapler{
+		client:   client,
+		logger:   logger,
+		staples:  make(map[string]*staple),
+		fetching: make(map[string]chan struct{}),
+	}
+}
@@ -0,0 +1,191 @@
+package autocert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "go-micro.org/v5/logger"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testStapling returns a certificate issued by a CA whose OCSP responder
+// calls before with each request, and the CA.
+func testStapling(t *testing.T, before func()) (*tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, _ := x509.ParseCertificate(caDER)
+
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before()
+
+		b, _ := io.ReadAll(r.Body)
+
+		req, err := ocsp.ParseRequest(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rsp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(rsp)
+	}))
+	t.Cleanup(responder.Close)
+
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{responder.URL},
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{leafDER, caDER},
+		PrivateKey:  key,
+	}, ca
+}
+
+func TestStapler(t *testing.T) {
+	var requests int32
+
+	cert, ca := testStapling(t, func() {
+		atomic.AddInt32(&requests, 1)
+	})
+
+	s := newStapler(http.DefaultClient, log.NewLogger())
+
+	for i := 0; i < 2; i++ {
+		c := s.staple(context.Background(), cert)
+		if len(c.OCSPStaple) == 0 {
+			t.Fatal("Expected an OCSP staple")
+		}
+
+		if _, err := ocsp.ParseResponse(c.OCSPStaple, ca); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the shared certificate isn't modified
+	if len(cert.OCSPStaple) > 0 {
+		t.Fatal("Expected the certificate to be copied")
+	}
+
+	// the response is cached
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("Expected 1 OCSP request got %d", n)
+	}
+
+	// certificates without a responder are served as is
+	cert.Certificate = cert.Certificate[:1]
+	if c := s.staple(context.Background(), cert); c != cert {
+		t.Fatal("Expected the certificate without an issuer to be unchanged")
+	}
+}
+
+func TestStaplerSlowResponder(t *testing.T) {
+	var requests int32
+
+	release := make(chan struct{})
+
+	cert, _ := testStapling(t, func() {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	})
+
+	s := newStapler(http.DefaultClient, log.NewLogger())
+
+	// handshakes share the fetch and wait for it until they're done
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			if c := s.staple(ctx, cert); len(c.OCSPStaple) > 0 {
+				t.Error("Expected no OCSP staple before the response")
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(release)
+
+	// the response is stapled once fetched
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.staple(context.Background(), cert).OCSPStaple) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected an OCSP staple")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("Expected 1 OCSP request got %d", n)
+	}
+
+	// a response due a refresh is stapled without waiting for the refresh
+	s.Lock()
+	for _, st := range s.staples {
+		st.refresh = time.Now().Add(-time.Minute)
+	}
+	s.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if c := s.staple(ctx, cert); len(c.OCSPStaple) == 0 {
+		t.Fatal("Expected the old OCSP staple while refreshing")
+	}
+}
@@ -0,0 +1,125 @@
+package autocert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"strings"
+
+	"go-micro.org/v5/api/server/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// track records the hosts and cache of a manager for CertStatus.
+func (a *autocertProvider) track(c autocert.Cache, hosts []string) {
+	a.Lock()
+	defer a.Unlock()
+
+	if c != nil {
+		a.cache = c
+	}
+
+	a.hosts = append(a.hosts, hosts...)
+}
+
+// served records the certificate served to a client.
+func (a *autocertProvider) served(hello *tls.ClientHelloInfo, cert *tls.Certificate) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.certs[strings.ToLower(hello.ServerName)] = leaf
+}
+
+// CertStatus implements acme.StatusProvider. It reports the certificates
+// in the cache of the hosts passed to TLSConfig and those served to
+// clients, including the hosts issued on demand.
+func (a *autocertProvider) CertStatus() ([]acme.CertInfo, error) {
+	a.Lock()
+	c := a.cache
+	hosts := append([]string(nil), a.hosts...)
+	certs := make(map[string]*x509.Certificate, len(a.certs))
+	for host, leaf := range a.certs {
+		certs[host] = leaf
+	}
+	a.Unlock()
+
+	var infos []acme.CertInfo
+
+	// certificates seen by serial number
+	seen := make(map[string]bool)
+
+	add := func(host string, leaf *x509.Certificate) {
+		if seen[leaf.SerialNumber.String()] {
+			return
+		}
+
+		seen[leaf.SerialNumber.String()] = true
+
+		infos = append(infos, acme.CertInfo{
+			Host:      host,
+			Issuer:    leaf.Issuer.CommonName,
+			NotBefore: leaf.NotBefore,
+			NotAfter:  leaf.NotAfter,
+		})
+	}
+
+	if c != nil {
+		for _, host := range hosts {
+			// the ECDSA and RSA certificates
+			for _, key := range []string{host, host + "+rsa"} {
+				data, err := c.Get(context.Background(), key)
+				if err == autocert.ErrCacheMiss {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+
+				if leaf := parseLeaf(data); leaf != nil {
+					add(host, leaf)
+				}
+			}
+		}
+	}
+
+	for host, leaf := range certs {
+		add(host, leaf)
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Host < infos[j].Host
+	})
+
+	return infos, nil
+}
+
+// parseLeaf returns the first certificate of the
+// PEM encoded key and chain autocert caches.
+func parseLeaf(data []byte) *x509.Certificate {
+	for {
+		var b *pem.Block
+		if b, data = pem.Decode(data); b == nil {
+			return nil
+		}
+
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+
+		leaf, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return nil
+		}
+
+		return leaf
+	}
+}
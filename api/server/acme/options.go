@@ -1,6 +1,8 @@
 package acme
 
 import (
+	"time"
+
 	"github.com/go-acme/lego/v4/challenge"
 	"go-micro.org/v5/logger"
 )
@@ -28,6 +30,15 @@ type Options struct {
 	// Issue certificates for domains on demand. Otherwise, certs will be
 	// retrieved / issued on start-up.
 	OnDemand bool
+	// OCSPStapling staples the certificate's OCSP response to handshakes.
+	OCSPStapling bool
+	// MustStaple requests certificates with the OCSP must-staple
+	// extension, which requires OCSPStapling.
+	MustStaple bool
+	// Timeout bounds the requests to the CA and OCSP responders. If
+	// zero CA requests are unlimited and OCSP ones use the default
+	// of the provider.
+	Timeout time.Duration
 }
 
 // AcceptToS indicates whether you accept your CA's terms of service.
@@ -76,6 +87,34 @@ func Logger(l logger.Logger) Option {
 	}
 }
 
+// OCSPStapling staples the OCSP response of the certificate to the TLS
+// handshakes, sparing clients the request to the CA's responder.
+func OCSPStapling(b bool) Option {
+	return func(o *Options) {
+		o.OCSPStapling = b
+	}
+}
+
+// MustStaple requests certificates with the TLS feature extension telling
+// clients to reject handshakes without a stapled OCSP response (RFC 7633).
+// It enables OCSPStapling as the certificates are unusable without it.
+func MustStaple(b bool) Option {
+	return func(o *Options) {
+		o.MustStaple = b
+		if b {
+			o.OCSPStapling = true
+		}
+	}
+}
+
+// Timeout sets the timeout of the HTTP requests to the CA
+// and the OCSP responders.
+func Timeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
 // DefaultOptions uses the Let's Encrypt Production CA, with DNS Challenge disabled.
 func DefaultOptions() Options {
 	return Options{
@@ -2,13 +2,85 @@ package client
 
 import (
 	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"go-micro.org/v5/errors"
 	"go-micro.org/v5/util/backoff"
 )
 
 type BackoffFunc func(ctx context.Context, req Request, attempts int) (time.Duration, error)
 
+type lastErrorKey struct{}
+
+// retryAfter matches a Retry-After hint in an error detail.
+var retryAfter = regexp.MustCompile(`(?i)retry-after\s*[:=]\s*([^;]+)`)
+
 func exponentialBackoff(ctx context.Context, req Request, attempts int) (time.Duration, error) {
 	return backoff.Do(attempts), nil
 }
+
+// withLastError passes the error of the previous attempt to the BackoffFunc.
+func withLastError(ctx context.Context, err error) context.Context {
+	if err == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, lastErrorKey{}, err)
+}
+
+// LastError returns the error of the previous attempt from the
+// context passed to a BackoffFunc, nil on the first attempt.
+func LastError(ctx context.Context) error {
+	err, _ := ctx.Value(lastErrorKey{}).(error)
+	return err
+}
+
+// RetryAfterBackoff returns a BackoffFunc which waits for the Retry-After hint
+// in the detail of the last error, e.g. "Retry-After: 30" in seconds or as an
+// HTTP date, capped at max. The hint takes precedence over the exponential
+// backoff, which is used when the last error carries none.
+func RetryAfterBackoff(max time.Duration) BackoffFunc {
+	return func(ctx context.Context, req Request, attempts int) (time.Duration, error) {
+		if d, ok := parseRetryAfter(LastError(ctx)); ok {
+			if d > max {
+				d = max
+			}
+
+			return d, nil
+		}
+
+		return exponentialBackoff(ctx, req, attempts)
+	}
+}
+
+// parseRetryAfter reads the Retry-After hint from the error detail.
+func parseRetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	m := retryAfter.FindStringSubmatch(errors.FromError(err).Detail)
+	if m == nil {
+		return 0, false
+	}
+
+	v := strings.TrimSpace(m[1])
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
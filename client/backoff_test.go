@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"go-micro.org/v5/errors"
 )
 
 func TestBackoff(t *testing.T) {
@@ -29,3 +31,30 @@ func TestBackoff(t *testing.T) {
 		}
 	}
 }
+
+func TestRetryAfterBackoff(t *testing.T) {
+	fn := RetryAfterBackoff(time.Minute)
+	req := NewRequest("test", "test", nil)
+
+	testData := []struct {
+		err  error
+		want time.Duration
+	}{
+		{nil, 100 * time.Millisecond},
+		{errors.New("test", "rate limited, Retry-After: 5", 429), 5 * time.Second},
+		{errors.New("test", "retry-after=600", 429), time.Minute},
+		{errors.New("test", "Retry-After: Wed, 21 Oct 2015 07:28:00 GMT", 503), 0},
+		{errors.New("test", "Retry-After: soon", 503), 100 * time.Millisecond},
+	}
+
+	for _, d := range testData {
+		got, err := fn(withLastError(context.TODO(), d.err), req, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != d.want {
+			t.Fatalf("Expected %v for %v, got %v", d.want, d.err, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-micro.org/v5/errors"
+)
+
+// BreakerState is the state of a circuit.
+type BreakerState int
+
+const (
+	// BreakerClosed lets calls through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails calls fast until the cooldown passes.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerOptions configure the circuit breaker.
+type BreakerOptions struct {
+	// Window the error rate is measured over
+	Window time.Duration
+	// Threshold is the error rate, between 0 and 1,
+	// at which the circuit opens
+	Threshold float64
+	// MinRequests in the window before the circuit may open
+	MinRequests int
+	// Cooldown before an open circuit lets a trial call through
+	Cooldown time.Duration
+}
+
+// BreakerOption sets a circuit breaker option.
+type BreakerOption func(o *BreakerOptions)
+
+// BreakerWindow sets the window the error rate is measured over.
+func BreakerWindow(d time.Duration) BreakerOption {
+	return func(o *BreakerOptions) {
+		o.Window = d
+	}
+}
+
+// BreakerThreshold sets the error rate at which the circuit opens.
+func BreakerThreshold(rate float64) BreakerOption {
+	return func(o *BreakerOptions) {
+		o.Threshold = rate
+	}
+}
+
+// BreakerMinRequests sets the number of calls in a window
+// before the error rate is considered.
+func BreakerMinRequests(n int) BreakerOption {
+	return func(o *BreakerOptions) {
+		o.MinRequests = n
+	}
+}
+
+// BreakerCooldown sets how long the circuit stays open.
+func BreakerCooldown(d time.Duration) BreakerOption {
+	return func(o *BreakerOptions) {
+		o.Cooldown = d
+	}
+}
+
+type circuit struct {
+	state BreakerState
+	// start of the current window
+	start    time.Time
+	requests int
+	failures int
+	// when the circuit opened
+	opened time.Time
+	// a trial call is in flight
+	trial bool
+}
+
+// Breaker tracks the error rate per service and endpoint and
+// fails calls fast while a circuit is open.
+type Breaker struct {
+	sync.Mutex
+
+	opts     BreakerOptions
+	circuits map[string]*circuit
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewBreaker returns a circuit breaker.
+func NewBreaker(opts ...BreakerOption) *Breaker {
+	options := BreakerOptions{
+		Window:      time.Second * 10,
+		Threshold:   0.5,
+		MinRequests: 10,
+		Cooldown:    time.Second * 5,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Breaker{
+		opts:     options,
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+}
+
+func breakerKey(service, endpoint string) string {
+	return service + ":" + endpoint
+}
+
+// State returns the state of the circuit for the service endpoint.
+func (b *Breaker) State(service, endpoint string) BreakerState {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[breakerKey(service, endpoint)]
+	if !ok {
+		return BreakerClosed
+	}
+
+	if c.state == BreakerOpen && b.now().Sub(c.opened) >= b.opts.Cooldown {
+		return BreakerHalfOpen
+	}
+
+	return c.state
+}
+
+// States returns the state of every circuit keyed by service:endpoint.
+func (b *Breaker) States() map[string]BreakerState {
+	b.Lock()
+	defer b.Unlock()
+
+	states := make(map[string]BreakerState, len(b.circuits))
+
+	for k, c := range b.circuits {
+		states[k] = c.state
+		if c.state == BreakerOpen && b.now().Sub(c.opened) >= b.opts.Cooldown {
+			states[k] = BreakerHalfOpen
+		}
+	}
+
+	return states
+}
+
+// allow reports whether a call may be made.
+func (b *Breaker) allow(key string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{start: b.now()}
+		b.circuits[key] = c
+	}
+
+	switch c.state {
+	case BreakerOpen:
+		if b.now().Sub(c.opened) < b.opts.Cooldown {
+			return false
+		}
+
+		c.state = BreakerHalfOpen
+		c.trial = true
+
+		return true
+	case BreakerHalfOpen:
+		// only the one trial call
+		if c.trial {
+			return false
+		}
+
+		c.trial = true
+
+		return true
+	}
+
+	return true
+}
+
+// record updates the circuit with the result of a call.
+func (b *Breaker) record(key string, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	c := b.circuits[key]
+	now := b.now()
+	failed := isFailure(err)
+
+	if c.state == BreakerHalfOpen {
+		c.trial = false
+
+		if failed {
+			c.state = BreakerOpen
+			c.opened = now
+
+			return
+		}
+
+		*c = circuit{start: now}
+
+		return
+	}
+
+	if now.Sub(c.start) >= b.opts.Window {
+		c.start = now
+		c.requests = 0
+		c.failures = 0
+	}
+
+	c.requests++
+	if failed {
+		c.failures++
+	}
+
+	if c.requests >= b.opts.MinRequests && float64(c.failures)/float64(c.requests) >= b.opts.Threshold {
+		c.state = BreakerOpen
+		c.opened = now
+	}
+}
+
+// isFailure reports whether the error counts against the
+// backend. Client errors such as bad requests do not.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	e := errors.FromError(err)
+
+	return e.Code == 0 || e.Code == http.StatusRequestTimeout || e.Code >= http.StatusInternalServerError
+}
+
+type breakerWrapper struct {
+	Client
+
+	breaker *Breaker
+}
+
+func (w *breakerWrapper) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	key := breakerKey(req.Service(), req.Endpoint())
+
+	if !w.breaker.allow(key) {
+		return &errors.Error{
+			Id:     "go.micro.client",
+			Code:   http.StatusServiceUnavailable,
+			Detail: "circuit open for " + key,
+			Status: http.StatusText(http.StatusServiceUnavailable),
+		}
+	}
+
+	err := w.Client.Call(ctx, req, rsp, opts...)
+	w.breaker.record(key, err)
+
+	return err
+}
+
+// Wrapper returns a client wrapper which short circuits calls to failing
+// endpoints with a 503 error. The wrapped call includes the client's own
+// retries, so the BackoffFunc is applied within a single recorded call and
+// a call only counts as failed once its retries are exhausted. The fast
+// 503 error is not retried by the default RetryFunc.
+func (b *Breaker) Wrapper() Wrapper {
+	return func(c Client) Client {
+		return &breakerWrapper{
+			Client:  c,
+			breaker: b,
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/errors"
+)
+
+type breakerTestClient struct {
+	Client
+	err   error
+	calls int
+}
+
+func (c *breakerTestClient) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	c.calls++
+	return c.err
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+
+	b := NewBreaker(BreakerMinRequests(2), BreakerThreshold(0.5), BreakerCooldown(time.Second))
+	b.now = func() time.Time { return now }
+
+	tc := &breakerTestClient{err: errors.InternalServerError("foo", "failed")}
+	c := b.Wrapper()(tc)
+	req := NewRequest("foo", "Foo.Bar", nil)
+
+	for i := 0; i < 2; i++ {
+		c.Call(context.TODO(), req, nil)
+	}
+
+	if s := b.State("foo", "Foo.Bar"); s != BreakerOpen {
+		t.Fatalf("Expected circuit to be open got %s", s)
+	}
+
+	err := c.Call(context.TODO(), req, nil)
+	if e := errors.FromError(err); e.Code != 503 || tc.calls != 2 {
+		t.Fatalf("Expected fast 503 error got %v after %d calls", err, tc.calls)
+	}
+
+	// the trial call after the cooldown closes the circuit
+	now = now.Add(time.Second)
+	tc.err = nil
+
+	if s := b.State("foo", "Foo.Bar"); s != BreakerHalfOpen {
+		t.Fatalf("Expected circuit to be half-open got %s", s)
+	}
+
+	if err := c.Call(context.TODO(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.State("foo", "Foo.Bar"); s != BreakerClosed {
+		t.Fatalf("Expected circuit to be closed got %s", s)
+	}
+}
+
+func TestBreakerClientErrors(t *testing.T) {
+	b := NewBreaker(BreakerMinRequests(1))
+	c := b.Wrapper()(&breakerTestClient{err: errors.BadRequest("foo", "bad")})
+
+	c.Call(context.TODO(), NewRequest("foo", "Foo.Bar", nil), nil)
+
+	if s := b.State("foo", "Foo.Bar"); s != BreakerClosed {
+		t.Fatalf("Expected client errors to not open the circuit got %s", s)
+	}
+}
@@ -1,54 +1,251 @@
 package client
 
 import (
+	"container/list"
 	"context"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"sync"
 	"time"
 
-	cache "github.com/patrickmn/go-cache"
-
 	"go-micro.org/v5/metadata"
 	"go-micro.org/v5/transport/headers"
 )
 
+var (
+	// DefaultCacheSize is the max number of cached responses.
+	DefaultCacheSize = 1000
+	// DefaultCacheTTL is how long the response cache wrapper keeps responses.
+	DefaultCacheTTL = time.Second * 10
+)
+
+// CacheOptions configure the response cache.
+type CacheOptions struct {
+	// TTL of cached responses, overridden per call with WithCache
+	TTL time.Duration
+	// Size is the max number of responses kept,
+	// the least recently used are evicted
+	Size int
+	// Endpoints which are idempotent and may be
+	// cached, all endpoints if empty
+	Endpoints []string
+}
+
+// CacheOption sets a response cache option.
+type CacheOption func(o *CacheOptions)
+
+// CacheTTL sets how long responses are cached for.
+func CacheTTL(d time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		o.TTL = d
+	}
+}
+
+// CacheSize sets the max number of cached responses.
+func CacheSize(n int) CacheOption {
+	return func(o *CacheOptions) {
+		o.Size = n
+	}
+}
+
+// CacheEndpoints limits caching to the given endpoints e.g. Foo.Bar.
+func CacheEndpoints(eps ...string) CacheOption {
+	return func(o *CacheOptions) {
+		o.Endpoints = append(o.Endpoints, eps...)
+	}
+}
+
+func newCacheOptions(opts ...CacheOption) CacheOptions {
+	options := CacheOptions{
+		TTL:  DefaultCacheTTL,
+		Size: DefaultCacheSize,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
 // NewCache returns an initialized cache.
-func NewCache() *Cache {
+func NewCache(opts ...CacheOption) *Cache {
+	options := newCacheOptions(opts...)
+
 	return &Cache{
-		cache: cache.New(cache.NoExpiration, 30*time.Second),
+		size:  options.Size,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
 	}
 }
 
 // Cache for responses.
 type Cache struct {
-	cache *cache.Cache
+	sync.Mutex
+
+	// max number of items, unbounded if zero
+	size  int
+	items map[string]*list.Element
+	// most recently used at the front
+	lru *list.List
+}
+
+type cacheItem struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func (i *cacheItem) expired() bool {
+	return !i.expires.IsZero() && time.Now().After(i.expires)
 }
 
 // Get a response from the cache.
 func (c *Cache) Get(ctx context.Context, req *Request) (interface{}, bool) {
-	return c.cache.Get(key(ctx, req))
+	k := key(ctx, req)
+
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+
+	item := el.Value.(*cacheItem)
+	if item.expired() {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+
+	return item.value, true
 }
 
-// Set a response in the cache.
+// Set a response in the cache. A zero expiry never expires.
 func (c *Cache) Set(ctx context.Context, req *Request, rsp interface{}, expiry time.Duration) {
-	c.cache.Set(key(ctx, req), rsp, expiry)
+	item := &cacheItem{
+		key:   key(ctx, req),
+		value: rsp,
+	}
+
+	if expiry > 0 {
+		item.expires = time.Now().Add(expiry)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[item.key]; ok {
+		el.Value = item
+		c.lru.MoveToFront(el)
+
+		return
+	}
+
+	c.items[item.key] = c.lru.PushFront(item)
+
+	// evict the least recently used
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*cacheItem).key)
 }
 
 // List the key value pairs in the cache.
 func (c *Cache) List() map[string]string {
-	items := c.cache.Items()
+	c.Lock()
+	defer c.Unlock()
 
-	rsp := make(map[string]string, len(items))
+	rsp := make(map[string]string, len(c.items))
 
-	for k, v := range items {
-		bytes, _ := json.Marshal(v.Object)
+	for k, el := range c.items {
+		item := el.Value.(*cacheItem)
+		if item.expired() {
+			continue
+		}
+
+		bytes, _ := json.Marshal(item.value)
 		rsp[k] = string(bytes)
 	}
 
 	return rsp
 }
 
+type cacheWrapper struct {
+	Client
+
+	opts  CacheOptions
+	cache *Cache
+}
+
+func (c *cacheWrapper) cacheable(req Request) bool {
+	if len(c.opts.Endpoints) == 0 {
+		return true
+	}
+
+	for _, ep := range c.opts.Endpoints {
+		if ep == req.Endpoint() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *cacheWrapper) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	options := CallOptions{
+		CacheExpiry: c.opts.TTL,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.CacheBypass || options.CacheExpiry <= 0 || !c.cacheable(req) {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	// responses are stored encoded so callers can't modify them
+	if v, ok := c.cache.Get(ctx, &req); ok {
+		if err := json.Unmarshal(v.([]byte), rsp); err == nil {
+			return nil
+		}
+	}
+
+	if err := c.Client.Call(ctx, req, rsp, opts...); err != nil {
+		return err
+	}
+
+	if b, err := json.Marshal(rsp); err == nil {
+		c.cache.Set(ctx, &req, b, options.CacheExpiry)
+	}
+
+	return nil
+}
+
+// ResponseCache returns a client wrapper which caches successful responses
+// keyed by the service, endpoint and request body. Only use it for
+// idempotent endpoints, see CacheEndpoints. Calls skip the cache with
+// WithCacheBypass and set their own TTL with WithCache.
+func ResponseCache(opts ...CacheOption) Wrapper {
+	options := newCacheOptions(opts...)
+	cache := NewCache(opts...)
+
+	return func(c Client) Client {
+		return &cacheWrapper{
+			Client: c,
+			opts:   options,
+			cache:  cache,
+		}
+	}
+}
+
 // key returns a hash for the context and request.
 func key(ctx context.Context, req *Request) string {
 	ns, _ := metadata.Get(ctx, headers.Namespace)
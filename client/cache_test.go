@@ -33,6 +33,76 @@ func TestCache(t *testing.T) {
 	})
 }
 
+func TestCacheEviction(t *testing.T) {
+	ctx := context.TODO()
+	c := NewCache(CacheSize(2))
+
+	req1 := NewRequest("go.micro.service.foo", "Foo.Bar", "1")
+	req2 := NewRequest("go.micro.service.foo", "Foo.Bar", "2")
+	req3 := NewRequest("go.micro.service.foo", "Foo.Bar", "3")
+
+	c.Set(ctx, &req1, "1", time.Minute)
+	c.Set(ctx, &req2, "2", time.Minute)
+
+	// use the first so the second is least recently used
+	c.Get(ctx, &req1)
+	c.Set(ctx, &req3, "3", time.Minute)
+
+	if _, ok := c.Get(ctx, &req2); ok {
+		t.Errorf("Expected least recently used response to be evicted")
+	}
+
+	if _, ok := c.Get(ctx, &req1); !ok {
+		t.Errorf("Expected recently used response to be kept")
+	}
+
+	c.Set(ctx, &req3, "3", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok := c.Get(ctx, &req3); ok {
+		t.Errorf("Expected expired response to be removed")
+	}
+}
+
+type cacheTestClient struct {
+	Client
+	calls int
+}
+
+func (c *cacheTestClient) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	c.calls++
+	*(rsp.(*map[string]int)) = map[string]int{"calls": c.calls}
+
+	return nil
+}
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.TODO()
+	tc := &cacheTestClient{}
+	c := ResponseCache(CacheEndpoints("Foo.Bar"))(tc)
+
+	call := func(endpoint string, opts ...CallOption) int {
+		rsp := map[string]int{}
+		if err := c.Call(ctx, NewRequest("go.micro.service.foo", endpoint, nil), &rsp, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		return rsp["calls"]
+	}
+
+	if call("Foo.Bar") != 1 || call("Foo.Bar") != 1 {
+		t.Errorf("Expected the second call to be served from the cache")
+	}
+
+	if call("Foo.Bar", WithCacheBypass()) != 2 {
+		t.Errorf("Expected the cache to be bypassed")
+	}
+
+	if call("Foo.Baz") != 3 || call("Foo.Baz") != 4 {
+		t.Errorf("Expected endpoints not listed to not be cached")
+	}
+}
+
 func TestCacheKey(t *testing.T) {
 	ctx := context.TODO()
 	req1 := NewRequest("go.micro.service.foo", "Foo.Bar", nil)
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+// errDeadline is returned when too little time remains for an attempt.
+var errDeadline = errors.New("insufficient time remaining before deadline")
+
+var (
+	// DefaultMinAttemptTimeout is the least time an attempt needs, deadline
+	// aware retries stop once less than this remains before the deadline.
+	DefaultMinAttemptTimeout = time.Millisecond * 10
+)
+
+// timeNow is the clock of the budgets, replaced in tests.
+var timeNow = time.Now
+
+// budget shares the time left until a deadline between call attempts.
+type budget struct {
+	deadline time.Time
+	// min time an attempt needs
+	min time.Duration
+	// now is replaced in tests
+	now func() time.Time
+}
+
+func newBudget(deadline time.Time) *budget {
+	return &budget{
+		deadline: deadline,
+		min:      DefaultMinAttemptTimeout,
+		now:      timeNow,
+	}
+}
+
+// timeout returns the timeout for the next attempt after sleeping for the
+// backoff, splitting what remains fairly between the attempts left. It
+// returns false if too little time remains to make the attempt.
+func (b *budget) timeout(backoff time.Duration, attempts int) (time.Duration, bool) {
+	remaining := b.deadline.Sub(b.now()) - backoff
+	if remaining < b.min {
+		return 0, false
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	if t := remaining / time.Duration(attempts); t > b.min {
+		return t, true
+	}
+
+	return b.min, true
+}
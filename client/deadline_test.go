@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Now()
+
+	b := newBudget(now.Add(time.Millisecond * 100))
+	b.now = func() time.Time { return now }
+
+	// four attempts share the whole deadline
+	if d, ok := b.timeout(0, 4); !ok || d != time.Millisecond*25 {
+		t.Fatalf("Expected 25ms attempt got %v %v", d, ok)
+	}
+
+	// the backoff is taken out of the share
+	now = now.Add(time.Millisecond * 40)
+	if d, ok := b.timeout(time.Millisecond*20, 2); !ok || d != time.Millisecond*20 {
+		t.Fatalf("Expected 20ms attempt got %v %v", d, ok)
+	}
+
+	// a backoff running into the deadline stops the attempts
+	if _, ok := b.timeout(time.Millisecond*55, 1); ok {
+		t.Fatal("Expected no attempt when the backoff uses up the deadline")
+	}
+
+	// as does nearing the deadline
+	now = now.Add(time.Millisecond * 55)
+	if _, ok := b.timeout(0, 1); ok {
+		t.Fatal("Expected no attempt near the deadline")
+	}
+}
+
+func TestDeadlineAwareRetry(t *testing.T) {
+	start := time.Now()
+	now := start
+
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var timeouts []time.Duration
+
+	// each attempt takes 30ms of the 100ms deadline and times out
+	wrap := func(cf CallFunc) CallFunc {
+		return func(_ context.Context, _ *registry.Node, _ Request, _ interface{}, opts CallOptions) error {
+			timeouts = append(timeouts, opts.ConnectionTimeout)
+			now = now.Add(time.Millisecond * 30)
+
+			return errors.Timeout("test.error", "attempt timeout")
+		}
+	}
+
+	noBackoff := func(context.Context, Request, int) (time.Duration, error) {
+		return 0, nil
+	}
+
+	r := newTestRegistry()
+	c := NewClient(
+		Registry(r),
+		WrapCall(wrap),
+		Retries(5),
+		Backoff(noBackoff),
+		DeadlineAwareRetry(true),
+	)
+
+	if err := c.Options().Selector.Init(selector.Registry(r)); err != nil {
+		t.Fatal("failed to initialize selector", err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Millisecond*100))
+	defer cancel()
+
+	req := c.NewRequest(serviceName, serviceEndpoint, nil)
+
+	err := c.Call(ctx, req, nil, WithAddress("10.1.10.1:8080"))
+	if e := errors.FromError(err); e.Code != 408 || e.Id != "test.error" {
+		t.Fatalf("Expected the last attempt error got %v", err)
+	}
+
+	// the fourth attempt leaves less than the minimum before the deadline
+	if len(timeouts) != 4 {
+		t.Fatalf("Expected 4 of 6 attempts got %d", len(timeouts))
+	}
+
+	expect := []time.Duration{
+		time.Millisecond * 100 / 6,
+		time.Millisecond * 70 / 5,
+		time.Millisecond * 40 / 4,
+		DefaultMinAttemptTimeout,
+	}
+
+	for i, d := range expect {
+		if timeouts[i] != d {
+			t.Fatalf("Expected attempt %d timeout %v got %v", i, d, timeouts[i])
+		}
+	}
+}
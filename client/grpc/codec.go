@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"net/http"
+	"strings"
+
+	"go-micro.org/v5/codec"
+	raw "go-micro.org/v5/codec/bytes"
+	"go-micro.org/v5/codec/json"
+	"go-micro.org/v5/codec/proto"
+	"go-micro.org/v5/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcCodec adapts a go-micro marshaler to a grpc codec. Raw
+// frames are passed through as is.
+type grpcCodec struct {
+	codec.Marshaler
+	name string
+}
+
+func (c grpcCodec) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*raw.Frame); ok {
+		return f.Data, nil
+	}
+
+	return c.Marshaler.Marshal(v)
+}
+
+func (c grpcCodec) Unmarshal(b []byte, v interface{}) error {
+	if f, ok := v.(*raw.Frame); ok {
+		f.Data = b
+		return nil
+	}
+
+	return c.Marshaler.Unmarshal(b, v)
+}
+
+func (c grpcCodec) Name() string {
+	return c.name
+}
+
+// codecFor returns the codec of the content type, proto by default.
+func codecFor(contentType string) grpcCodec {
+	if strings.Contains(contentType, "json") {
+		return grpcCodec{Marshaler: json.Marshaler{}, name: "json"}
+	}
+
+	return grpcCodec{Marshaler: proto.Marshaler{}, name: "proto"}
+}
+
+// statusCodes maps grpc status codes to http status codes.
+var statusCodes = map[codes.Code]int32{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.DeadlineExceeded:   http.StatusRequestTimeout,
+	codes.Canceled:           http.StatusRequestTimeout,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+}
+
+// fromStatus converts the status of a failed call to a go-micro error.
+// Go-micro errors sent as the status message are returned as is.
+func fromStatus(service string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+
+	if e := errors.Parse(st.Message()); e.Code > 0 {
+		return e
+	}
+
+	code, ok := statusCodes[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+
+	return errors.New(service, st.Message(), code)
+}
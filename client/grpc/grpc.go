@@ -0,0 +1,343 @@
+// Package grpc provides a client which calls services over gRPC
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/logger"
+	"go-micro.org/v5/metadata"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/selector"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	gmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultContentType is the content type of requests, proto over gRPC.
+const DefaultContentType = "application/grpc+proto"
+
+// DefaultConnIdleTimeout is how long a connection no call
+// uses is kept open before it's closed.
+var DefaultConnIdleTimeout = time.Minute * 5
+
+type grpcClient struct {
+	opts client.Options
+
+	sync.Mutex
+	// connections by address
+	conns map[string]*poolConn
+}
+
+// poolConn is a pooled connection, its fields are guarded by the client.
+type poolConn struct {
+	*grpc.ClientConn
+
+	addr string
+	// calls using the connection
+	active int
+	// when it was last used
+	used time.Time
+	// removed from the pool, closed once unused
+	evicted bool
+}
+
+// methodToGRPC converts a go-micro endpoint e.g. Say.Hello of the
+// greeter service to the gRPC method /greeter.Say/Hello.
+func methodToGRPC(service, endpoint string) string {
+	// no endpoint or already a grpc method
+	if len(endpoint) == 0 || endpoint[0] == '/' {
+		return endpoint
+	}
+
+	parts := strings.Split(endpoint, ".")
+	if len(parts) != 2 {
+		return endpoint
+	}
+
+	if len(service) == 0 {
+		return fmt.Sprintf("/%s/%s", parts[0], parts[1])
+	}
+
+	return fmt.Sprintf("/%s.%s/%s", service, parts[0], parts[1])
+}
+
+func (g *grpcClient) next(request client.Request, opts client.CallOptions) (selector.Next, error) {
+	// return remote address
+	if len(opts.Address) > 0 {
+		nodes := make([]*registry.Node, len(opts.Address))
+		for i, addr := range opts.Address {
+			nodes[i] = &registry.Node{Address: addr}
+		}
+
+		return selector.Random([]*registry.Service{{Nodes: nodes}}), nil
+	}
+
+	next, err := g.opts.Selector.Select(request.Service(), opts.SelectOptions...)
+	if err != nil {
+		return nil, errors.InternalServerError("go.micro.client", "service %s: %s", request.Service(), err.Error())
+	}
+
+	return next, nil
+}
+
+// conn returns a connection to the address, dialed once and reused
+// until it's evicted or idle. It must be released once the call ends.
+func (g *grpcClient) conn(addr string) (*poolConn, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.sweep()
+
+	pc, ok := g.conns[addr]
+	if !ok || pc.GetState() == connectivity.Shutdown {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(g.credentials()))
+		if err != nil {
+			return nil, err
+		}
+
+		pc = &poolConn{ClientConn: cc, addr: addr}
+		g.conns[addr] = pc
+	}
+
+	pc.active++
+
+	return pc, nil
+}
+
+// credentials secures the connections with TLS if the client's
+// transport is Secure or has a TLSConfig, as the rpc client does.
+func (g *grpcClient) credentials() credentials.TransportCredentials {
+	if g.opts.Transport == nil {
+		return insecure.NewCredentials()
+	}
+
+	topts := g.opts.Transport.Options()
+	if !topts.Secure && topts.TLSConfig == nil {
+		return insecure.NewCredentials()
+	}
+
+	config := topts.TLSConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	return credentials.NewTLS(config)
+}
+
+// release marks the end of a call using the connection.
+func (g *grpcClient) release(pc *poolConn) {
+	g.Lock()
+	defer g.Unlock()
+
+	pc.active--
+	pc.used = time.Now()
+
+	if pc.evicted && pc.active == 0 {
+		pc.Close()
+	}
+}
+
+// evict removes the connection from the pool so the address is
+// dialed again, closing it once the calls using it end.
+func (g *grpcClient) evict(pc *poolConn) {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.conns[pc.addr] == pc {
+		delete(g.conns, pc.addr)
+	}
+
+	pc.evicted = true
+}
+
+// sweep closes the connections idle for longer than the
+// DefaultConnIdleTimeout. The caller must hold the lock.
+func (g *grpcClient) sweep() {
+	for addr, pc := range g.conns {
+		if pc.active == 0 && time.Since(pc.used) > DefaultConnIdleTimeout {
+			pc.Close()
+			delete(g.conns, addr)
+		}
+	}
+}
+
+func (g *grpcClient) Init(opts ...client.Option) error {
+	for _, o := range opts {
+		o(&g.opts)
+	}
+
+	return nil
+}
+
+func (g *grpcClient) Options() client.Options {
+	return g.opts
+}
+
+func (g *grpcClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return newMessage(topic, msg, g.opts.ContentType, opts...)
+}
+
+func (g *grpcClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return newRequest(service, endpoint, req, g.opts.ContentType, opts...)
+}
+
+// Call makes a unary gRPC call to a node of the service. The call
+// wrappers, backoff and retries of the call options apply as with the
+// rpc client.
+func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	callOpts := g.opts.CallOptions
+	for _, o := range opts {
+		o(&callOpts)
+	}
+
+	next, err := g.next(req, callOpts)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok && callOpts.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+
+		defer cancel()
+	}
+
+	// pass on the go-micro metadata
+	if md, ok := metadata.FromContext(ctx); ok {
+		ctx = gmetadata.NewOutgoingContext(ctx, gmetadata.New(md))
+	}
+
+	// wrap the call in reverse
+	gcall := g.call
+	for i := len(callOpts.CallWrappers); i > 0; i-- {
+		gcall = callOpts.CallWrappers[i-1](gcall)
+	}
+
+	call := func(i int) error {
+		t, err := callOpts.Backoff(ctx, req, i)
+		if err != nil {
+			return errors.InternalServerError("go.micro.client", "backoff error: %v", err.Error())
+		}
+
+		if t > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Timeout("go.micro.client", "call timeout: %v", ctx.Err())
+			case <-time.After(t):
+			}
+		}
+
+		node, err := next()
+		if err != nil {
+			return errors.InternalServerError("go.micro.client", "error selecting %s node: %s", req.Service(), err.Error())
+		}
+
+		err = gcall(ctx, node, req, rsp, callOpts)
+		g.opts.Selector.Mark(req.Service(), node, err)
+
+		return err
+	}
+
+	var gerr error
+
+	for i := 0; i <= callOpts.Retries; i++ {
+		err := call(i)
+		if err == nil {
+			return nil
+		}
+
+		retry, rerr := callOpts.Retry(ctx, req, i, err)
+		if rerr != nil {
+			return rerr
+		}
+
+		if !retry {
+			return err
+		}
+
+		g.opts.Logger.Logf(logger.DebugLevel, "Retrying request. Previous attempt failed with: %v", err)
+
+		gerr = err
+	}
+
+	return gerr
+}
+
+// call invokes the gRPC method on the node.
+func (g *grpcClient) call(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	pc, err := g.conn(node.Address)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", "error dialing %s: %v", node.Address, err)
+	}
+	defer g.release(pc)
+
+	start := time.Now()
+
+	err = pc.Invoke(ctx, methodToGRPC(req.Service(), req.Endpoint()), req.Body(), rsp,
+		grpc.ForceCodec(codecFor(req.ContentType())))
+	if err == nil {
+		return nil
+	}
+
+	// the node can't be reached, dial it again next time
+	if status.Code(err) == codes.Unavailable {
+		g.evict(pc)
+	}
+
+	g.opts.Logger.Logf(logger.DebugLevel, "grpc call %s.%s failed after %v: %v", req.Service(), req.Endpoint(), time.Since(start), err)
+
+	return fromStatus(req.Service(), err)
+}
+
+// Stream is not supported, only unary calls are.
+func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return nil, errors.New("go.micro.client", "streaming is not supported by the grpc client", 501)
+}
+
+// Publish is not supported, gRPC has no pub/sub.
+func (g *grpcClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return errors.New("go.micro.client", "publishing is not supported by the grpc client", 501)
+}
+
+func (g *grpcClient) String() string {
+	return "grpc"
+}
+
+// NewClient returns a client which makes unary calls to services serving
+// gRPC. Endpoints such as Say.Hello of the greeter service are called as
+// the gRPC method /greeter.Say/Hello. Nodes are selected with the client's
+// selector so select options and strategies apply as with the rpc client,
+// as do the client wrappers. Connections are secured with the TLSConfig
+// of the client's transport if it's set or the transport is Secure.
+// Connections idle for DefaultConnIdleTimeout are closed.
+func NewClient(opts ...client.Option) client.Client {
+	options := client.NewOptions(opts...)
+
+	// default to proto unless a content type was set
+	if options.ContentType == client.DefaultContentType {
+		options.ContentType = DefaultContentType
+	}
+
+	c := client.Client(&grpcClient{
+		opts:  options,
+		conns: make(map[string]*poolConn),
+	})
+
+	// wrap in reverse
+	for i := len(options.Wrappers); i > 0; i-- {
+		c = options.Wrappers[i-1](c)
+	}
+
+	return c
+}
@@ -0,0 +1,235 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	api "go-micro.org/v5/api/proto"
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/errors"
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/transport"
+	mls "go-micro.org/v5/util/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func TestMethodToGRPC(t *testing.T) {
+	testData := []struct {
+		service  string
+		endpoint string
+		method   string
+	}{
+		{"greeter", "Say.Hello", "/greeter.Say/Hello"},
+		{"", "Say.Hello", "/Say/Hello"},
+		{"greeter", "/pkg.Say/Hello", "/pkg.Say/Hello"},
+		{"greeter", "Hello", "Hello"},
+	}
+
+	for _, d := range testData {
+		if m := methodToGRPC(d.service, d.endpoint); m != d.method {
+			t.Fatalf("%s %s: expected %s got %s", d.service, d.endpoint, d.method, m)
+		}
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	err := fromStatus("greeter", status.Error(codes.InvalidArgument, "missing name"))
+	if e := errors.FromError(err); e.Code != 400 || e.Id != "greeter" || e.Detail != "missing name" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// go-micro errors are passed through
+	err = fromStatus("greeter", status.Error(codes.Unknown, errors.Forbidden("auth", "denied").Error()))
+	if e := errors.FromError(err); e.Code != 403 || e.Id != "auth" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// testServer answers api requests, failing the first calls with a timeout.
+type testServer struct {
+	sync.Mutex
+	calls, fail int
+}
+
+func (s *testServer) serve(_ interface{}, stream grpc.ServerStream) error {
+	var req api.Request
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.calls++
+	fail := s.calls <= s.fail
+	s.Unlock()
+
+	if fail {
+		return status.Error(codes.Unknown, errors.Timeout("greeter", "too slow").Error())
+	}
+
+	return stream.SendMsg(&api.Response{StatusCode: 200, Body: req.Path})
+}
+
+func startServer(t *testing.T, s *testServer, opts ...grpc.ServerOption) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(append(opts, grpc.UnknownServiceHandler(s.serve))...)
+	t.Cleanup(srv.Stop)
+
+	go srv.Serve(l)
+
+	return l.Addr().String()
+}
+
+func noBackoff(context.Context, client.Request, int) (time.Duration, error) {
+	return 0, nil
+}
+
+func TestCallWrappersAndRetries(t *testing.T) {
+	s := &testServer{fail: 1}
+	addr := startServer(t, s)
+
+	var wrapped, calls int
+
+	c := NewClient(
+		client.Wrap(func(c client.Client) client.Client {
+			wrapped++
+			return c
+		}),
+		client.WrapCall(func(cf client.CallFunc) client.CallFunc {
+			return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+				calls++
+				return cf(ctx, node, req, rsp, opts)
+			}
+		}),
+		client.Retries(2),
+		client.Backoff(noBackoff),
+	)
+
+	if wrapped != 1 {
+		t.Fatalf("Expected the client to be wrapped got %d", wrapped)
+	}
+
+	var rsp api.Response
+
+	req := c.NewRequest("greeter", "Say.Hello", &api.Request{Path: "/hello"})
+	if err := c.Call(context.TODO(), req, &rsp, client.WithAddress(addr)); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Body != "/hello" {
+		t.Fatalf("Expected /hello got %s", rsp.Body)
+	}
+
+	// the timed out call is retried through the call wrapper
+	if calls != 2 || s.calls != 2 {
+		t.Fatalf("Expected 2 calls got %d wrapped and %d served", calls, s.calls)
+	}
+
+	// no retries are left
+	s.Lock()
+	s.fail = 10
+	s.Unlock()
+
+	err := c.Call(context.TODO(), req, &rsp, client.WithAddress(addr))
+	if e := errors.FromError(err); e.Code != 408 {
+		t.Fatalf("Expected a timeout got %v", err)
+	}
+
+	if s.calls != 5 {
+		t.Fatalf("Expected 3 more calls got %d", s.calls-2)
+	}
+}
+
+func TestConnEviction(t *testing.T) {
+	addr := startServer(t, &testServer{})
+
+	g := NewClient(client.Retries(0)).(*grpcClient)
+
+	call := func(addr string) error {
+		var rsp api.Response
+
+		req := g.NewRequest("greeter", "Say.Hello", &api.Request{Path: "/hello"})
+
+		return g.Call(context.TODO(), req, &rsp, client.WithAddress(addr))
+	}
+
+	if err := call(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := g.conns[addr]
+	if pc == nil || pc.active != 0 {
+		t.Fatalf("Expected a released connection got %+v", pc)
+	}
+
+	// idle connections are closed
+	defer func(d time.Duration) { DefaultConnIdleTimeout = d }(DefaultConnIdleTimeout)
+	DefaultConnIdleTimeout = 0
+
+	if err := call(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	if pc.GetState() != connectivity.Shutdown || g.conns[addr] == pc {
+		t.Fatal("Expected the idle connection to be closed")
+	}
+
+	DefaultConnIdleTimeout = time.Minute
+
+	// connections to unreachable nodes are evicted
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	if err := call(l.Addr().String()); err == nil {
+		t.Fatal("Expected the call to fail")
+	}
+
+	if _, ok := g.conns[l.Addr().String()]; ok {
+		t.Fatal("Expected the connection to be evicted")
+	}
+}
+
+func TestSecure(t *testing.T) {
+	cert, err := mls.Certificate("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := startServer(t, &testServer{}, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+
+	call := func(c client.Client) error {
+		var rsp api.Response
+
+		req := c.NewRequest("greeter", "Say.Hello", &api.Request{Path: "/hello"})
+
+		return c.Call(context.TODO(), req, &rsp, client.WithAddress(addr))
+	}
+
+	// a plaintext client can't call a tls server
+	if err := call(NewClient(client.Retries(0))); err == nil {
+		t.Fatal("Expected a plaintext call to fail")
+	}
+
+	c := NewClient(
+		client.Retries(0),
+		client.Transport(transport.NewHTTPTransport(transport.TLSConfig(&tls.Config{InsecureSkipVerify: true}))),
+	)
+
+	if err := call(c); err != nil {
+		t.Fatal(err)
+	}
+}
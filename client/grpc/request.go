@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"go-micro.org/v5/client"
+	"go-micro.org/v5/codec"
+)
+
+type request struct {
+	service     string
+	endpoint    string
+	contentType string
+	body        interface{}
+	stream      bool
+}
+
+func newRequest(service, endpoint string, body interface{}, contentType string, opts ...client.RequestOption) client.Request {
+	var options client.RequestOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if len(options.ContentType) > 0 {
+		contentType = options.ContentType
+	}
+
+	return &request{
+		service:     service,
+		endpoint:    endpoint,
+		contentType: contentType,
+		body:        body,
+		stream:      options.Stream,
+	}
+}
+
+func (r *request) Service() string {
+	return r.service
+}
+
+func (r *request) Method() string {
+	return r.endpoint
+}
+
+func (r *request) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *request) ContentType() string {
+	return r.contentType
+}
+
+func (r *request) Body() interface{} {
+	return r.body
+}
+
+// Codec is nil, requests are encoded by grpc.
+func (r *request) Codec() codec.Writer {
+	return nil
+}
+
+func (r *request) Stream() bool {
+	return r.stream
+}
+
+type message struct {
+	topic       string
+	contentType string
+	payload     interface{}
+}
+
+func newMessage(topic string, payload interface{}, contentType string, opts ...client.MessageOption) client.Message {
+	var options client.MessageOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if len(options.ContentType) > 0 {
+		contentType = options.ContentType
+	}
+
+	return &message{
+		topic:       topic,
+		contentType: contentType,
+		payload:     payload,
+	}
+}
+
+func (m *message) Topic() string {
+	return m.topic
+}
+
+func (m *message) ContentType() string {
+	return m.contentType
+}
+
+func (m *message) Payload() interface{} {
+	return m.payload
+}
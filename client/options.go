@@ -94,10 +94,14 @@ type CallOptions struct {
 	StreamTimeout time.Duration
 	// Duration to cache the response for
 	CacheExpiry time.Duration
+	// Skip the response cache
+	CacheBypass bool
 	// Transport Dial Timeout. Used for initial dial to establish a connection.
 	DialTimeout time.Duration
 	// Number of Call attempts
 	Retries int
+	// Share the time left before the deadline between attempts
+	DeadlineAware bool
 	// Use the services own auth token
 	ServiceToken bool
 	// ConnClose sets the Connection: close header.
@@ -243,6 +247,15 @@ func Retries(i int) Option {
 	}
 }
 
+// DeadlineAwareRetry splits the time left before the request deadline,
+// less any backoff, between the remaining attempts and stops retrying
+// once too little time remains.
+func DeadlineAwareRetry(b bool) Option {
+	return func(o *Options) {
+		o.CallOptions.DeadlineAware = b
+	}
+}
+
 // Retry sets the retry function to be used when re-trying.
 func Retry(fn RetryFunc) Option {
 	return func(o *Options) {
@@ -377,6 +390,13 @@ func WithCache(c time.Duration) CallOption {
 	}
 }
 
+// WithCacheBypass is a CallOption which skips the response cache.
+func WithCacheBypass() CallOption {
+	return func(o *CallOptions) {
+		o.CacheBypass = true
+	}
+}
+
 func WithMessageContentType(ct string) MessageOption {
 	return func(o *MessageOptions) {
 		o.ContentType = ct
@@ -468,14 +468,33 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 		rcall = callOpts.CallWrappers[i-1](rcall)
 	}
 
+	// share the time left between the attempts
+	var bgt *budget
+	if d, ok := ctx.Deadline(); ok && callOpts.DeadlineAware {
+		bgt = newBudget(d)
+	}
+
 	// return errors.New("go.micro.client", "request timeout", 408)
-	call := func(i int) error {
+	call := func(i int, last error) error {
 		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, request, i)
+		t, err := callOpts.Backoff(withLastError(ctx, last), request, i)
 		if err != nil {
 			return merrors.InternalServerError("go.micro.client", "backoff error: %v", err.Error())
 		}
 
+		opts := callOpts
+
+		if bgt != nil {
+			timeout, ok := bgt.timeout(t, callOpts.Retries-i+1)
+			if !ok {
+				return errDeadline
+			}
+
+			if opts.ConnectionTimeout == 0 || timeout < opts.ConnectionTimeout {
+				opts.ConnectionTimeout = timeout
+			}
+		}
+
 		// only sleep if greater than 0
 		if t.Seconds() > 0 {
 			time.Sleep(t)
@@ -497,7 +516,7 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 		}
 
 		// make the call
-		err = rcall(ctx, node, request, response, callOpts)
+		err = rcall(ctx, node, request, response, opts)
 		r.opts.Selector.Mark(service, node, err)
 
 		return err
@@ -517,9 +536,9 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 	var gerr error
 
 	for i := 0; i <= retries; i++ {
-		go func(i int) {
-			ch <- call(i)
-		}(i)
+		go func(i int, last error) {
+			ch <- call(i, last)
+		}(i, gerr)
 
 		select {
 		case <-ctx.Done():
@@ -530,6 +549,15 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 				return nil
 			}
 
+			// not enough time left for another attempt
+			if err == errDeadline {
+				if gerr != nil {
+					return gerr
+				}
+
+				return merrors.Timeout("go.micro.client", "call timeout: %v", err)
+			}
+
 			retry, rerr := callOpts.Retry(ctx, request, i, err)
 			if rerr != nil {
 				return rerr
@@ -569,9 +597,9 @@ func (r *rpcClient) Stream(ctx context.Context, request Request, opts ...CallOpt
 	default:
 	}
 
-	call := func(i int) (Stream, error) {
+	call := func(i int, last error) (Stream, error) {
 		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, request, i)
+		t, err := callOpts.Backoff(withLastError(ctx, last), request, i)
 		if err != nil {
 			return nil, merrors.InternalServerError("go.micro.client", "backoff error: %v", err.Error())
 		}
@@ -619,10 +647,10 @@ func (r *rpcClient) Stream(ctx context.Context, request Request, opts ...CallOpt
 	var grr error
 
 	for i := 0; i <= retries; i++ {
-		go func(i int) {
-			s, err := call(i)
+		go func(i int, last error) {
+			s, err := call(i, last)
 			ch <- response{s, err}
-		}(i)
+		}(i, grr)
 
 		select {
 		case <-ctx.Done():
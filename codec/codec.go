@@ -51,6 +51,21 @@ type Marshaler interface {
 	String() string
 }
 
+// Validator is implemented by messages which check their own fields,
+// e.g. those generated by protoc-gen-validate.
+type Validator interface {
+	Validate() error
+}
+
+// Validate returns the error of v's Validate method if it's a Validator.
+func Validate(v interface{}) error {
+	if vv, ok := v.(Validator); ok {
+		return vv.Validate()
+	}
+
+	return nil
+}
+
 // Message represents detailed information about
 // the communication, likely followed by the body.
 // In the case of an error, body may be nil.
@@ -0,0 +1,109 @@
+// Package gzip provides a gzip compressing marshaler wrapper
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"go-micro.org/v5/codec"
+)
+
+const (
+	// ContentEncoding is the encoding applied by the marshaler,
+	// as used in the Content-Encoding header.
+	ContentEncoding = "gzip"
+)
+
+var (
+	// DefaultMaxSize is the size data is decompressed to at most
+	// if the marshaler's MaxSize isn't set.
+	DefaultMaxSize int64 = 1024 * 1024 * 10
+
+	// ErrTooLarge is returned for data which decompresses
+	// to more than the maximum size.
+	ErrTooLarge = errors.New("gzip: decompressed data exceeds the maximum size")
+)
+
+// Marshaler compresses the output of the wrapped marshaler and
+// decompresses the input before it's unmarshaled.
+type Marshaler struct {
+	codec.Marshaler
+
+	// Level is the gzip compression level,
+	// gzip.DefaultCompression if zero
+	Level int
+	// MaxSize is the size data is decompressed to at
+	// most, DefaultMaxSize if zero
+	MaxSize int64
+}
+
+func (m Marshaler) Marshal(v interface{}) ([]byte, error) {
+	b, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	level := m.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (m Marshaler) Unmarshal(data []byte, v interface{}) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	max := m.MaxSize
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+
+	// a few bytes may decompress to gigabytes
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return err
+	}
+
+	if int64(len(b)) > max {
+		return ErrTooLarge
+	}
+
+	return m.Marshaler.Unmarshal(b, v)
+}
+
+func (m Marshaler) String() string {
+	return ContentEncoding + "+" + m.Marshaler.String()
+}
+
+// NewMarshaler returns a gzip compressing marshaler wrapping m. Register it
+// against an encoded content type to make it negotiable, e.g.
+//
+//	codec.RegisterMarshaler("application/json+gzip", gzip.NewMarshaler(json.Marshaler{}))
+func NewMarshaler(m codec.Marshaler) codec.Marshaler {
+	return Marshaler{
+		Marshaler: m,
+		Level:     gzip.DefaultCompression,
+	}
+}
@@ -0,0 +1,74 @@
+package gzip
+
+import (
+	"strings"
+	"testing"
+
+	"go-micro.org/v5/codec/json"
+)
+
+func TestMarshaler(t *testing.T) {
+	m := NewMarshaler(json.Marshaler{})
+
+	if have, want := m.String(), "gzip+json"; have != want {
+		t.Fatalf("Expected %s got %s", want, have)
+	}
+
+	in := map[string]string{"data": strings.Repeat("hello world ", 1000)}
+
+	b, err := m.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshaler{}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) >= len(raw) {
+		t.Fatalf("Expected compressed size %d to be smaller than %d", len(b), len(raw))
+	}
+
+	var out map[string]string
+	if err := m.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out["data"] != in["data"] {
+		t.Fatal("Expected round trip to preserve data")
+	}
+
+	if err := m.Unmarshal(raw, &out); err == nil {
+		t.Fatal("Expected error unmarshaling uncompressed data")
+	}
+}
+
+func TestMarshalerLimits(t *testing.T) {
+	in := map[string]string{"data": strings.Repeat("a", 1024*1024)}
+
+	raw, err := json.Marshaler{}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the zero level compresses
+	b, err := Marshaler{Marshaler: json.Marshaler{}}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) >= len(raw) {
+		t.Fatalf("Expected compressed size %d to be smaller than %d", len(b), len(raw))
+	}
+
+	var out map[string]string
+
+	if err := (Marshaler{Marshaler: json.Marshaler{}, MaxSize: 1024}).Unmarshal(b, &out); err != ErrTooLarge {
+		t.Fatalf("Expected %v got %v", ErrTooLarge, err)
+	}
+
+	if err := (Marshaler{Marshaler: json.Marshaler{}, MaxSize: int64(len(raw))}).Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+}
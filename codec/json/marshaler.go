@@ -3,14 +3,32 @@ package json
 import (
 	"encoding/json"
 
+	"go-micro.org/v5/codec"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
-type Marshaler struct{}
+func init() {
+	codec.RegisterMarshaler("application/json", Marshaler{})
+}
+
+// Marshaler marshals proto messages with protojson and other values
+// with encoding/json.
+type Marshaler struct {
+	// Validate unmarshaled values implementing codec.Validator
+	Validate bool
+	// Fields is a comma separated field mask of the proto message
+	// fields marshaled, e.g. id,user.name, see ParseFields. All
+	// are if empty.
+	Fields string
+}
 
 func (j Marshaler) Marshal(v interface{}) ([]byte, error) {
 	if pb, ok := v.(proto.Message); ok {
+		if len(j.Fields) > 0 {
+			pb = Mask(pb, ParseFields(j.Fields)...)
+		}
+
 		buf, err := protojson.Marshal(pb)
 		if err != nil {
 			return nil, err
@@ -21,10 +39,18 @@ func (j Marshaler) Marshal(v interface{}) ([]byte, error) {
 }
 
 func (j Marshaler) Unmarshal(d []byte, v interface{}) error {
+	var err error
 	if pb, ok := v.(proto.Message); ok {
-		return protojson.Unmarshal(d, pb)
+		err = protojson.Unmarshal(d, pb)
+	} else {
+		err = json.Unmarshal(d, v)
+	}
+
+	if err != nil || !j.Validate {
+		return err
 	}
-	return json.Unmarshal(d, v)
+
+	return codec.Validate(v)
 }
 
 func (j Marshaler) String() string {
@@ -0,0 +1,39 @@
+package json
+
+import (
+	"errors"
+	"testing"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func (u *user) Validate() error {
+	if len(u.Name) == 0 {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+func TestMarshalerValidate(t *testing.T) {
+	testData := []struct {
+		validate bool
+		data     string
+		err      bool
+	}{
+		{false, `{}`, false},
+		{true, `{}`, true},
+		{true, `{"name":"john"}`, false},
+	}
+
+	for _, d := range testData {
+		var u user
+
+		err := Marshaler{Validate: d.validate}.Unmarshal([]byte(d.data), &u)
+		if (err != nil) != d.err {
+			t.Fatalf("validate %v %s: unexpected error %v", d.validate, d.data, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package json
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldTree is a parsed field mask, a nil subtree selects the whole field.
+type fieldTree map[string]fieldTree
+
+func newFieldTree(paths []string) fieldTree {
+	tree := make(fieldTree)
+
+	for _, p := range paths {
+		node := tree
+
+		parts := strings.Split(strings.TrimSpace(p), ".")
+		for i, part := range parts {
+			sub, ok := node[part]
+
+			// a shorter path already selects the whole field
+			if ok && sub == nil {
+				break
+			}
+
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+
+			if !ok {
+				sub = make(fieldTree)
+				node[part] = sub
+			}
+
+			node = sub
+		}
+	}
+
+	return tree
+}
+
+// ParseFields splits a comma separated field mask such as the
+// value of a fields query parameter e.g. id,user.name.
+func ParseFields(s string) []string {
+	var fields []string
+
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); len(f) > 0 {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// Mask returns a copy of the message with only the fields of the mask
+// set. Paths match the proto or json field names, unknown ones are
+// ignored. Repeated messages are masked per element, maps as a whole.
+func Mask(m proto.Message, paths ...string) proto.Message {
+	if len(paths) == 0 {
+		return m
+	}
+
+	c := proto.Clone(m)
+	prune(c.ProtoReflect(), newFieldTree(paths))
+
+	return c
+}
+
+func prune(m protoreflect.Message, tree fieldTree) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := tree[string(fd.Name())]
+		if !ok {
+			sub, ok = tree[fd.JSONName()]
+		}
+
+		switch {
+		case !ok:
+			m.Clear(fd)
+		case sub == nil, fd.IsMap(), fd.Message() == nil:
+			// keep the whole field
+		case fd.IsList():
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				prune(l.Get(i).Message(), sub)
+			}
+		default:
+			prune(v.Message(), sub)
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,54 @@
+package json
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func TestMask(t *testing.T) {
+	in := &typepb.Type{
+		Name: "user",
+		Fields: []*typepb.Field{
+			{Name: "id", Number: 1},
+			{Name: "name", Number: 2},
+		},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "user.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+	}
+
+	b, err := Marshaler{Fields: "name, fields.name,sourceContext"}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(typepb.Type)
+	if err := (Marshaler{}).Unmarshal(b, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &typepb.Type{
+		Name: "user",
+		Fields: []*typepb.Field{
+			{Name: "id"},
+			{Name: "name"},
+		},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "user.proto"},
+	}
+
+	if !proto.Equal(out, want) {
+		t.Fatalf("Expected %v got %v", want, out)
+	}
+
+	// the input is not modified
+	if in.Syntax != typepb.Syntax_SYNTAX_PROTO3 || in.Fields[0].Number != 1 {
+		t.Fatalf("Expected the message to be unchanged got %v", in)
+	}
+
+	// the marshaler is comparable, e.g. by codec lookups
+	if (Marshaler{Fields: "name"}) != (Marshaler{Fields: "name"}) {
+		t.Fatal("Expected equal marshalers")
+	}
+}
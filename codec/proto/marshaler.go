@@ -1,19 +1,41 @@
 package proto
 
 import (
+	"fmt"
+
 	"go-micro.org/v5/codec"
 	"google.golang.org/protobuf/proto"
 )
 
-type Marshaler struct{}
+func init() {
+	codec.RegisterMarshaler("application/proto", Marshaler{})
+	codec.RegisterMarshaler("application/protobuf", Marshaler{})
+}
 
-func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+// Marshaler marshals proto messages.
+type Marshaler struct {
+	// Validate unmarshaled messages implementing codec.Validator
+	Validate bool
+	// Deterministic orders map entries so equal messages
+	// marshal to equal bytes e.g. for content hashing
+	Deterministic bool
+}
+
+// NewMarshaler returns a proto marshaler. Deterministic marshaling sorts
+// map keys at a small CPU cost, the zero Marshaler doesn't.
+func NewMarshaler(deterministic bool) Marshaler {
+	return Marshaler{
+		Deterministic: deterministic,
+	}
+}
+
+func (m Marshaler) Marshal(v interface{}) ([]byte, error) {
 	pb, ok := v.(proto.Message)
 	if !ok {
-		return nil, codec.ErrInvalidMessage
+		return nil, fmt.Errorf("%w: got %T", codec.ErrInvalidMessage, v)
 	}
 
-	buf, err := proto.Marshal(pb)
+	buf, err := proto.MarshalOptions{Deterministic: m.Deterministic}.Marshal(pb)
 	if err != nil {
 		return nil, err
 	}
@@ -21,13 +43,17 @@ func (Marshaler) Marshal(v interface{}) ([]byte, error) {
 	return buf, nil
 }
 
-func (Marshaler) Unmarshal(data []byte, v interface{}) error {
+func (m Marshaler) Unmarshal(data []byte, v interface{}) error {
 	pb, ok := v.(proto.Message)
 	if !ok {
-		return codec.ErrInvalidMessage
+		return fmt.Errorf("%w: got %T", codec.ErrInvalidMessage, v)
+	}
+
+	if err := proto.Unmarshal(data, pb); err != nil || !m.Validate {
+		return err
 	}
 
-	return proto.Unmarshal(data, pb)
+	return codec.Validate(v)
 }
 
 func (Marshaler) String() string {
@@ -0,0 +1,39 @@
+package proto
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestDeterministic(t *testing.T) {
+	fields := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		fields[fmt.Sprintf("key%d", i)] = i
+	}
+
+	m := NewMarshaler(true)
+
+	var first []byte
+
+	for i := 0; i < 10; i++ {
+		// a new map each time so the iteration order differs
+		st, err := structpb.NewStruct(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := m.Marshal(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if first == nil {
+			first = b
+		} else if !bytes.Equal(first, b) {
+			t.Fatal("Expected deterministic output")
+		}
+	}
+}
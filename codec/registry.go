@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	mtx        sync.RWMutex
+	marshalers = map[string]Marshaler{}
+)
+
+// RegisterMarshaler registers a marshaler for the given content type so it
+// can be selected by the api handlers and clients. Registering a content
+// type twice replaces the existing marshaler.
+func RegisterMarshaler(contentType string, m Marshaler) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	marshalers[contentType] = m
+}
+
+// GetMarshaler returns the marshaler registered for the content type.
+func GetMarshaler(contentType string) (Marshaler, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	m, ok := marshalers[contentType]
+
+	return m, ok
+}
+
+// ContentTypes returns the sorted list of registered content types.
+func ContentTypes() []string {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	cts := make([]string, 0, len(marshalers))
+	for ct := range marshalers {
+		cts = append(cts, ct)
+	}
+
+	sort.Strings(cts)
+
+	return cts
+}
@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"testing"
+)
+
+type testMarshaler struct {
+	name string
+}
+
+func (m testMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return []byte(m.name), nil
+}
+
+func (m testMarshaler) Unmarshal(b []byte, v interface{}) error {
+	return nil
+}
+
+func (m testMarshaler) String() string {
+	return m.name
+}
+
+func TestRegisterMarshaler(t *testing.T) {
+	if _, ok := GetMarshaler("application/x-registry-test"); ok {
+		t.Fatal("Expected no marshaler before registering one")
+	}
+
+	RegisterMarshaler("application/x-registry-test", testMarshaler{"a"})
+
+	m, ok := GetMarshaler("application/x-registry-test")
+	if !ok || m.String() != "a" {
+		t.Fatalf("Expected the registered marshaler got %v", m)
+	}
+
+	// registering again replaces it
+	RegisterMarshaler("application/x-registry-test", testMarshaler{"b"})
+
+	if m, _ := GetMarshaler("application/x-registry-test"); m.String() != "b" {
+		t.Fatalf("Expected the replaced marshaler got %v", m)
+	}
+
+	RegisterMarshaler("application/x-registry-a", testMarshaler{"c"})
+
+	var cts []string
+	for _, ct := range ContentTypes() {
+		if ct == "application/x-registry-test" || ct == "application/x-registry-a" {
+			cts = append(cts, ct)
+		}
+	}
+
+	if len(cts) != 2 || cts[0] != "application/x-registry-a" {
+		t.Fatalf("Expected the sorted content types got %v", cts)
+	}
+}
@@ -446,6 +446,15 @@ func (c *cache) GetService(service string, opts ...registry.GetOption) ([]*regis
 	return services, nil
 }
 
+// Invalidate drops the cached nodes of the service so the next lookup
+// asks the registry. The cache is kept while the registry is failing.
+func (c *cache) Invalidate(service string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.del(service)
+}
+
 func (c *cache) Stop() {
 	c.Lock()
 	defer c.Unlock()
@@ -182,11 +182,41 @@ func (m *memRegistry) Register(s *Service, opts ...RegisterOption) error {
 		return nil
 	}
 
-	// refresh TTL and timestamp
+	// refresh TTL, timestamp and metadata
+	updated := false
+
 	for _, n := range s.Nodes {
 		logger.Logf(log.DebugLevel, "Updated registration for service: %s, version: %s", s.Name, s.Version)
-		m.records[s.Name][s.Version].Nodes[n.Id].TTL = options.TTL
-		m.records[s.Name][s.Version].Nodes[n.Id].LastSeen = time.Now()
+
+		rn := m.records[s.Name][s.Version].Nodes[n.Id]
+
+		metadata := make(map[string]string)
+		for k, v := range n.Metadata {
+			metadata[k] = v
+
+			if rv, ok := rn.Metadata[k]; !ok || rv != v {
+				updated = true
+			}
+		}
+
+		if len(rn.Metadata) != len(metadata) {
+			updated = true
+		}
+
+		// replaced rather than modified as the node
+		// may be the one of a registered service
+		rn.Node = &Node{
+			Id:       rn.Id,
+			Address:  rn.Address,
+			Metadata: metadata,
+		}
+		rn.TTL = options.TTL
+		rn.LastSeen = time.Now()
+	}
+
+	// watchers learn of changed metadata e.g. a draining node
+	if updated {
+		go m.sendEvent(&Result{Action: "update", Service: s})
 	}
 
 	return nil
@@ -242,3 +242,55 @@ func TestMemoryRegistryTTLConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryRegistryMetadata(t *testing.T) {
+	m := NewMemoryRegistry()
+
+	w, err := m.Watch(WatchService("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	service := func(md map[string]string) *Service {
+		return &Service{
+			Name:    "foo",
+			Version: "1.0.0",
+			Nodes:   []*Node{{Id: "foo-1", Address: "localhost:9999", Metadata: md}},
+		}
+	}
+
+	md := map[string]string{"status": "serving"}
+
+	for _, s := range []*Service{service(md), service(md), service(map[string]string{"status": "draining"})} {
+		if err := m.Register(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svcs, err := m.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := svcs[0].Nodes[0].Metadata["status"]; status != "draining" {
+		t.Fatalf("Expected the re-registered metadata got %q", status)
+	}
+
+	// an event for the new node and one for the changed metadata,
+	// in either order as they're sent asynchronously
+	statuses := make(map[string]bool)
+
+	for i := 0; i < 2; i++ {
+		res, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		statuses[res.Service.Nodes[0].Metadata["status"]] = true
+	}
+
+	if !statuses["serving"] || !statuses["draining"] {
+		t.Fatalf("Expected an update event with the draining status got %v", statuses)
+	}
+}
@@ -424,7 +424,7 @@ func (r *runtime) Logs(s *Service, options ...LogsOption) (LogStream, error) {
 					ret.Stop()
 					return
 				}
-				ret.stream <- LogRecord{Message: line.Text}
+				ret.stream <- LogRecord{Timestamp: line.Time, Message: line.Text}
 			case <-ret.stop:
 				return
 			}
@@ -3,6 +3,8 @@ package kubernetes
 
 import (
 	"fmt"
+	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -350,10 +352,88 @@ type kubeStream struct {
 	// the k8s log stream
 	stream chan runtime.LogRecord
 	stop   chan bool
+	// what to do when the stream is full
+	policy runtime.LogsPolicy
+	// records to be ordered, if enabled
+	merge chan runtime.LogRecord
+	// the open pod log bodies
+	bodies []io.Closer
+	// the pod streamers
+	wg sync.WaitGroup
 	// the stop chan
 	sync.Mutex
 }
 
+// send queues a record, to be ordered if enabled.
+func (k *kubeStream) send(r runtime.LogRecord) {
+	if k.merge == nil {
+		k.push(r)
+		return
+	}
+
+	select {
+	case k.merge <- r:
+	case <-k.stop:
+	}
+}
+
+// order collects the records sent by the pod streamers and
+// every window emits them sorted by timestamp.
+func (k *kubeStream) order(window time.Duration) {
+	defer k.wg.Done()
+
+	t := time.NewTicker(window)
+	defer t.Stop()
+
+	var records []runtime.LogRecord
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case r := <-k.merge:
+			records = append(records, r)
+		case <-t.C:
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].Timestamp.Before(records[j].Timestamp)
+			})
+
+			for _, r := range records {
+				k.push(r)
+			}
+
+			records = records[:0]
+		}
+	}
+}
+
+// push queues a record, applying the buffer policy when the stream is full.
+func (k *kubeStream) push(r runtime.LogRecord) {
+	if k.policy != runtime.LogsDropOldest {
+		select {
+		case k.stream <- r:
+		case <-k.stop:
+		}
+
+		return
+	}
+
+	for {
+		select {
+		case k.stream <- r:
+			return
+		case <-k.stop:
+			return
+		default:
+			// make room by dropping the oldest record
+			select {
+			case <-k.stream:
+			default:
+			}
+		}
+	}
+}
+
 func (k *kubeStream) Error() error {
 	return k.err
 }
@@ -362,6 +442,25 @@ func (k *kubeStream) Chan() chan runtime.LogRecord {
 	return k.stream
 }
 
+// track registers a pod log body to be closed on stop,
+// returning false if the stream is already stopped.
+func (k *kubeStream) track(body io.Closer) bool {
+	k.Lock()
+	defer k.Unlock()
+
+	select {
+	case <-k.stop:
+		return false
+	default:
+	}
+
+	k.bodies = append(k.bodies, body)
+
+	return true
+}
+
+// Stop closes the pod log bodies to unblock the streamers. The
+// stream is closed once they have all returned.
 func (k *kubeStream) Stop() error {
 	k.Lock()
 	defer k.Unlock()
@@ -370,8 +469,19 @@ func (k *kubeStream) Stop() error {
 		return nil
 	default:
 		close(k.stop)
-		close(k.stream)
 	}
+
+	for _, b := range k.bodies {
+		b.Close()
+	}
+
+	k.bodies = nil
+
+	go func() {
+		k.wg.Wait()
+		close(k.stream)
+	}()
+
 	return nil
 }
 
@@ -1,12 +1,14 @@
 // Package kubernetes taken from https://github.com/micro/go-micro/blob/master/debug/log/kubernetes/kubernetes.go
 // There are some modifications compared to the other files as
 // this package doesn't provide write functionality.
-// With the write functionality gone, structured logs also go away.
+// Structured logs are parsed from JSON lines with runtime.LogsFormat.
 package kubernetes
 
 import (
 	"bufio"
+	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
 
 	"go-micro.org/v5/logger"
@@ -14,6 +16,9 @@ import (
 	"go-micro.org/v5/util/kubernetes/client"
 )
 
+// DefaultLogsBufferSize is the number of records buffered per log stream.
+var DefaultLogsBufferSize = 100
+
 type klog struct {
 	options     runtime.LogsOptions
 	client      client.Client
@@ -23,12 +28,22 @@ type klog struct {
 func (k *klog) podLogStream(podName string, stream *kubeStream) error {
 	p := make(map[string]string)
 	p["follow"] = "true"
+	p["container"] = k.options.Container
+
+	// timestamps are needed to order the records
+	if k.options.OrderWindow > 0 {
+		p["timestamps"] = "true"
+	}
 
 	opts := []client.LogOption{
 		client.LogParams(p),
 		client.LogNamespace(k.options.Namespace),
 	}
 
+	if k.options.Context != nil {
+		opts = append(opts, client.LogContext(k.options.Context))
+	}
+
 	// get the logs for the pod
 	body, err := k.client.Log(&client.Resource{
 		Name: podName,
@@ -45,28 +60,87 @@ func (k *klog) podLogStream(podName string, stream *kubeStream) error {
 		return err
 	}
 
-	s := bufio.NewScanner(body)
 	defer body.Close()
 
+	// stop closes the body to unblock the scanner
+	if !stream.track(body) {
+		return stream.Error()
+	}
+
+	s := bufio.NewScanner(body)
+
 	for {
 		select {
 		case <-stream.stop:
 			return stream.Error()
 		default:
 			if s.Scan() {
-				record := runtime.LogRecord{
-					Message: s.Text(),
-				}
-				stream.stream <- record
+				stream.send(k.record(s.Text()))
 			} else {
 				// TODO: is there a blocking call
 				// rather than a sleep loop?
-				time.Sleep(time.Second)
+				select {
+				case <-stream.stop:
+				case <-time.After(time.Second):
+				}
 			}
 		}
 	}
 }
 
+// record parses a log line in the configured format, falling
+// back to the raw line as the message.
+func (k *klog) record(line string) runtime.LogRecord {
+	record := runtime.LogRecord{
+		Message: line,
+	}
+
+	// strip the timestamp prefix if requested
+	if k.options.OrderWindow > 0 {
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			if ts, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				record.Timestamp = ts
+				record.Message = line[i+1:]
+				line = record.Message
+			}
+		}
+	}
+
+	if k.options.Format != "json" {
+		return record
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return record
+	}
+
+	record.Metadata = make(map[string]string, len(fields))
+
+	for key, val := range fields {
+		if v, ok := val.(string); ok {
+			record.Metadata[key] = v
+			continue
+		}
+
+		b, err := json.Marshal(val)
+		if err != nil {
+			continue
+		}
+
+		record.Metadata[key] = string(b)
+	}
+
+	for _, key := range []string{"msg", "message"} {
+		if v, ok := record.Metadata[key]; ok {
+			record.Message = v
+			break
+		}
+	}
+
+	return record
+}
+
 func (k *klog) getMatchingPods() ([]string, error) {
 	r := &client.Resource{
 		Kind:  "pod",
@@ -128,11 +202,17 @@ func (k *klog) Read() ([]runtime.LogRecord, error) {
 			logParams["follow"] = "true"
 		}
 
+		logParams["container"] = k.options.Container
+
 		opts := []client.LogOption{
 			client.LogParams(logParams),
 			client.LogNamespace(k.options.Namespace),
 		}
 
+		if k.options.Context != nil {
+			opts = append(opts, client.LogContext(k.options.Context))
+		}
+
 		logs, err := k.client.Log(&client.Resource{
 			Name: pod,
 			Kind: "pod",
@@ -146,12 +226,15 @@ func (k *klog) Read() ([]runtime.LogRecord, error) {
 		s := bufio.NewScanner(logs)
 
 		for s.Scan() {
-			record := runtime.LogRecord{
-				Message: s.Text(),
-			}
+			record := k.record(s.Text())
 			// record.Metadata["pod"] = pod
 			records = append(records, record)
 		}
+
+		// a cancelled context aborts the read
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
 	}
 
 	// sort the records
@@ -160,6 +243,24 @@ func (k *klog) Read() ([]runtime.LogRecord, error) {
 	return records, nil
 }
 
+// newStream returns a stream buffered as configured.
+func (k *klog) newStream() *kubeStream {
+	stream := &kubeStream{
+		stream: make(chan runtime.LogRecord, k.options.BufferSize),
+		stop:   make(chan bool),
+		policy: k.options.BufferPolicy,
+	}
+
+	if k.options.OrderWindow > 0 {
+		stream.merge = make(chan runtime.LogRecord, k.options.BufferSize)
+		stream.wg.Add(1)
+
+		go stream.order(k.options.OrderWindow)
+	}
+
+	return stream
+}
+
 func (k *klog) Stream() (runtime.LogStream, error) {
 	// find the matching pods
 	pods, err := k.getMatchingPods()
@@ -167,14 +268,26 @@ func (k *klog) Stream() (runtime.LogStream, error) {
 		return nil, err
 	}
 
-	stream := &kubeStream{
-		stream: make(chan runtime.LogRecord),
-		stop:   make(chan bool),
+	stream := k.newStream()
+
+	// stop the stream when the context is done
+	if k.options.Context != nil {
+		go func() {
+			select {
+			case <-k.options.Context.Done():
+				stream.Stop()
+			case <-stream.stop:
+			}
+		}()
 	}
 
 	// stream from the individual pods
 	for _, pod := range pods {
+		stream.wg.Add(1)
+
 		go func(podName string) {
+			defer stream.wg.Done()
+
 			err := k.podLogStream(podName, stream)
 			if err != nil {
 				logger.DefaultLogger.Log(logger.ErrorLevel, err)
@@ -194,6 +307,15 @@ func newLog(c client.Client, serviceName string, opts ...runtime.LogsOption) *kl
 		o(&options)
 	}
 
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultLogsBufferSize
+	}
+
+	// the service container is named after the service
+	if len(options.Container) == 0 {
+		options.Container = client.Format(serviceName)
+	}
+
 	klog := &klog{
 		serviceName: serviceName,
 		client:      c,
@@ -0,0 +1,185 @@
+package kubernetes
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/runtime"
+	"go-micro.org/v5/util/kubernetes/client"
+)
+
+// testClient serves pods whose logs block until closed.
+type testClient struct {
+	client.Client
+	pods []string
+	// logs returned instead of blocking
+	logs string
+	// params of the last log request
+	params map[string]string
+	sync.Mutex
+}
+
+func (c *testClient) Get(r *client.Resource, opts ...client.GetOption) error {
+	list := r.Value.(*client.PodList)
+
+	for _, p := range c.pods {
+		list.Items = append(list.Items, client.Pod{
+			Metadata: &client.Metadata{
+				Name:   p,
+				Labels: map[string]string{"name": client.Format("foo")},
+			},
+		})
+	}
+
+	return nil
+}
+
+func (c *testClient) Log(r *client.Resource, opts ...client.LogOption) (io.ReadCloser, error) {
+	var options client.LogOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c.Lock()
+	c.params = options.Params
+	c.Unlock()
+
+	if len(c.logs) > 0 {
+		return io.NopCloser(strings.NewReader(c.logs)), nil
+	}
+
+	pr, _ := io.Pipe()
+	return pr, nil
+}
+
+func TestLogsBufferSize(t *testing.T) {
+	k := newLog(nil, "foo")
+	if s := k.newStream(); cap(s.stream) != DefaultLogsBufferSize {
+		t.Fatalf("Expected buffer size %d got %d", DefaultLogsBufferSize, cap(s.stream))
+	}
+
+	k = newLog(nil, "foo", runtime.LogsBufferSize(5), runtime.LogsBufferPolicy(runtime.LogsDropOldest))
+
+	s := k.newStream()
+	if cap(s.stream) != 5 {
+		t.Fatalf("Expected buffer size 5 got %d", cap(s.stream))
+	}
+
+	// the sixth record drops the first
+	for i := 0; i < 6; i++ {
+		s.send(runtime.LogRecord{Message: strconv.Itoa(i)})
+	}
+
+	if r := <-s.stream; r.Message != "1" {
+		t.Fatalf("Expected oldest record to be dropped, got %s", r.Message)
+	}
+}
+
+func TestLogsStreamStop(t *testing.T) {
+	k := newLog(&testClient{pods: []string{"foo-1", "foo-2", "foo-3"}}, "foo")
+
+	stream, err := k.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// let the streamers block on the bodies
+	time.Sleep(time.Millisecond * 50)
+
+	if err := stream.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream is closed once all the streamers return
+	select {
+	case _, ok := <-stream.Chan():
+		if ok {
+			t.Fatal("Expected stream to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the pod streamers to stop")
+	}
+}
+
+func TestLogsFormat(t *testing.T) {
+	k := newLog(nil, "foo", runtime.LogsFormat("json"))
+
+	r := k.record(`{"level":"info","msg":"started","port":8080}`)
+	if r.Message != "started" {
+		t.Fatalf("Expected message started got %s", r.Message)
+	}
+
+	if r.Metadata["level"] != "info" || r.Metadata["port"] != "8080" {
+		t.Fatalf("Unexpected metadata %v", r.Metadata)
+	}
+
+	// plain lines are kept as is
+	if r := k.record("not json"); r.Message != "not json" || r.Metadata != nil {
+		t.Fatalf("Unexpected record %+v", r)
+	}
+}
+
+func TestLogsContainer(t *testing.T) {
+	c := &testClient{pods: []string{"foo-1"}, logs: "started\n"}
+
+	if _, err := newLog(c, "foo").Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.params["container"] != "foo" {
+		t.Fatalf("Expected default container foo got %s", c.params["container"])
+	}
+}
+
+func TestLogsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	k := newLog(&testClient{pods: []string{"foo-1"}}, "foo", runtime.LogsContext(ctx))
+
+	stream, err := k.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-stream.Chan():
+		if ok {
+			t.Fatal("Expected stream to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the stream to stop")
+	}
+}
+
+func TestLogsOrderWindow(t *testing.T) {
+	k := newLog(nil, "foo", runtime.LogsOrderWindow(time.Millisecond*50))
+
+	s := k.newStream()
+	defer s.Stop()
+
+	for _, line := range []string{
+		"2024-01-01T00:00:03Z three",
+		"2024-01-01T00:00:01Z one",
+		"2024-01-01T00:00:02Z two",
+	} {
+		s.send(k.record(line))
+	}
+
+	for _, want := range []string{"one", "two", "three"} {
+		select {
+		case r := <-s.stream:
+			if r.Message != want {
+				t.Fatalf("Expected %s got %s", want, r.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for ordered records")
+		}
+	}
+}
@@ -19,6 +19,8 @@ type Source struct {
 	Repository *source.Repository
 	// Language is the language of code
 	Language string
+	// Tag of the build e.g. a version or git commit
+	Tag string
 }
 
 // Package is micro service package.
@@ -32,3 +34,27 @@ type Package struct {
 	// Type of binary
 	Type string
 }
+
+// Phase is the stage of a build an event reports.
+type Phase string
+
+const (
+	// PhaseStep is the start of a build step.
+	PhaseStep Phase = "step"
+	// PhasePull is the progress of a layer pull.
+	PhasePull Phase = "pull"
+	// PhaseOutput is any other build output.
+	PhaseOutput Phase = "output"
+	// PhaseError is an error reported by the build.
+	PhaseError Phase = "error"
+	// PhaseDone is the last event of a build, Error is
+	// set if the build failed.
+	PhaseDone Phase = "done"
+)
+
+// Event is the progress of a build.
+type Event struct {
+	Phase   Phase
+	Message string
+	Error   error
+}
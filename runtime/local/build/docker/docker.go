@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -18,15 +19,77 @@ type Builder struct {
 	Options build.Options
 }
 
+// buildx builds the image with docker buildx, loading
+// the result into the local image store.
+func (d *Builder) buildx(dir, dockerFile, ref string) error {
+	args := []string{"buildx", "build", "--load", "-t", ref, "-f", filepath.Join(dir, dockerFile)}
+	for _, k := range d.Options.BuildArgNames() {
+		args = append(args, "--build-arg", k+"="+d.Options.BuildArgs[k])
+	}
+	args = append(args, dir)
+
+	cmd := exec.Command("docker", args...)
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	cmd.Stdout = d.Options.Writer()
+	cmd.Stderr = d.Options.Writer()
+
+	if d.Options.Progress == nil {
+		return cmd.Run()
+	}
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	done := make(chan struct{})
+
+	go func() {
+		lines(pr, d.Options.Progress, d.Options.Writer())
+		close(done)
+	}()
+
+	err := cmd.Run()
+	pw.Close()
+	<-done
+
+	return err
+}
+
+// Build builds the image, sending its events to the progress
+// channel if set. The last event of the build is PhaseDone.
 func (d *Builder) Build(s *build.Source) (*build.Package, error) {
+	pkg, err := d.build(s)
+
+	if ch := d.Options.Progress; ch != nil {
+		ch <- build.Event{Phase: build.PhaseDone, Error: err}
+	}
+
+	return pkg, err
+}
+
+func (d *Builder) build(s *build.Source) (*build.Package, error) {
 	image := filepath.Join(s.Repository.Path, s.Repository.Name)
+	ref := d.Options.Reference(image, s)
+
+	dockerFile := d.Options.DockerfileName()
+
+	if d.Options.BuildKit {
+		if err := d.buildx(image, dockerFile, ref); err != nil {
+			return nil, err
+		}
+
+		return &build.Package{
+			Name:   ref,
+			Path:   image,
+			Type:   "docker",
+			Source: s,
+		}, nil
+	}
 
 	buf := new(bytes.Buffer)
 	tw := tar.NewWriter(buf)
 	defer tw.Close()
 
-	dockerFile := "Dockerfile"
-
 	// open docker file
 	f, err := os.Open(filepath.Join(s.Repository.Path, s.Repository.Name, dockerFile))
 	if err != nil {
@@ -53,28 +116,66 @@ func (d *Builder) Build(s *build.Source) (*build.Package, error) {
 	}
 	tr := bytes.NewReader(buf.Bytes())
 
-	err = d.Client.BuildImage(docker.BuildImageOptions{
-		Name:           image,
+	var buildArgs []docker.BuildArg
+	for _, k := range d.Options.BuildArgNames() {
+		buildArgs = append(buildArgs, docker.BuildArg{Name: k, Value: d.Options.BuildArgs[k]})
+	}
+
+	opts := docker.BuildImageOptions{
+		Name:           ref,
 		Dockerfile:     dockerFile,
+		BuildArgs:      buildArgs,
 		InputStream:    tr,
-		OutputStream:   io.Discard,
+		OutputStream:   d.Options.Writer(),
 		RmTmpContainer: true,
-		SuppressOutput: true,
-	})
+		SuppressOutput: d.Options.Output == nil,
+	}
+
+	if d.Options.Progress == nil {
+		err = d.Client.BuildImage(opts)
+	} else {
+		err = d.buildProgress(opts)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &build.Package{
-		Name:   image,
+		Name:   ref,
 		Path:   image,
 		Type:   "docker",
 		Source: s,
 	}, nil
 }
 
+// buildProgress builds the image parsing the daemon's JSON output
+// stream into events. Errors the daemon reports in the stream fail
+// the build.
+func (d *Builder) buildProgress(opts docker.BuildImageOptions) error {
+	pr, pw := io.Pipe()
+
+	opts.OutputStream = pw
+	opts.RawJSONStream = true
+	opts.SuppressOutput = false
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- progress(pr, d.Options.Progress, d.Options.Writer())
+	}()
+
+	err := d.Client.BuildImage(opts)
+	pw.Close()
+
+	if perr := <-errCh; err == nil {
+		err = perr
+	}
+
+	return err
+}
+
+// Clean removes the image by its tagged reference.
 func (d *Builder) Clean(b *build.Package) error {
-	image := filepath.Join(b.Path, b.Name)
-	return d.Client.RemoveImage(image)
+	return d.Client.RemoveImage(b.Name)
 }
 
 func NewBuilder(opts ...build.Option) build.Builder {
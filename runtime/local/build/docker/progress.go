@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"go-micro.org/v5/runtime/local/build"
+)
+
+// message is a message of the docker daemon's JSON output stream.
+type message struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	Progress    string `json:"progress"`
+	ID          string `json:"id"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// event converts the message to a build event. False
+// is returned for messages with nothing to report.
+func (m *message) event() (build.Event, bool) {
+	switch {
+	case m.ErrorDetail != nil || len(m.Error) > 0:
+		msg := m.Error
+		if m.ErrorDetail != nil && len(m.ErrorDetail.Message) > 0 {
+			msg = m.ErrorDetail.Message
+		}
+
+		return build.Event{Phase: build.PhaseError, Message: msg, Error: errors.New(msg)}, true
+	case len(m.Status) > 0:
+		msg := m.Status
+		if len(m.ID) > 0 {
+			msg = m.ID + ": " + msg
+		}
+
+		if len(m.Progress) > 0 {
+			msg += " " + m.Progress
+		}
+
+		return build.Event{Phase: build.PhasePull, Message: msg}, true
+	}
+
+	msg := strings.TrimSpace(m.Stream)
+	if len(msg) == 0 {
+		return build.Event{}, false
+	}
+
+	if strings.HasPrefix(msg, "Step ") {
+		return build.Event{Phase: build.PhaseStep, Message: msg}, true
+	}
+
+	return build.Event{Phase: build.PhaseOutput, Message: msg}, true
+}
+
+// progress sends the events of the JSON stream to the channel and writes
+// the build output to out. The first error reported by the build is returned.
+func progress(r io.Reader, ch chan<- build.Event, out io.Writer) error {
+	// drain anything undecodable so the build is not blocked
+	defer io.Copy(io.Discard, r)
+
+	var buildErr error
+
+	dec := json.NewDecoder(r)
+
+	for {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			return buildErr
+		}
+
+		ev, ok := m.event()
+		if !ok {
+			continue
+		}
+
+		if ev.Error != nil && buildErr == nil {
+			buildErr = ev.Error
+		}
+
+		io.WriteString(out, ev.Message+"\n")
+		ch <- ev
+	}
+}
+
+// lines sends each line of plain build output to the channel.
+func lines(r io.Reader, ch chan<- build.Event, out io.Writer) {
+	s := bufio.NewScanner(io.TeeReader(r, out))
+
+	for s.Scan() {
+		msg := strings.TrimSpace(s.Text())
+		if len(msg) == 0 {
+			continue
+		}
+
+		ch <- build.Event{Phase: build.PhaseOutput, Message: msg}
+	}
+
+	io.Copy(io.Discard, r)
+}
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go-micro.org/v5/runtime/local/build"
+)
+
+func TestProgress(t *testing.T) {
+	stream := `{"stream":"Step 1/2 : FROM alpine\n"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"abc"}
+{"stream":"\n"}
+{"stream":" ---> 1234\n"}
+{"errorDetail":{"message":"no such file"},"error":"no such file"}
+`
+
+	ch := make(chan build.Event, 10)
+	out := new(bytes.Buffer)
+
+	err := progress(strings.NewReader(stream), ch, out)
+	if err == nil || err.Error() != "no such file" {
+		t.Fatalf("expected build error got %v", err)
+	}
+
+	close(ch)
+
+	want := []build.Event{
+		{Phase: build.PhaseStep, Message: "Step 1/2 : FROM alpine"},
+		{Phase: build.PhasePull, Message: "abc: Pulling fs layer"},
+		{Phase: build.PhaseOutput, Message: "---> 1234"},
+		{Phase: build.PhaseError, Message: "no such file"},
+	}
+
+	var got []build.Event
+	for ev := range ch {
+		got = append(got, ev)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, ev := range got {
+		if ev.Phase != want[i].Phase || ev.Message != want[i].Message {
+			t.Fatalf("event %d: expected %+v got %+v", i, want[i], ev)
+		}
+	}
+
+	if !strings.HasPrefix(out.String(), "Step 1/2 : FROM alpine\n") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
@@ -1,8 +1,72 @@
 package build
 
+import (
+	"io"
+	"sort"
+)
+
 type Options struct {
 	// local path to download source
 	Path string
+	// Tag is the default tag of builds whose source has none
+	Tag string
+	// Output receives the build output, discarded if nil
+	Output io.Writer
+	// BuildKit builds docker images with docker buildx
+	BuildKit bool
+	// Dockerfile is the name of the image build file
+	Dockerfile string
+	// BuildArgs are passed to image builds
+	BuildArgs map[string]string
+	// Progress receives build events, it's never closed
+	Progress chan<- Event
+}
+
+// DefaultDockerfile is the image build file used if none is set.
+const DefaultDockerfile = "Dockerfile"
+
+// Reference returns the image name tagged with the source tag, or the
+// default tag. Untagged images are implicitly latest.
+func (o Options) Reference(image string, s *Source) string {
+	tag := s.Tag
+	if len(tag) == 0 {
+		tag = o.Tag
+	}
+
+	if len(tag) == 0 {
+		return image
+	}
+
+	return image + ":" + tag
+}
+
+// Writer returns the writer the build output is sent to.
+func (o Options) Writer() io.Writer {
+	if o.Output == nil {
+		return io.Discard
+	}
+
+	return o.Output
+}
+
+// DockerfileName returns the name of the image build file.
+func (o Options) DockerfileName() string {
+	if len(o.Dockerfile) == 0 {
+		return DefaultDockerfile
+	}
+
+	return o.Dockerfile
+}
+
+// BuildArgNames returns the build arg names in a stable order.
+func (o Options) BuildArgNames() []string {
+	keys := make([]string, 0, len(o.BuildArgs))
+	for k := range o.BuildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 type Option func(o *Options)
@@ -13,3 +77,55 @@ func Path(p string) Option {
 		o.Path = p
 	}
 }
+
+// Tag sets the default tag of the builds e.g. a version or git commit.
+func Tag(t string) Option {
+	return func(o *Options) {
+		o.Tag = t
+	}
+}
+
+// Output writes the build output to w.
+func Output(w io.Writer) Option {
+	return func(o *Options) {
+		o.Output = w
+	}
+}
+
+// BuildKit builds docker images by running docker buildx build, which
+// supports BuildKit features such as cache mounts. The docker command
+// must be installed. The whole source directory is the build context.
+func BuildKit(b bool) Option {
+	return func(o *Options) {
+		o.BuildKit = b
+	}
+}
+
+// Dockerfile sets the name of the image build file in the source
+// directory. This defaults to DefaultDockerfile.
+func Dockerfile(name string) Option {
+	return func(o *Options) {
+		o.Dockerfile = name
+	}
+}
+
+// BuildArg sets an image build arg.
+func BuildArg(key, val string) Option {
+	return func(o *Options) {
+		if o.BuildArgs == nil {
+			o.BuildArgs = make(map[string]string)
+		}
+
+		o.BuildArgs[key] = val
+	}
+}
+
+// WithProgress sends the events of each build to the channel, each
+// build ending with a PhaseDone event. The channel is owned by the
+// caller and isn't closed, so it may be shared by builds, and must be
+// drained to not block them.
+func WithProgress(ch chan<- Event) Option {
+	return func(o *Options) {
+		o.Progress = ch
+	}
+}
@@ -0,0 +1,45 @@
+package build
+
+import (
+	"io"
+	"testing"
+)
+
+func TestReference(t *testing.T) {
+	testData := []struct {
+		tag    string
+		source string
+		want   string
+	}{
+		{"", "", "foo"},
+		{"v1", "", "foo:v1"},
+		{"v1", "abc123", "foo:abc123"},
+	}
+
+	for _, d := range testData {
+		o := Options{Tag: d.tag}
+
+		if got := o.Reference("foo", &Source{Tag: d.source}); got != d.want {
+			t.Fatalf("Expected %s got %s", d.want, got)
+		}
+	}
+}
+
+func TestOptionDefaults(t *testing.T) {
+	var o Options
+
+	if o.Writer() != io.Discard {
+		t.Fatal("Expected the output to be discarded")
+	}
+
+	if o.DockerfileName() != DefaultDockerfile {
+		t.Fatalf("Expected %s got %s", DefaultDockerfile, o.DockerfileName())
+	}
+
+	BuildArg("b", "2")(&o)
+	BuildArg("a", "1")(&o)
+
+	if keys := o.BuildArgNames(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("Expected sorted build args got %v", keys)
+	}
+}
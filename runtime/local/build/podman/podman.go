@@ -0,0 +1,80 @@
+// Package podman builds images with the podman cli
+package podman
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"go-micro.org/v5/runtime/local/build"
+)
+
+// Command is the podman binary used by the builder.
+var Command = "podman"
+
+type Builder struct {
+	Options build.Options
+}
+
+// args returns the podman build arguments.
+func (p *Builder) args(dir, ref string) []string {
+	args := []string{"build", "-t", ref, "-f", filepath.Join(dir, p.Options.DockerfileName())}
+	for _, k := range p.Options.BuildArgNames() {
+		args = append(args, "--build-arg", k+"="+p.Options.BuildArgs[k])
+	}
+
+	return append(args, dir)
+}
+
+func (p *Builder) run(args ...string) error {
+	cmd := exec.Command(Command, args...)
+	cmd.Stdout = p.Options.Writer()
+	cmd.Stderr = p.Options.Writer()
+
+	return cmd.Run()
+}
+
+// Build builds the image. A progress channel only receives
+// the done event as podman has no structured output.
+func (p *Builder) Build(s *build.Source) (*build.Package, error) {
+	pkg, err := p.build(s)
+
+	if ch := p.Options.Progress; ch != nil {
+		ch <- build.Event{Phase: build.PhaseDone, Error: err}
+	}
+
+	return pkg, err
+}
+
+func (p *Builder) build(s *build.Source) (*build.Package, error) {
+	image := filepath.Join(s.Repository.Path, s.Repository.Name)
+	ref := p.Options.Reference(image, s)
+
+	if err := p.run(p.args(image, ref)...); err != nil {
+		return nil, err
+	}
+
+	return &build.Package{
+		Name:   ref,
+		Path:   image,
+		Type:   "podman",
+		Source: s,
+	}, nil
+}
+
+// Clean removes the image by its tagged reference.
+func (p *Builder) Clean(b *build.Package) error {
+	return p.run("rmi", b.Name)
+}
+
+// NewBuilder returns a builder which runs podman build. Podman
+// needs no daemon so it works for rootless builds.
+func NewBuilder(opts ...build.Option) build.Builder {
+	options := build.Options{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Builder{
+		Options: options,
+	}
+}
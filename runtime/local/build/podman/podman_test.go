@@ -0,0 +1,68 @@
+package podman
+
+import (
+	"reflect"
+	"testing"
+
+	"go-micro.org/v5/runtime/local/build"
+	"go-micro.org/v5/runtime/local/source"
+)
+
+func TestArgs(t *testing.T) {
+	testData := []struct {
+		opts []build.Option
+		want []string
+	}{
+		{
+			nil,
+			[]string{"build", "-t", "foo:v1", "-f", "/src/foo/Dockerfile", "/src/foo"},
+		},
+		{
+			[]build.Option{
+				build.Dockerfile("Containerfile"),
+				build.BuildArg("VERSION", "1.0"),
+				build.BuildArg("ARCH", "amd64"),
+			},
+			[]string{
+				"build", "-t", "foo:v1", "-f", "/src/foo/Containerfile",
+				"--build-arg", "ARCH=amd64",
+				"--build-arg", "VERSION=1.0",
+				"/src/foo",
+			},
+		},
+	}
+
+	for _, d := range testData {
+		p := NewBuilder(d.opts...).(*Builder)
+
+		if got := p.args("/src/foo", "foo:v1"); !reflect.DeepEqual(got, d.want) {
+			t.Fatalf("Expected %v got %v", d.want, got)
+		}
+	}
+}
+
+func TestBuildProgress(t *testing.T) {
+	defer func(cmd string) { Command = cmd }(Command)
+	Command = "false"
+
+	ch := make(chan build.Event, 2)
+	p := NewBuilder(build.WithProgress(ch))
+
+	src := &build.Source{Repository: &source.Repository{Name: "foo", Path: t.TempDir()}}
+
+	// the channel is used by each build
+	for i := 0; i < 2; i++ {
+		if _, err := p.Build(src); err == nil {
+			t.Fatal("Expected the build to fail")
+		}
+
+		ev, ok := <-ch
+		if !ok {
+			t.Fatal("Expected the progress channel to stay open")
+		}
+
+		if ev.Phase != build.PhaseDone || ev.Error == nil {
+			t.Fatalf("Expected a failed done event got %+v", ev)
+		}
+	}
+}
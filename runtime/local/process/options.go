@@ -1,5 +1,86 @@
 package process
 
-type Options struct{}
+import (
+	"go-micro.org/v5/logger"
+)
+
+type Options struct {
+	// Resources limits the processes
+	Resources *Resources
+	// Env is added to the environment of the processes
+	Env []string
+	// Secrets are added to the environment of the
+	// processes but never logged
+	Secrets map[string]string
+	// Logger logs the processes started
+	Logger logger.Logger
+}
 
 type Option func(o *Options)
+
+// WithResources sets the resource limits of the processes.
+func WithResources(r *Resources) Option {
+	return func(o *Options) {
+		o.Resources = r
+	}
+}
+
+// WithEnv adds env vars e.g. FOO=bar to the environment of the processes.
+func WithEnv(env ...string) Option {
+	return func(o *Options) {
+		o.Env = append(o.Env, env...)
+	}
+}
+
+// WithSecret adds a secret env var to the environment of the processes.
+// Its value is redacted from anything logged.
+func WithSecret(key, val string) Option {
+	return func(o *Options) {
+		if o.Secrets == nil {
+			o.Secrets = make(map[string]string)
+		}
+
+		o.Secrets[key] = val
+	}
+}
+
+// WithLogger sets the logger of the processes.
+func WithLogger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// Stream is a process output stream.
+type Stream int
+
+const (
+	// Stdout is the standard output of a process.
+	Stdout Stream = 1 << iota
+	// Stderr is the standard error of a process.
+	Stderr
+)
+
+// LogOptions configure reading the output of a process.
+type LogOptions struct {
+	// Follow waits for new output until the process exits
+	Follow bool
+	// Streams to read, both if unset
+	Streams Stream
+}
+
+type LogOption func(o *LogOptions)
+
+// LogFollow keeps the reader open until the process exits.
+func LogFollow(b bool) LogOption {
+	return func(o *LogOptions) {
+		o.Follow = b
+	}
+}
+
+// LogStreams selects the output streams read e.g. Stdout|Stderr.
+func LogStreams(s Stream) LogOption {
+	return func(o *LogOptions) {
+		o.Streams = s
+	}
+}
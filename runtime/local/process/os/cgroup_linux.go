@@ -0,0 +1,130 @@
+package os
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.org/v5/runtime/local/process"
+)
+
+// CgroupRoot is the cgroup v2 directory process cgroups are created in.
+var CgroupRoot = "/sys/fs/cgroup/micro"
+
+// cpuPeriod is the cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// reapInterval is how often the cgroup of a process
+// which may never be waited on is checked for removal.
+var reapInterval = time.Second
+
+// limit creates a cgroup enforcing the resources for a process to be
+// started in and returns its directory.
+func limit(r *process.Resources) (string, error) {
+	if r == nil || (r.CPU <= 0 && r.Mem <= 0) {
+		return "", nil
+	}
+
+	parent := filepath.Dir(CgroupRoot)
+
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("resource limits require cgroup v2: %v", err)
+	}
+
+	if err := os.MkdirAll(CgroupRoot, 0755); err != nil {
+		return "", err
+	}
+
+	// delegate the controllers down to the process cgroups
+	for _, dir := range []string{parent, CgroupRoot} {
+		if err := write(dir, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+			return "", err
+		}
+	}
+
+	dir := filepath.Join(CgroupRoot, uuid.New().String())
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if err := setLimits(dir, r); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func setLimits(dir string, r *process.Resources) error {
+	if r.Mem > 0 {
+		if err := write(dir, "memory.max", strconv.Itoa(r.Mem*1024*1024)); err != nil {
+			return err
+		}
+
+		// the oom killer takes the whole process, not the host
+		if err := write(dir, "memory.oom.group", "1"); err != nil {
+			return err
+		}
+	}
+
+	if r.CPU > 0 {
+		quota := r.CPU * cpuPeriod / 1000
+		if err := write(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unlimit removes the cgroup of an exited process.
+func unlimit(dir string) error {
+	if len(dir) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// populated reports whether processes are left in the cgroup.
+func populated(dir string) (bool, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.events"))
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Contains(b, []byte("populated 0")), nil
+}
+
+// reap removes the cgroup once its process exits, so it isn't left
+// behind if the process is never waited on. It returns once the
+// cgroup is gone.
+func reap(dir string) {
+	t := time.NewTicker(reapInterval)
+	defer t.Stop()
+
+	for range t.C {
+		ok, err := populated(dir)
+		if err != nil {
+			return
+		}
+
+		if !ok {
+			unlimit(dir)
+			return
+		}
+	}
+}
+
+func write(dir, file, val string) error {
+	return os.WriteFile(filepath.Join(dir, file), []byte(val), 0644)
+}
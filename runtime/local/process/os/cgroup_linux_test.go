@@ -0,0 +1,138 @@
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-micro.org/v5/runtime/local/process"
+)
+
+func TestLimit(t *testing.T) {
+	root := t.TempDir()
+
+	// fake a cgroup v2 hierarchy
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(r string) { CgroupRoot = r }(CgroupRoot)
+	CgroupRoot = filepath.Join(root, "micro")
+
+	dir, err := limit(nil)
+	if err != nil || len(dir) > 0 {
+		t.Fatalf("expected no cgroup without limits got %q %v", dir, err)
+	}
+
+	dir, err = limit(&process.Resources{CPU: 500, Mem: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Dir(dir) != CgroupRoot {
+		t.Fatalf("expected a cgroup in %s got %s", CgroupRoot, dir)
+	}
+
+	files := map[string]string{
+		"cpu.max":          "50000 100000",
+		"memory.max":       "67108864",
+		"memory.oom.group": "1",
+	}
+
+	for file, want := range files {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != want {
+			t.Fatalf("%s: expected %q got %q", file, want, b)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, "cgroup.subtree_control"))
+	if err != nil || string(b) != "+cpu +memory" {
+		t.Fatalf("expected controllers to be delegated got %q %v", b, err)
+	}
+
+	// without cgroup v2 limits fail rather than being ignored
+	CgroupRoot = filepath.Join(t.TempDir(), "micro")
+
+	if _, err := limit(&process.Resources{Mem: 64}); err == nil {
+		t.Fatal("expected an error without cgroup v2")
+	}
+}
+
+func TestReap(t *testing.T) {
+	defer func(d time.Duration) { reapInterval = d }(reapInterval)
+	reapInterval = time.Millisecond
+
+	dir := filepath.Join(t.TempDir(), "cgroup")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	events := filepath.Join(dir, "cgroup.events")
+	if err := os.WriteFile(events, []byte("populated 1\nfrozen 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+
+	go func() {
+		reap(dir)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected the populated cgroup to be kept")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	// the process exits
+	if err := os.WriteFile(events, []byte("populated 0\nfrozen 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected reap to return once the cgroup is unpopulated")
+	}
+
+	// a cgroup removed by Wait is no longer checked
+	done = make(chan bool)
+
+	go func() {
+		reap(filepath.Join(t.TempDir(), "removed"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected reap to return once the cgroup is gone")
+	}
+}
+
+func TestPopulated(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, d := range []struct {
+		events string
+		want   bool
+	}{
+		{"populated 1\nfrozen 0\n", true},
+		{"populated 0\nfrozen 0\n", false},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte(d.events), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := populated(dir); err != nil || ok != d.want {
+			t.Fatalf("%q: expected %v got %v %v", d.events, d.want, ok, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package os
+
+import (
+	"os/exec"
+
+	"go-micro.org/v5/runtime/local/process"
+)
+
+// limit is a no-op, resource limits are only enforced on linux.
+func limit(r *process.Resources) (string, error) {
+	return "", nil
+}
+
+func unlimit(dir string) error {
+	return nil
+}
+
+func reap(dir string) {}
+
+func start(cmd *exec.Cmd, cgroup string) error {
+	return cmd.Start()
+}
@@ -0,0 +1,32 @@
+//go:build linux && !go1.20
+// +build linux,!go1.20
+
+package os
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// start starts the command and moves it into the cgroup, it runs
+// unlimited until it's moved as the cgroup can't be set at fork
+// before go1.20.
+func start(cmd *exec.Cmd, cgroup string) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if len(cgroup) == 0 {
+		return nil
+	}
+
+	if err := write(cgroup, "cgroup.procs", strconv.Itoa(cmd.Process.Pid)); err != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+
+		return err
+	}
+
+	return nil
+}
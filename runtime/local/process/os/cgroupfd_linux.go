@@ -0,0 +1,28 @@
+//go:build linux && go1.20
+// +build linux,go1.20
+
+package os
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// start starts the command in the cgroup so it's limited
+// from the outset, or as is if the cgroup is empty.
+func start(cmd *exec.Cmd, cgroup string) error {
+	if len(cgroup) == 0 {
+		return cmd.Start()
+	}
+
+	fd, err := syscall.Open(cgroup, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+
+	return cmd.Start()
+}
@@ -0,0 +1,226 @@
+package os
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"go-micro.org/v5/runtime/local/process"
+)
+
+// DefaultLogSize is the amount of output kept for each process.
+// The oldest output is dropped once it is exceeded.
+var DefaultLogSize = 1024 * 1024
+
+type chunk struct {
+	stream process.Stream
+	data   []byte
+}
+
+// logBuffer holds the output captured from a process.
+type logBuffer struct {
+	sync.Mutex
+	cond *sync.Cond
+
+	chunks []chunk
+	// index of the first chunk since the process started
+	base int
+	size int
+	// streams still being captured
+	open int
+}
+
+// capture reads the output pipes of the process into a log buffer, and
+// returns readers of its stdout and stderr which don't drop any output.
+// As with the pipes, the process blocks if the readers aren't read.
+func (p *Process) capture(id string, stdout, stderr io.Reader) (io.Reader, io.Reader) {
+	b := &logBuffer{open: 2}
+	b.cond = sync.NewCond(b)
+
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+
+	go b.copy(process.Stdout, stdout, outW)
+	go b.copy(process.Stderr, stderr, errW)
+
+	p.Lock()
+	if p.logs == nil {
+		p.logs = make(map[string]*logBuffer)
+	}
+	p.logs[id] = b
+	p.Unlock()
+
+	return outR, errR
+}
+
+// release drops the output of the process.
+func (p *Process) release(id string) {
+	p.Lock()
+	delete(p.logs, id)
+	p.Unlock()
+}
+
+// Logs returns the output of the process. Stdout and stderr are combined
+// in the order they were written unless a stream is selected. Following
+// reads block for new output until the process exits or the reader is
+// closed. Output is kept until the process is waited on or killed.
+func (p *Process) Logs(pid *process.PID, opts ...process.LogOption) (io.ReadCloser, error) {
+	options := process.LogOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	p.Lock()
+	b, ok := p.logs[pid.ID]
+	p.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no logs for process %s", pid.ID)
+	}
+
+	return b.reader(options.Streams, options.Follow), nil
+}
+
+// copy writes the output of the stream to the buffer and the pipe.
+func (b *logBuffer) copy(s process.Stream, r io.Reader, w *io.PipeWriter) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			data := append([]byte(nil), buf[:n]...)
+			b.write(s, data)
+
+			// the output is still captured once the pipe is closed
+			if w != nil {
+				if _, err := w.Write(data); err != nil {
+					w = nil
+				}
+			}
+		}
+
+		if err != nil {
+			if w != nil {
+				w.CloseWithError(err)
+			}
+
+			break
+		}
+	}
+
+	b.Lock()
+	b.open--
+	b.cond.Broadcast()
+	b.Unlock()
+}
+
+func (b *logBuffer) write(s process.Stream, data []byte) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.chunks = append(b.chunks, chunk{stream: s, data: data})
+	b.size += len(data)
+
+	for b.size > DefaultLogSize && len(b.chunks) > 1 {
+		b.size -= len(b.chunks[0].data)
+		b.chunks[0] = chunk{}
+		b.chunks = b.chunks[1:]
+		b.base++
+	}
+
+	b.cond.Broadcast()
+}
+
+func (b *logBuffer) reader(streams process.Stream, follow bool) *logReader {
+	if streams == 0 {
+		streams = process.Stdout | process.Stderr
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	return &logReader{
+		b:       b,
+		streams: streams,
+		follow:  follow,
+		next:    b.base,
+		end:     b.base + len(b.chunks),
+	}
+}
+
+// next returns the next chunk of the reader's streams.
+func (b *logBuffer) next(r *logReader) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	for {
+		if r.closed {
+			return nil, io.ErrClosedPipe
+		}
+
+		// output dropped before it was read is skipped
+		if r.next < b.base {
+			r.next = b.base
+		}
+
+		end := b.base + len(b.chunks)
+		if !r.follow && r.end < end {
+			end = r.end
+		}
+
+		for i := r.next; i < end; i++ {
+			if c := b.chunks[i-b.base]; c.stream&r.streams != 0 {
+				r.next = i + 1
+				return c.data, nil
+			}
+		}
+
+		r.next = end
+
+		if !r.follow || b.open == 0 {
+			return nil, io.EOF
+		}
+
+		b.cond.Wait()
+	}
+}
+
+// logReader reads the output of a process.
+type logReader struct {
+	b       *logBuffer
+	streams process.Stream
+	follow  bool
+
+	// next chunk to read, guarded by the buffer
+	next int
+	// end of the output when the reader was created
+	end    int
+	closed bool
+
+	buf []byte
+}
+
+func (r *logReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		data, err := r.b.next(r)
+		if err != nil {
+			return 0, err
+		}
+
+		r.buf = data
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+func (r *logReader) Close() error {
+	r.b.Lock()
+	r.closed = true
+	r.b.cond.Broadcast()
+	r.b.Unlock()
+
+	return nil
+}
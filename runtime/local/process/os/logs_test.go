@@ -0,0 +1,99 @@
+package os
+
+import (
+	"io"
+	"testing"
+
+	"go-micro.org/v5/runtime/local/process"
+)
+
+func TestLogs(t *testing.T) {
+	p := new(Process)
+
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+
+	stdout, stderr := p.capture("1", outR, errR)
+	pid := &process.PID{ID: "1"}
+
+	// the pipes of the process see all of the output
+	pipes := make(chan string, 2)
+	for _, r := range []io.Reader{stdout, stderr} {
+		go func(r io.Reader) {
+			b, _ := io.ReadAll(r)
+			pipes <- string(b)
+		}(r)
+	}
+
+	follow, err := p.Logs(pid, process.LogFollow(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errLogs, err := p.Logs(pid, process.LogFollow(true), process.LogStreams(process.Stderr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4)
+
+	// write each stream in turn so the order is known
+	for _, w := range []struct {
+		w    io.Writer
+		data string
+	}{
+		{outW, "out\n"},
+		{errW, "err\n"},
+	} {
+		w.w.Write([]byte(w.data))
+
+		n, _ := io.ReadFull(follow, buf)
+		if got := string(buf[:n]); got != w.data {
+			t.Fatalf("expected %q got %q", w.data, got)
+		}
+	}
+
+	// a snapshot ends at the current output
+	logs, err := p.Logs(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := io.ReadAll(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "out\nerr\n" {
+		t.Fatalf("expected output got %q", b)
+	}
+
+	// following readers end once the process exits
+	outW.Close()
+	errW.Close()
+
+	b, err = io.ReadAll(errLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "err\n" {
+		t.Fatalf("expected stderr got %q", b)
+	}
+
+	if a, b := <-pipes, <-pipes; a+b != "out\nerr\n" && a+b != "err\nout\n" {
+		t.Fatalf("expected the output of the pipes got %q and %q", a, b)
+	}
+
+	follow.Close()
+
+	if _, err := follow.Read(buf); err != io.ErrClosedPipe {
+		t.Fatalf("expected closed reader got %v", err)
+	}
+
+	p.release("1")
+
+	if _, err := p.Logs(pid); err == nil {
+		t.Fatal("expected no logs after release")
+	}
+}
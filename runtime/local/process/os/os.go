@@ -15,8 +15,12 @@ import (
 )
 
 func (p *Process) Exec(exe *process.Executable) error {
-	cmd := exec.Command(exe.Package.Path)
+	cmd := exec.Command(exe.Package.Path, exe.Args...)
 	cmd.Dir = exe.Dir
+	cmd.Env = p.environ(exe)
+
+	p.logStart(exe)
+
 	return cmd.Run()
 }
 
@@ -26,8 +30,7 @@ func (p *Process) Fork(exe *process.Executable) (*process.PID, error) {
 
 	cmd.Dir = exe.Dir
 	// set env vars
-	cmd.Env = append(cmd.Env, os.Environ()...)
-	cmd.Env = append(cmd.Env, exe.Env...)
+	cmd.Env = p.environ(exe)
 
 	// create process group
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -44,16 +47,50 @@ func (p *Process) Fork(exe *process.Executable) (*process.PID, error) {
 	if err != nil {
 		return nil, err
 	}
-	// start the process
-	if err := cmd.Start(); err != nil {
+	// the cgroup enforcing the resource limits
+	cgroup, err := limit(p.resources(exe))
+	if err != nil {
+		return nil, err
+	}
+
+	p.logStart(exe)
+
+	// start the process in its cgroup
+	if err := start(cmd, cgroup); err != nil {
+		unlimit(cgroup)
 		return nil, err
 	}
 
+	id := fmt.Sprintf("%d", cmd.Process.Pid)
+
+	if len(cgroup) > 0 {
+		p.Lock()
+		if p.cgroups == nil {
+			p.cgroups = make(map[string]string)
+		}
+		p.cgroups[id] = cgroup
+		p.Unlock()
+
+		// the cgroup is removed even if the process is never waited on
+		go func() {
+			reap(cgroup)
+
+			p.Lock()
+			if p.cgroups[id] == cgroup {
+				delete(p.cgroups, id)
+			}
+			p.Unlock()
+		}()
+	}
+
+	// capture the output so it can be read by Logs
+	stdout, stderr := p.capture(id, out, er)
+
 	return &process.PID{
-		ID:     fmt.Sprintf("%d", cmd.Process.Pid),
+		ID:     id,
 		Input:  in,
-		Output: out,
-		Error:  er,
+		Output: stdout,
+		Error:  stderr,
 	}, nil
 }
 
@@ -62,6 +99,8 @@ func (p *Process) Kill(pid *process.PID) error {
 	if err != nil {
 		return err
 	}
+
+	p.release(pid.ID)
 	if _, err := os.FindProcess(id); err != nil {
 		return err
 	}
@@ -87,6 +126,17 @@ func (p *Process) Wait(pid *process.PID) error {
 		return err
 	}
 
+	// the process has exited so its output and cgroup can be released,
+	// readers already returned by Logs keep the output they read from
+	p.release(pid.ID)
+
+	p.Lock()
+	cgroup := p.cgroups[pid.ID]
+	delete(p.cgroups, pid.ID)
+	p.Unlock()
+
+	unlimit(cgroup)
+
 	if ps.Success() {
 		return nil
 	}
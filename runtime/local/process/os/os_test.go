@@ -0,0 +1,77 @@
+//go:build !windows
+// +build !windows
+
+package os
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go-micro.org/v5/logger"
+	"go-micro.org/v5/runtime/local/build"
+	"go-micro.org/v5/runtime/local/process"
+)
+
+// testLogger records what is logged.
+type testLogger struct {
+	logger.Logger
+	buf bytes.Buffer
+}
+
+func (l *testLogger) Options() logger.Options {
+	return logger.Options{Level: logger.DebugLevel}
+}
+
+func (l *testLogger) Log(level logger.Level, v ...interface{}) {
+	fmt.Fprint(&l.buf, v...)
+}
+
+func TestForkEnv(t *testing.T) {
+	l := &testLogger{}
+
+	p := NewProcess(
+		process.WithEnv("FOO=bar"),
+		process.WithSecret("TOKEN", "s3cr3t"),
+		process.WithLogger(l),
+	)
+
+	pid, err := p.Fork(&process.Executable{
+		Package: &build.Package{Path: "sh"},
+		Args:    []string{"-c", `echo "$FOO $BAZ"; test "$TOKEN" = s3cr3t && echo secret`},
+		Env:     []string{"BAZ=qux"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(pid.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Wait(pid); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(out); got != "bar qux\nsecret\n" {
+		t.Fatalf("expected env in the child got %q", got)
+	}
+
+	// the output is released once the process is waited on
+	if _, err := p.(*Process).Logs(pid); err == nil {
+		t.Fatal("expected the logs to be released after wait")
+	}
+
+	logs := l.buf.String()
+
+	if !strings.Contains(logs, "TOKEN=[redacted]") {
+		t.Fatalf("expected the secret to be redacted got %q", logs)
+	}
+
+	if strings.Contains(logs, "s3cr3t") {
+		t.Fatalf("secret was logged: %q", logs)
+	}
+}
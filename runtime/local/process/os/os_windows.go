@@ -11,15 +11,21 @@ import (
 )
 
 func (p *Process) Exec(exe *process.Executable) error {
-	cmd := exec.Command(exe.Package.Path)
+	cmd := exec.Command(exe.Package.Path, exe.Args...)
+	cmd.Dir = exe.Dir
+	cmd.Env = p.environ(exe)
+
+	p.logStart(exe)
+
 	return cmd.Run()
 }
 
 func (p *Process) Fork(exe *process.Executable) (*process.PID, error) {
 	// create command
 	cmd := exec.Command(exe.Package.Path, exe.Args...)
+	cmd.Dir = exe.Dir
 	// set env vars
-	cmd.Env = append(cmd.Env, exe.Env...)
+	cmd.Env = p.environ(exe)
 
 	in, err := cmd.StdinPipe()
 	if err != nil {
@@ -34,16 +40,23 @@ func (p *Process) Fork(exe *process.Executable) (*process.PID, error) {
 		return nil, err
 	}
 
+	p.logStart(exe)
+
 	// start the process
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
 
+	id := fmt.Sprintf("%d", cmd.Process.Pid)
+
+	// capture the output so it can be read by Logs
+	stdout, stderr := p.capture(id, out, er)
+
 	return &process.PID{
-		ID:     fmt.Sprintf("%d", cmd.Process.Pid),
+		ID:     id,
 		Input:  in,
-		Output: out,
-		Error:  er,
+		Output: stdout,
+		Error:  stderr,
 	}, nil
 }
 
@@ -53,6 +66,8 @@ func (p *Process) Kill(pid *process.PID) error {
 		return err
 	}
 
+	p.release(pid.ID)
+
 	pr, err := os.FindProcess(id)
 	if err != nil {
 		return err
@@ -81,6 +96,9 @@ func (p *Process) Wait(pid *process.PID) error {
 		return err
 	}
 
+	// the process has exited so its output can be released
+	p.release(pid.ID)
+
 	if ps.Success() {
 		return nil
 	}
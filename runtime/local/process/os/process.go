@@ -2,11 +2,115 @@
 package os
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-micro.org/v5/logger"
 	"go-micro.org/v5/runtime/local/process"
 )
 
-type Process struct{}
+// redacted replaces the values of secrets in logs.
+const redacted = "[redacted]"
+
+type Process struct {
+	sync.Mutex
+	// output of the forked processes
+	logs map[string]*logBuffer
+	// cgroups of the limited processes
+	cgroups map[string]string
+
+	opts process.Options
+}
+
+// resources returns the limits of the executable.
+func (p *Process) resources(exe *process.Executable) *process.Resources {
+	if exe.Resources != nil {
+		return exe.Resources
+	}
+
+	return p.opts.Resources
+}
+
+// secrets returns the secrets of the process options and executable.
+func (p *Process) secrets(exe *process.Executable) map[string]string {
+	secrets := make(map[string]string, len(p.opts.Secrets)+len(exe.Secrets))
+
+	for k, v := range p.opts.Secrets {
+		secrets[k] = v
+	}
+
+	for k, v := range exe.Secrets {
+		secrets[k] = v
+	}
+
+	return secrets
+}
+
+// environ returns the environment of the executable. The process env
+// is overridden by the executable's and secrets take precedence.
+func (p *Process) environ(exe *process.Executable) []string {
+	env := append([]string{}, os.Environ()...)
+	env = append(env, p.opts.Env...)
+	env = append(env, exe.Env...)
+
+	secrets := p.secrets(exe)
+
+	for _, k := range sortedKeys(secrets) {
+		env = append(env, k+"="+secrets[k])
+	}
+
+	return env
+}
+
+// logStart logs the executable being started. Only the injected env
+// is logged, with the values of secrets redacted.
+func (p *Process) logStart(exe *process.Executable) {
+	l := logger.LoggerOrDefault(p.opts.Logger)
+	if !l.Options().Level.Enabled(logger.DebugLevel) {
+		return
+	}
+
+	secrets := p.secrets(exe)
+
+	env := append([]string{}, p.opts.Env...)
+	env = append(env, exe.Env...)
+
+	for _, k := range sortedKeys(secrets) {
+		env = append(env, k+"="+redacted)
+	}
+
+	line := fmt.Sprintf("Forking process %s %s env %s", exe.Package.Path, strings.Join(exe.Args, " "), strings.Join(env, " "))
+
+	// secrets may be passed on in args or other env vars
+	for _, v := range secrets {
+		if len(v) > 0 {
+			line = strings.ReplaceAll(line, v, redacted)
+		}
+	}
+
+	l.Log(logger.DebugLevel, line)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
 
 func NewProcess(opts ...process.Option) process.Process {
-	return &Process{}
+	options := process.Options{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Process{
+		opts: options,
+	}
 }
@@ -28,6 +28,20 @@ type Executable struct {
 	Env []string
 	// Args to pass
 	Args []string
+	// Secrets are env variables which are never logged
+	Secrets map[string]string
+	// Resources limits the process, the process
+	// options are used if not set
+	Resources *Resources
+}
+
+// Resources limits the resources a process may use. Limits
+// are only enforced on linux with cgroup v2.
+type Resources struct {
+	// CPU in millicores e.g. 500 is half a core
+	CPU int
+	// Mem in MiB, exceeding it kills the process
+	Mem int
 }
 
 // PID is the running process.
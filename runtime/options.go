@@ -3,6 +3,7 @@ package runtime
 import (
 	"context"
 	"io"
+	"time"
 
 	"go-micro.org/v5/client"
 	"go-micro.org/v5/logger"
@@ -104,6 +105,18 @@ type CreateOptions struct {
 	Env []string
 	// Retries before failing deploy
 	Retries int
+	// Resources to limit the service to
+	Resources *Resources
+	// Secrets are env variables which are never logged
+	Secrets map[string]string
+}
+
+// Resources are the resource limits of a service.
+type Resources struct {
+	// CPU in millicores
+	CPU int
+	// Mem in MiB
+	Mem int
 }
 
 // ReadOptions queries runtime services.
@@ -177,6 +190,26 @@ func WithEnv(env []string) CreateOption {
 	}
 }
 
+// ResourceLimits sets the resources the service may use. The local
+// runtime enforces them with cgroup v2 on linux only.
+func ResourceLimits(r *Resources) CreateOption {
+	return func(o *CreateOptions) {
+		o.Resources = r
+	}
+}
+
+// WithSecret adds a secret env variable to the created service. Its
+// value is redacted from the logs of the local runtime.
+func WithSecret(key, value string) CreateOption {
+	return func(o *CreateOptions) {
+		if o.Secrets == nil {
+			o.Secrets = make(map[string]string)
+		}
+
+		o.Secrets[key] = value
+	}
+}
+
 // WithOutput sets the arg output.
 func WithOutput(out io.Writer) CreateOption {
 	return func(o *CreateOptions) {
@@ -278,7 +311,28 @@ type LogsOptions struct {
 	Count int64
 	// Stream new lines?
 	Stream bool
-}
+	// BufferSize of the stream channel
+	BufferSize int
+	// BufferPolicy when the stream buffer is full
+	BufferPolicy LogsPolicy
+	// Format of the log lines, e.g. json
+	Format string
+	// Container to read logs from, the service's by default
+	Container string
+	// OrderWindow streamed records are held for to
+	// be ordered by timestamp, disabled if zero
+	OrderWindow time.Duration
+}
+
+// LogsPolicy decides what happens when a log stream's buffer is full.
+type LogsPolicy int
+
+const (
+	// LogsBlock blocks the producers until the consumer catches up.
+	LogsBlock LogsPolicy = iota
+	// LogsDropOldest discards the oldest buffered record.
+	LogsDropOldest
+)
 
 // LogsExistingCount confiures how many existing lines to show.
 func LogsCount(count int64) LogsOption {
@@ -294,6 +348,43 @@ func LogsStream(stream bool) LogsOption {
 	}
 }
 
+// LogsBufferSize sets the number of records buffered for a stream.
+func LogsBufferSize(size int) LogsOption {
+	return func(l *LogsOptions) {
+		l.BufferSize = size
+	}
+}
+
+// LogsBufferPolicy sets what happens when the stream buffer is full.
+func LogsBufferPolicy(p LogsPolicy) LogsOption {
+	return func(l *LogsOptions) {
+		l.BufferPolicy = p
+	}
+}
+
+// LogsFormat sets the format log lines are parsed as. With "json" the
+// fields of each line are returned as the record metadata.
+func LogsFormat(f string) LogsOption {
+	return func(l *LogsOptions) {
+		l.Format = f
+	}
+}
+
+// LogsContainer sets the container within the pod to read logs from.
+func LogsContainer(name string) LogsOption {
+	return func(l *LogsOptions) {
+		l.Container = name
+	}
+}
+
+// LogsOrderWindow holds streamed records for the window and emits them
+// sorted by timestamp, trading latency for ordering across pods.
+func LogsOrderWindow(d time.Duration) LogsOption {
+	return func(l *LogsOptions) {
+		l.OrderWindow = d
+	}
+}
+
 // LogsNamespace sets the namespace.
 func LogsNamespace(ns string) LogsOption {
 	return func(o *LogsOptions) {
@@ -45,8 +45,9 @@ type LogStream interface {
 }
 
 type LogRecord struct {
-	Metadata map[string]string
-	Message  string
+	Timestamp time.Time
+	Metadata  map[string]string
+	Message   string
 }
 
 // Scheduler is a runtime service scheduler.
@@ -51,6 +51,14 @@ func newService(s *Service, c CreateOptions, l log.Logger) *service {
 	exec = strings.Join(c.Command, " ")
 	args = c.Args
 
+	var resources *process.Resources
+	if c.Resources != nil {
+		resources = &process.Resources{
+			CPU: c.Resources.CPU,
+			Mem: c.Resources.Mem,
+		}
+	}
+
 	return &service{
 		Service: s,
 		Process: new(proc.Process),
@@ -59,9 +67,11 @@ func newService(s *Service, c CreateOptions, l log.Logger) *service {
 				Name: s.Name,
 				Path: exec,
 			},
-			Env:  c.Env,
-			Args: args,
-			Dir:  s.Source,
+			Env:       c.Env,
+			Args:      args,
+			Dir:       s.Source,
+			Resources: resources,
+			Secrets:   c.Secrets,
 		},
 		Logger:     log.LoggerOrDefault(l),
 		closed:     make(chan bool),
@@ -2,6 +2,7 @@ package selector
 
 import (
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
@@ -54,3 +55,120 @@ func RoundRobin(services []*registry.Service) Next {
 		return node, nil
 	}
 }
+
+// Weighted returns a strategy which splits traffic between service versions
+// by weight, e.g {"v1": 90, "v2": 10} for a canary rollout. Weights not set
+// in the map are read from the "weight" service metadata. Versions without a
+// weight receive no traffic, unless none are weighted in which case nodes
+// are selected at random.
+func Weighted(weights map[string]int) Strategy {
+	return func(services []*registry.Service) Next {
+		var (
+			total    int
+			weighted []*registry.Service
+			shares   []int
+		)
+
+		for _, service := range services {
+			w, ok := weights[service.Version]
+			if !ok {
+				w, _ = strconv.Atoi(service.Metadata["weight"])
+			}
+
+			if w <= 0 || len(service.Nodes) == 0 {
+				continue
+			}
+
+			total += w
+			weighted = append(weighted, service)
+			shares = append(shares, total)
+		}
+
+		if total == 0 {
+			return Random(services)
+		}
+
+		return func() (*registry.Node, error) {
+			n := rand.Intn(total)
+
+			for i, share := range shares {
+				if n < share {
+					nodes := weighted[i].Nodes
+					return nodes[rand.Intn(len(nodes))], nil
+				}
+			}
+
+			return nil, ErrNoneAvailable
+		}
+	}
+}
+
+// NodeWeighted returns a strategy which biases node selection by a numeric
+// weight read from the node metadata key, e.g. a health score reported by
+// each node. Nodes with a weight of zero or less receive no traffic and
+// nodes without one are given the average weight, so selection is uniform
+// when none report a weight.
+func NodeWeighted(key string) Strategy {
+	return func(services []*registry.Service) Next {
+		var (
+			nodes   []*registry.Node
+			weights []float64
+			sum     float64
+			count   int
+		)
+
+		for _, service := range services {
+			for _, node := range service.Nodes {
+				w, err := strconv.ParseFloat(node.Metadata[key], 64)
+				if err != nil {
+					// unweighted, filled in below
+					w = -1
+				} else if w <= 0 {
+					continue
+				} else {
+					sum += w
+					count++
+				}
+
+				nodes = append(nodes, node)
+				weights = append(weights, w)
+			}
+		}
+
+		// uniform when no node reports a weight
+		avg := 1.0
+		if count > 0 {
+			avg = sum / float64(count)
+		}
+
+		var (
+			total  float64
+			shares = make([]float64, len(weights))
+		)
+
+		for i, w := range weights {
+			if w < 0 {
+				w = avg
+			}
+
+			total += w
+			shares[i] = total
+		}
+
+		return func() (*registry.Node, error) {
+			if len(nodes) == 0 {
+				return nil, ErrNoneAvailable
+			}
+
+			n := rand.Float64() * total
+
+			for i, share := range shares {
+				if n < share {
+					return nodes[i], nil
+				}
+			}
+
+			return nodes[len(nodes)-1], nil
+		}
+	}
+}
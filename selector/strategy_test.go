@@ -56,3 +56,95 @@ func TestStrategies(t *testing.T) {
 		}
 	}
 }
+
+func TestWeighted(t *testing.T) {
+	testData := []*registry.Service{
+		{
+			Name:    "test1",
+			Version: "v1",
+			Nodes:   []*registry.Node{{Id: "test1-1"}},
+		},
+		{
+			Name:     "test1",
+			Version:  "v2",
+			Metadata: map[string]string{"weight": "1"},
+			Nodes:    []*registry.Node{{Id: "test1-2"}},
+		},
+		{
+			Name:    "test1",
+			Version: "v3",
+			Nodes:   []*registry.Node{{Id: "test1-3"}},
+		},
+	}
+
+	next := Weighted(map[string]int{"v1": 3})(testData)
+	counts := make(map[string]int)
+
+	for i := 0; i < 1000; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[node.Id]++
+	}
+
+	if counts["test1-3"] != 0 {
+		t.Fatalf("Expected unweighted version to receive no traffic got %d", counts["test1-3"])
+	}
+
+	if counts["test1-1"] < counts["test1-2"] {
+		t.Fatalf("Expected v1 to receive more traffic than v2: %+v", counts)
+	}
+
+	// no weights falls back to random
+	next = Weighted(nil)(testData[2:])
+	if node, err := next(); err != nil || node.Id != "test1-3" {
+		t.Fatalf("Expected test1-3 got %v %v", node, err)
+	}
+}
+
+func TestNodeWeighted(t *testing.T) {
+	testData := []*registry.Service{
+		{
+			Name: "test1",
+			Nodes: []*registry.Node{
+				{Id: "test1-1", Metadata: map[string]string{"weight": "9"}},
+				{Id: "test1-2", Metadata: map[string]string{"weight": "1"}},
+				{Id: "test1-3", Metadata: map[string]string{"weight": "0"}},
+				{Id: "test1-4"},
+			},
+		},
+	}
+
+	next := NodeWeighted("weight")(testData)
+	counts := make(map[string]int)
+
+	for i := 0; i < 1000; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[node.Id]++
+	}
+
+	if counts["test1-3"] != 0 {
+		t.Fatalf("Expected zero weight node to receive no traffic got %d", counts["test1-3"])
+	}
+
+	// the unweighted node gets the average weight of 5
+	if counts["test1-1"] < counts["test1-4"] || counts["test1-4"] < counts["test1-2"] {
+		t.Fatalf("Expected traffic biased by weight: %+v", counts)
+	}
+
+	// no weights falls back to random
+	testData[0].Nodes = testData[0].Nodes[3:]
+	next = NodeWeighted("weight")(testData)
+	if node, err := next(); err != nil || node.Id != "test1-4" {
+		t.Fatalf("Expected test1-4 got %v %v", node, err)
+	}
+
+	// no nodes
+	if _, err := NodeWeighted("weight")(nil)(); err != ErrNoneAvailable {
+		t.Fatalf("Expected %v got %v", ErrNoneAvailable, err)
+	}
+}
@@ -12,10 +12,20 @@ type MockServer struct {
 	Opts        server.Options
 	Handlers    map[string]server.Handler
 	Subscribers map[string][]server.Subscriber
+	// Calls made to the server in order
+	Calls []MockCall
+	// Errors returned by method name, e.g. "Start"
+	Errors map[string]error
 	sync.Mutex
 	Running bool
 }
 
+// MockCall is a call recorded by the mock server.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
 var (
 	_ server.Server = NewServer()
 )
@@ -31,9 +41,36 @@ func newMockServer(opts ...server.Option) *MockServer {
 		Opts:        options,
 		Handlers:    make(map[string]server.Handler),
 		Subscribers: make(map[string][]server.Subscriber),
+		Errors:      make(map[string]error),
 	}
 }
 
+// record records the call and returns the error set for the method.
+func (m *MockServer) record(method string, args ...interface{}) error {
+	m.Calls = append(m.Calls, MockCall{
+		Method: method,
+		Args:   args,
+	})
+
+	return m.Errors[method]
+}
+
+// CallsTo returns the calls made to the method.
+func (m *MockServer) CallsTo(method string) []MockCall {
+	m.Lock()
+	defer m.Unlock()
+
+	var calls []MockCall
+
+	for _, c := range m.Calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
 func (m *MockServer) Options() server.Options {
 	m.Lock()
 	defer m.Unlock()
@@ -55,6 +92,10 @@ func (m *MockServer) Handle(h server.Handler) error {
 	m.Lock()
 	defer m.Unlock()
 
+	if err := m.record("Handle", h); err != nil {
+		return err
+	}
+
 	if _, ok := m.Handlers[h.Name()]; ok {
 		return errors.New("Handler " + h.Name() + " already exists")
 	}
@@ -82,9 +123,10 @@ func (m *MockServer) NewSubscriber(topic string, fn interface{}, opts ...server.
 	}
 
 	return &MockSubscriber{
-		Id:   topic,
-		Sub:  fn,
-		Opts: options,
+		Id:        uuid.New().String(),
+		TopicName: topic,
+		Sub:       fn,
+		Opts:      options,
 	}
 }
 
@@ -92,6 +134,10 @@ func (m *MockServer) Subscribe(sub server.Subscriber) error {
 	m.Lock()
 	defer m.Unlock()
 
+	if err := m.record("Subscribe", sub); err != nil {
+		return err
+	}
+
 	subs := m.Subscribers[sub.Topic()]
 	subs = append(subs, sub)
 	m.Subscribers[sub.Topic()] = subs
@@ -99,17 +145,27 @@ func (m *MockServer) Subscribe(sub server.Subscriber) error {
 }
 
 func (m *MockServer) Register() error {
-	return nil
+	m.Lock()
+	defer m.Unlock()
+
+	return m.record("Register")
 }
 
 func (m *MockServer) Deregister() error {
-	return nil
+	m.Lock()
+	defer m.Unlock()
+
+	return m.record("Deregister")
 }
 
 func (m *MockServer) Start() error {
 	m.Lock()
 	defer m.Unlock()
 
+	if err := m.record("Start"); err != nil {
+		return err
+	}
+
 	if m.Running {
 		return errors.New("already running")
 	}
@@ -122,6 +178,10 @@ func (m *MockServer) Stop() error {
 	m.Lock()
 	defer m.Unlock()
 
+	if err := m.record("Stop"); err != nil {
+		return err
+	}
+
 	if !m.Running {
 		return errors.New("not running")
 	}
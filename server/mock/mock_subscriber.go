@@ -9,9 +9,17 @@ type MockSubscriber struct {
 	Opts server.SubscriberOptions
 	Sub  interface{}
 	Id   string
+	// TopicName is the subscribed topic, a field can't
+	// share the name of the Topic method
+	TopicName string
 }
 
+// Topic returns the subscribed topic, or the Id if none is set.
 func (m *MockSubscriber) Topic() string {
+	if len(m.TopicName) > 0 {
+		return m.TopicName
+	}
+
 	return m.Id
 }
 
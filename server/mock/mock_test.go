@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"errors"
 	"testing"
 
 	"go-micro.org/v5/server"
@@ -55,3 +56,40 @@ func TestMockServer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMockSubscriberTopic(t *testing.T) {
+	if s := (&MockSubscriber{Id: "id", TopicName: "topic"}); s.Topic() != "topic" {
+		t.Fatalf("Expected topic got %s", s.Topic())
+	}
+
+	if s := (&MockSubscriber{Id: "id"}); s.Topic() != "id" {
+		t.Fatalf("Expected id fallback got %s", s.Topic())
+	}
+}
+
+func TestMockServerCalls(t *testing.T) {
+	srv := NewServer()
+	srv.Errors["Start"] = errors.New("start error")
+
+	sub := srv.NewSubscriber("test", func() string { return "foo" })
+	if err := srv.Subscribe(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Start(); err == nil || err.Error() != "start error" {
+		t.Fatalf("Expected start error got %v", err)
+	}
+
+	if srv.Running {
+		t.Fatal("Expected server to not be running")
+	}
+
+	calls := srv.CallsTo("Subscribe")
+	if len(calls) != 1 || calls[0].Args[0] != sub {
+		t.Fatalf("Expected subscribe call with the subscriber got %v", calls)
+	}
+
+	if len(srv.Calls) != 2 || srv.Calls[1].Method != "Start" {
+		t.Fatalf("Expected subscribe then start calls got %v", srv.Calls)
+	}
+}
@@ -53,8 +53,13 @@ func NewRoundTripper(opts ...Option) http.RoundTripper {
 		o(&options)
 	}
 
+	rt := options.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
 	return &roundTripper{
-		rt:   http.DefaultTransport,
+		rt:   rt,
 		st:   selector.Random,
 		opts: options,
 	}
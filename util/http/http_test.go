@@ -4,9 +4,12 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"go-micro.org/v5/registry"
+	"go-micro.org/v5/registry/cache"
 )
 
 func TestRoundTripper(t *testing.T) {
@@ -78,3 +81,85 @@ func TestRoundTripper(t *testing.T) {
 		t.Fatal("response is", string(b))
 	}
 }
+
+// countRegistry counts the lookups reaching the registry.
+type countRegistry struct {
+	registry.Registry
+
+	mu    sync.Mutex
+	count int
+}
+
+func (r *countRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	r.mu.Lock()
+	r.count++
+	r.mu.Unlock()
+
+	return r.Registry.GetService(name, opts...)
+}
+
+func (r *countRegistry) lookups() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.count
+}
+
+func TestRoundTripperCache(t *testing.T) {
+	m := &countRegistry{Registry: registry.NewMemoryRegistry()}
+
+	c := cache.New(m, cache.WithTTL(time.Minute))
+	defer c.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`hello world`))
+	})}
+
+	go srv.Serve(l)
+
+	m.Register(&registry.Service{
+		Name:  "cached.example.com",
+		Nodes: []*registry.Node{{Id: "1", Address: l.Addr().String()}},
+	})
+
+	client := &http.Client{Transport: NewRoundTripper(WithRegistry(c))}
+
+	get := func() error {
+		rsp, err := client.Get("http://cached.example.com")
+		if err != nil {
+			return err
+		}
+
+		rsp.Body.Close()
+
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := m.lookups(); n != 1 {
+		t.Fatalf("expected the nodes to be cached, got %d lookups", n)
+	}
+
+	// a failed request drops the stale nodes
+	srv.Close()
+
+	if err := get(); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+
+	get()
+
+	if n := m.lookups(); n != 2 {
+		t.Fatalf("expected the cache to be invalidated, got %d lookups", n)
+	}
+}
@@ -1,11 +1,17 @@
 package http
 
 import (
+	"net/http"
+
 	"go-micro.org/v5/registry"
 )
 
 type Options struct {
 	Registry registry.Registry
+	// Headers are set from the metadata of the request context
+	Headers []string
+	// Transport sends the requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
 }
 
 type Option func(*Options)
@@ -15,3 +21,20 @@ func WithRegistry(r registry.Registry) Option {
 		o.Registry = r
 	}
 }
+
+// WithHeaders sets the headers of requests from the metadata of their
+// context, e.g. an Authorization header propagated from the incoming
+// request. Headers already set on the request are kept.
+func WithHeaders(headers ...string) Option {
+	return func(o *Options) {
+		o.Headers = append(o.Headers, headers...)
+	}
+}
+
+// WithTransport sets the transport requests are sent with once their node
+// is selected, e.g. an http.Transport with tuned idle connection limits.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *Options) {
+		o.Transport = rt
+	}
+}
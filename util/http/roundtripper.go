@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"go-micro.org/v5/metadata"
 	"go-micro.org/v5/selector"
 )
 
@@ -14,13 +15,28 @@ type roundTripper struct {
 }
 
 func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	s, err := r.opts.Registry.GetService(req.URL.Host)
+	service := req.URL.Host
+
+	s, err := r.opts.Registry.GetService(service)
 	if err != nil {
 		return nil, err
 	}
 
 	next := r.st(s)
 
+	// the request is modified below so it's copied
+	req = req.Clone(req.Context())
+
+	for _, k := range r.opts.Headers {
+		if len(req.Header.Get(k)) > 0 {
+			continue
+		}
+
+		if v, ok := metadata.Get(req.Context(), k); ok && len(v) > 0 {
+			req.Header.Set(k, v)
+		}
+	}
+
 	// rudimentary retry 3 times
 	for i := 0; i < 3; i++ {
 		n, err := next()
@@ -35,5 +51,10 @@ func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		return w, nil
 	}
 
+	// the nodes of a caching registry may be stale
+	if c, ok := r.opts.Registry.(interface{ Invalidate(string) }); ok {
+		c.Invalidate(service)
+	}
+
 	return nil, errors.New("failed request")
 }
@@ -122,6 +122,10 @@ func (c *client) Log(r *Resource, opts ...LogOption) (io.ReadCloser, error) {
 		req.Params(&api.Params{Additional: options.Params})
 	}
 
+	if options.Context != nil {
+		req.Context(options.Context)
+	}
+
 	resp, err := req.Raw()
 	if err != nil {
 		return nil, err
@@ -1,5 +1,7 @@
 package client
 
+import "context"
+
 type CreateOptions struct {
 	Namespace string
 }
@@ -19,6 +21,7 @@ type ListOptions struct {
 }
 
 type LogOptions struct {
+	Context   context.Context
 	Params    map[string]string
 	Namespace string
 }
@@ -43,6 +46,14 @@ func LogParams(p map[string]string) LogOption {
 	}
 }
 
+// LogContext sets the context of the log request, cancelling
+// it aborts reading the logs.
+func LogContext(ctx context.Context) LogOption {
+	return func(l *LogOptions) {
+		l.Context = ctx
+	}
+}
+
 // WatchParams used for watch params.
 func WatchParams(p map[string]string) WatchOption {
 	return func(w *WatchOptions) {
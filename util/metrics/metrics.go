@@ -0,0 +1,25 @@
+// Package metrics provides helpers for prometheus metrics
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCollector registers the collector with the registerer. If an
+// equal collector is already registered, e.g. by another service in the
+// process, it's returned to be shared instead.
+func RegisterCollector(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+
+	return nil, err
+}
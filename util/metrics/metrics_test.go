@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	newCounter := func(help string) prometheus.Collector {
+		return prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: help})
+	}
+
+	c, err := RegisterCollector(reg, newCounter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an equal collector is shared
+	if shared, err := RegisterCollector(reg, newCounter("test")); err != nil || shared != c {
+		t.Fatalf("Expected the registered collector got %v %v", shared, err)
+	}
+
+	// a conflicting one fails
+	if _, err := RegisterCollector(reg, newCounter("other")); err == nil {
+		t.Fatal("Expected an error for a conflicting collector")
+	}
+}
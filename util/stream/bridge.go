@@ -0,0 +1,47 @@
+package stream
+
+import (
+	"io"
+
+	raw "go-micro.org/v5/codec/bytes"
+)
+
+// Bridge pumps messages between the streams in both directions until
+// either is closed or fails, then closes both. Messages are passed as
+// raw frames so they're not decoded. The first error is returned, a
+// stream ending with io.EOF returns nil.
+func Bridge(a, b Stream) error {
+	errs := make(chan error, 2)
+
+	go func() { errs <- pump(a, b) }()
+	go func() { errs <- pump(b, a) }()
+
+	err := <-errs
+
+	// unblock the other direction
+	a.Close()
+	b.Close()
+
+	<-errs
+
+	if err == io.EOF {
+		return nil
+	}
+
+	return err
+}
+
+// pump copies messages from src to dst.
+func pump(src, dst Stream) error {
+	for {
+		msg := new(raw.Frame)
+
+		if err := src.RecvMsg(msg); err != nil {
+			return err
+		}
+
+		if err := dst.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	raw "go-micro.org/v5/codec/bytes"
+)
+
+// testStream receives from in and sends to out.
+type testStream struct {
+	in  chan []byte
+	out chan []byte
+
+	once   sync.Once
+	closed chan bool
+	err    error
+}
+
+func newTestStream() *testStream {
+	return &testStream{
+		in:     make(chan []byte),
+		out:    make(chan []byte, 10),
+		closed: make(chan bool),
+	}
+}
+
+func (s *testStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testStream) SendMsg(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.out <- v.(*raw.Frame).Data
+
+	return nil
+}
+
+func (s *testStream) RecvMsg(v interface{}) error {
+	select {
+	case b, ok := <-s.in:
+		if !ok {
+			return io.EOF
+		}
+
+		v.(*raw.Frame).Data = b
+
+		return nil
+	case <-s.closed:
+		return io.EOF
+	}
+}
+
+func (s *testStream) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestBridge(t *testing.T) {
+	a, b := newTestStream(), newTestStream()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- Bridge(a, b) }()
+
+	a.in <- []byte("ping")
+	if msg := <-b.out; string(msg) != "ping" {
+		t.Fatalf("Expected ping got %s", msg)
+	}
+
+	b.in <- []byte("pong")
+	if msg := <-a.out; string(msg) != "pong" {
+		t.Fatalf("Expected pong got %s", msg)
+	}
+
+	// closing one side ends the bridge
+	close(a.in)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Expected no error got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the bridge to end")
+	}
+
+	select {
+	case <-b.closed:
+	default:
+		t.Fatal("Expected both streams to be closed")
+	}
+
+	// the first error is returned
+	a, b = newTestStream(), newTestStream()
+	b.err = errors.New("send failed")
+
+	go func() { errCh <- Bridge(a, b) }()
+
+	a.in <- []byte("ping")
+
+	if err := <-errCh; err != b.err {
+		t.Fatalf("Expected %v got %v", b.err, err)
+	}
+}
@@ -3,10 +3,13 @@ package stream
 
 import (
 	"context"
+	"reflect"
 	"sync"
+	"time"
 
 	"go-micro.org/v5/client"
 	"go-micro.org/v5/codec"
+	"go-micro.org/v5/errors"
 	"go-micro.org/v5/metadata"
 	"go-micro.org/v5/server"
 )
@@ -18,15 +21,50 @@ type Stream interface {
 	Close() error
 }
 
+// TimeoutStream is a server.Stream with a bounded receive,
+// implemented by the streams returned by New.
+type TimeoutStream interface {
+	server.Stream
+	RecvWithTimeout(v interface{}, d time.Duration) error
+}
+
+// Options configure the stream.
+type Options struct {
+	// Window is the number of sends which may be in flight
+	// at once, unbounded if zero
+	Window int
+}
+
+// Option sets a stream option.
+type Option func(o *Options)
+
+// Window bounds the number of concurrent sends. Once n are in flight Send
+// blocks until one completes or the stream context is done.
+func Window(n int) Option {
+	return func(o *Options) {
+		o.Window = n
+	}
+}
+
 type stream struct {
 	Stream
 
 	err     error
 	request *request
+	// window limits the sends in flight
+	window chan struct{}
+	// pending is the receive of a timed out RecvWithTimeout
+	pending chan *received
 
 	sync.RWMutex
 }
 
+// received is the result of a receive into a value of the stream.
+type received struct {
+	v   interface{}
+	err error
+}
+
 type request struct {
 	client.Request
 	context context.Context
@@ -50,6 +88,15 @@ func (s *stream) Request() server.Request {
 }
 
 func (s *stream) Send(v interface{}) error {
+	if s.window != nil {
+		select {
+		case s.window <- struct{}{}:
+			defer func() { <-s.window }()
+		case <-s.Stream.Context().Done():
+			return s.Stream.Context().Err()
+		}
+	}
+
 	err := s.Stream.SendMsg(v)
 	if err != nil {
 		s.Lock()
@@ -60,7 +107,14 @@ func (s *stream) Send(v interface{}) error {
 }
 
 func (s *stream) Recv(v interface{}) error {
-	err := s.Stream.RecvMsg(v)
+	var err error
+
+	if ch := s.takePending(); ch != nil {
+		err = s.copyOut(v, <-ch)
+	} else {
+		err = s.Stream.RecvMsg(v)
+	}
+
 	if err != nil {
 		s.Lock()
 		s.err = err
@@ -69,6 +123,79 @@ func (s *stream) Recv(v interface{}) error {
 	return err
 }
 
+// RecvWithTimeout receives like Recv but fails with a timeout error if no
+// message arrives within d. The receive can't be cancelled, so it's kept
+// pending on timeout and the next Recv or RecvWithTimeout waits for it.
+func (s *stream) RecvWithTimeout(v interface{}, d time.Duration) error {
+	ch := s.takePending()
+	if ch == nil {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return errors.InternalServerError("go.micro.stream", "can't receive into %T", v)
+		}
+
+		// decode into a value of our own so a late
+		// receive doesn't write to the caller's
+		m := reflect.New(rv.Type().Elem()).Interface()
+
+		ch = make(chan *received, 1)
+		go func() {
+			ch <- &received{v: m, err: s.Stream.RecvMsg(m)}
+		}()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	var err error
+
+	select {
+	case r := <-ch:
+		err = s.copyOut(v, r)
+	case <-t.C:
+		s.Lock()
+		s.pending = ch
+		s.Unlock()
+
+		err = errors.Timeout("go.micro.stream", "recv timed out after %v", d)
+	}
+
+	if err != nil {
+		s.Lock()
+		s.err = err
+		s.Unlock()
+	}
+
+	return err
+}
+
+// takePending returns the pending receive, nil if there's none.
+func (s *stream) takePending() chan *received {
+	s.Lock()
+	defer s.Unlock()
+
+	ch := s.pending
+	s.pending = nil
+
+	return ch
+}
+
+// copyOut copies the received value to v.
+func (s *stream) copyOut(v interface{}, r *received) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	dst, src := reflect.ValueOf(v), reflect.ValueOf(r.v)
+	if dst.Type() != src.Type() || dst.IsNil() {
+		return errors.InternalServerError("go.micro.stream", "can't receive %T into %T", r.v, v)
+	}
+
+	dst.Elem().Set(src.Elem())
+
+	return nil
+}
+
 func (s *stream) Error() error {
 	s.RLock()
 	defer s.RUnlock()
@@ -77,12 +204,23 @@ func (s *stream) Error() error {
 
 // New returns a new encapsulated stream
 // Proto stream within a server.Stream.
-func New(service, endpoint string, req interface{}, s Stream) server.Stream {
-	return &stream{
+func New(service, endpoint string, req interface{}, s Stream, opts ...Option) server.Stream {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	st := &stream{
 		Stream: s,
 		request: &request{
 			context: s.Context(),
 			Request: client.DefaultClient.NewRequest(service, endpoint, req),
 		},
 	}
+
+	if options.Window > 0 {
+		st.window = make(chan struct{}, options.Window)
+	}
+
+	return st
 }
@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	raw "go-micro.org/v5/codec/bytes"
+	"go-micro.org/v5/errors"
+)
+
+// slowStream blocks each send until released.
+type slowStream struct {
+	ctx      context.Context
+	release  chan bool
+	inflight int32
+	max      int32
+}
+
+func (s *slowStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *slowStream) SendMsg(v interface{}) error {
+	n := atomic.AddInt32(&s.inflight, 1)
+	defer atomic.AddInt32(&s.inflight, -1)
+
+	for {
+		m := atomic.LoadInt32(&s.max)
+		if n <= m || atomic.CompareAndSwapInt32(&s.max, m, n) {
+			break
+		}
+	}
+
+	<-s.release
+
+	return nil
+}
+
+func (s *slowStream) RecvMsg(v interface{}) error {
+	return nil
+}
+
+func (s *slowStream) Close() error {
+	return nil
+}
+
+func TestWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ss := &slowStream{ctx: ctx, release: make(chan bool)}
+	st := New("go.micro.srv.test", "Test.Stream", nil, ss, Window(2))
+
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() { errs <- st.Send("msg") }()
+	}
+
+	// let the sends queue up
+	time.Sleep(time.Millisecond * 50)
+
+	if n := atomic.LoadInt32(&ss.inflight); n != 2 {
+		t.Fatalf("Expected 2 sends in flight got %d", n)
+	}
+
+	for i := 0; i < 4; i++ {
+		ss.release <- true
+
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m := atomic.LoadInt32(&ss.max); m != 2 {
+		t.Fatalf("Expected at most 2 sends in flight got %d", m)
+	}
+
+	// a blocked send returns when the context is done
+	go func() { errs <- st.Send("msg") }()
+	go func() { errs <- st.Send("msg") }()
+	go func() { errs <- st.Send("msg") }()
+
+	time.Sleep(time.Millisecond * 50)
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != context.Canceled {
+			t.Fatalf("Expected %v got %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the blocked send to return")
+	}
+
+	close(ss.release)
+}
+
+func TestRecvWithTimeout(t *testing.T) {
+	a := newTestStream()
+	st := New("go.micro.srv.test", "Test.Stream", nil, a).(TimeoutStream)
+
+	go func() { a.in <- []byte("ping") }()
+
+	msg := new(raw.Frame)
+	if err := st.RecvWithTimeout(msg, time.Second); err != nil || string(msg.Data) != "ping" {
+		t.Fatalf("Expected ping got %q %v", msg.Data, err)
+	}
+
+	err := st.RecvWithTimeout(new(raw.Frame), time.Millisecond*10)
+	if e := errors.FromError(err); e.Code != http.StatusRequestTimeout {
+		t.Fatalf("Expected a timeout error got %v", err)
+	}
+
+	if st.Error() != err {
+		t.Fatalf("Expected the error to be stored got %v", st.Error())
+	}
+
+	// the next receive gets the message of the pending one
+	go func() { a.in <- []byte("pong") }()
+
+	msg = new(raw.Frame)
+	if err := st.RecvWithTimeout(msg, time.Second); err != nil || string(msg.Data) != "pong" {
+		t.Fatalf("Expected pong got %q %v", msg.Data, err)
+	}
+
+	// as does a plain receive
+	if err := st.RecvWithTimeout(new(raw.Frame), time.Millisecond*10); err == nil {
+		t.Fatal("Expected a timeout error")
+	}
+
+	go func() { a.in <- []byte("ping") }()
+
+	msg = new(raw.Frame)
+	if err := st.Recv(msg); err != nil || string(msg.Data) != "ping" {
+		t.Fatalf("Expected ping got %q %v", msg.Data, err)
+	}
+
+	// closing the stream ends the pending receive
+	st.RecvWithTimeout(new(raw.Frame), time.Millisecond*10)
+	a.Close()
+
+	if err := st.Recv(new(raw.Frame)); err != io.EOF {
+		t.Fatalf("Expected EOF got %v", err)
+	}
+}
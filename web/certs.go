@@ -0,0 +1,140 @@
+package web
+
+import (
+	"bytes"
+	"crypto/tls"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "go-micro.org/v5/logger"
+)
+
+// certReloader serves the certificate of the files, reloading it when
+// they change so new connections get the rotated certificate.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   log.Logger
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+// load reads the files, keeping the current certificate on error. It
+// reports whether the certificate changed.
+func (c *certReloader) load() (bool, error) {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.cert != nil && bytes.Equal(c.cert.Certificate[0], cert.Certificate[0]) {
+		return false, nil
+	}
+
+	c.cert = &cert
+
+	return true, nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.cert, nil
+}
+
+// config returns a copy of the config serving the current certificate,
+// unless one of the SNI certificates matches.
+func (c *certReloader) config(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	sni := config.GetCertificate
+
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if sni != nil {
+			if cert, err := sni(hello); cert != nil || err != nil {
+				return cert, err
+			}
+		}
+
+		return c.GetCertificate(hello)
+	}
+
+	return config
+}
+
+// watch reloads the certificate when the files change until done. The
+// directories are watched as the files are usually replaced, e.g. by
+// swapping the symlink of a mounted kubernetes secret.
+func (c *certReloader) watch(done chan bool) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.logger.Logf(log.ErrorLevel, "Certificate watch error: %v", err)
+		return
+	}
+	defer fw.Close()
+
+	for _, dir := range []string{filepath.Dir(c.certFile), filepath.Dir(c.keyFile)} {
+		if err := fw.Add(dir); err != nil {
+			c.logger.Logf(log.ErrorLevel, "Certificate watch %s error: %v", dir, err)
+			return
+		}
+	}
+
+	for {
+		select {
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+
+			// chmod events don't change the content
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+
+			// the cert and key may be written one at a time,
+			// the next event loads the matching pair
+			changed, err := c.load()
+			if err != nil {
+				c.logger.Logf(log.DebugLevel, "Certificate reload error: %v", err)
+				continue
+			}
+
+			if changed {
+				c.logger.Logf(log.InfoLevel, "Reloaded certificate %s", c.certFile)
+			}
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+
+			c.logger.Logf(log.ErrorLevel, "Certificate watch error: %v", err)
+		case <-done:
+			return
+		}
+	}
+}
+
+func newCertReloader(certFile, keyFile string, logger log.Logger) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+
+	if _, err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,300 @@
+package web
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// DefaultCompressMinSize is the smallest response body compressed.
+	DefaultCompressMinSize = 1024
+
+	// content types which are already compressed
+	compressedTypes = []string{
+		"image/",
+		"video/",
+		"audio/",
+		"font/woff",
+		"application/gzip",
+		"application/x-gzip",
+		"application/zip",
+		"application/zstd",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+		"application/octet-stream",
+		"text/event-stream",
+	}
+)
+
+// CompressOptions configure response compression.
+type CompressOptions struct {
+	// MinSize is the smallest body compressed
+	MinSize int
+	// Level is the gzip compression level
+	Level int
+}
+
+// CompressOption sets a compression option.
+type CompressOption func(o *CompressOptions)
+
+// CompressMinSize sets the smallest response body which is compressed.
+func CompressMinSize(n int) CompressOption {
+	return func(o *CompressOptions) {
+		o.MinSize = n
+	}
+}
+
+// CompressLevel sets the gzip compression level e.g. gzip.BestSpeed.
+// Levels gzip doesn't support are clamped to HuffmanOnly or
+// BestCompression.
+func CompressLevel(l int) CompressOption {
+	if l < gzip.HuffmanOnly {
+		l = gzip.HuffmanOnly
+	} else if l > gzip.BestCompression {
+		l = gzip.BestCompression
+	}
+
+	return func(o *CompressOptions) {
+		o.Level = l
+	}
+}
+
+// acceptsGzip reports whether the client accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(v, ";")
+
+		enc := strings.TrimSpace(parts[0])
+		if enc != "gzip" && enc != "*" {
+			continue
+		}
+
+		// q=0 means not acceptable
+		for _, p := range parts[1:] {
+			if q := strings.TrimSpace(p); strings.HasPrefix(q, "q=") {
+				if f, err := strconv.ParseFloat(q[2:], 64); err == nil && f == 0 {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// compressHandler gzips the responses of the handler for clients which
+// accept it, skipping small bodies and compressed content types.
+func (s *service) compressHandler(h http.Handler) http.Handler {
+	opts := *s.opts.Compress
+
+	pool := sync.Pool{
+		New: func() interface{} {
+			gz, _ := gzip.NewWriterLevel(nil, opts.Level)
+			return gz
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(r) || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			minSize:        opts.MinSize,
+			pool:           &pool,
+		}
+		defer cw.Close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of the body to decide
+// whether the response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+
+	minSize int
+	pool    *sync.Pool
+
+	status int
+	buf    []byte
+	// the headers have been written
+	started bool
+	// the connection was taken over
+	hijacked bool
+	gz       *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.started || w.status != 0 {
+		return
+	}
+
+	w.status = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+
+	if len(w.buf) < w.minSize {
+		return len(b), nil
+	}
+
+	if err := w.start(true); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// compressible reports whether the response may be compressed.
+func (w *compressWriter) compressible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	hdr := w.Header()
+
+	// the ranges are of the uncompressed body
+	if len(hdr.Get("Content-Encoding")) > 0 || len(hdr.Get("Content-Range")) > 0 {
+		return false
+	}
+
+	// sniff the type as the server would
+	ct := hdr.Get("Content-Type")
+	if len(ct) == 0 {
+		ct = http.DetectContentType(w.buf)
+		hdr.Set("Content-Type", ct)
+	}
+
+	mt, _, _ := mime.ParseMediaType(ct)
+
+	for _, t := range compressedTypes {
+		if strings.HasPrefix(mt, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// start writes the headers and the buffered body.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if compress && w.compressible() {
+		hdr := w.Header()
+		hdr.Set("Content-Encoding", "gzip")
+		hdr.Del("Content-Length")
+
+		w.gz = w.pool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(buf)
+
+	return err
+}
+
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start(len(w.buf) > 0)
+	}
+
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes a body smaller than the minimum size uncompressed
+// and completes the gzip stream.
+func (w *compressWriter) Close() error {
+	if w.hijacked {
+		return nil
+	}
+
+	if !w.started {
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+
+	if w.gz == nil {
+		return nil
+	}
+
+	err := w.gz.Close()
+	w.pool.Put(w.gz)
+	w.gz = nil
+
+	return err
+}
+
+// Hijack hands the connection to the handler, e.g. to upgrade it to a
+// websocket, once nothing has been written.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.started || len(w.buf) > 0 {
+		return nil, nil, errors.New("response already written")
+	}
+
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
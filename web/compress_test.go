@@ -0,0 +1,188 @@
+package web
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+
+	testData := []struct {
+		accept  string
+		ct      string
+		body    string
+		gzipped bool
+	}{
+		{"gzip, deflate", "application/json", large, true},
+		{"gzip;q=0", "application/json", large, false},
+		{"", "application/json", large, false},
+		{"gzip", "application/json", "small", false},
+		{"gzip", "image/png", large, false},
+		// the content type is sniffed
+		{"gzip", "", large, true},
+	}
+
+	for _, d := range testData {
+		s := &service{opts: newOptions(Compress(CompressMinSize(100)))}
+
+		h := s.compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(d.ct) > 0 {
+				w.Header().Set("Content-Type", d.ct)
+			}
+
+			// written in parts across the threshold
+			io.WriteString(w, d.body[:len(d.body)/2])
+			io.WriteString(w, d.body[len(d.body)/2:])
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if len(d.accept) > 0 {
+			req.Header.Set("Accept-Encoding", d.accept)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%+v: expected Vary header got %q", d, w.Header().Get("Vary"))
+		}
+
+		gzipped := w.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != d.gzipped {
+			t.Fatalf("%+v: expected gzipped %v got %v", d, d.gzipped, gzipped)
+		}
+
+		var body io.Reader = w.Body
+
+		if gzipped {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body = gz
+		}
+
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != d.body {
+			t.Fatalf("%+v: unexpected body %q", d, b)
+		}
+	}
+}
+
+func TestCompressSkip(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+
+	testData := []struct {
+		status int
+		header map[string]string
+	}{
+		{http.StatusPartialContent, nil},
+		{http.StatusOK, map[string]string{"Content-Range": "bytes 0-99/2400"}},
+		{http.StatusOK, map[string]string{"Content-Encoding": "br"}},
+	}
+
+	for _, d := range testData {
+		s := &service{opts: newOptions(Compress(CompressMinSize(100)))}
+
+		h := s.compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range d.header {
+				w.Header().Set(k, v)
+			}
+
+			w.WriteHeader(d.status)
+			io.WriteString(w, large)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") == "gzip" {
+			t.Fatalf("%+v: expected the response not to be gzipped", d)
+		}
+
+		if w.Code != d.status || w.Body.String() != large {
+			t.Fatalf("%+v: unexpected response %d", d, w.Code)
+		}
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	testData := []struct {
+		level int
+		want  int
+	}{
+		{gzip.BestSpeed, gzip.BestSpeed},
+		{gzip.BestCompression + 1, gzip.BestCompression},
+		{gzip.HuffmanOnly - 1, gzip.HuffmanOnly},
+	}
+
+	for _, d := range testData {
+		var opts CompressOptions
+		CompressLevel(d.level)(&opts)
+
+		if opts.Level != d.want {
+			t.Fatalf("%d: expected level %d got %d", d.level, d.want, opts.Level)
+		}
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	written  bool
+}
+
+func (h *hijackRecorder) WriteHeader(code int) {
+	h.written = true
+	h.ResponseRecorder.WriteHeader(code)
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestCompressHijack(t *testing.T) {
+	s := &service{opts: newOptions(Compress())}
+
+	h := s.compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected the writer to be a hijacker")
+		}
+
+		if _, _, err := hj.Hijack(); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, req)
+
+	if !w.hijacked {
+		t.Fatal("expected the connection to be hijacked")
+	}
+
+	// nothing is written once hijacked
+	if w.written || w.Body.Len() > 0 {
+		t.Fatalf("unexpected response written %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+)
+
+// ConnStats are the connections of the Client to an upstream node.
+type ConnStats struct {
+	// Host is the address of the node
+	Host string
+	// Active is the number of requests in flight
+	Active int
+	// Idle is the number of open connections without a request
+	Idle int
+	// Waiting is the number of requests waiting for a connection
+	Waiting int
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// hostConns are the counts of a host.
+type hostConns struct {
+	open    int
+	active  int
+	waiting int
+}
+
+// connStats counts the connections and requests of a transport by host.
+type connStats struct {
+	sync.Mutex
+	hosts map[string]*hostConns
+}
+
+// add updates the counts of the host, dropping
+// those of hosts without connections or requests.
+func (c *connStats) add(host string, fn func(*hostConns)) {
+	c.Lock()
+	defer c.Unlock()
+
+	h, ok := c.hosts[host]
+	if !ok {
+		h = new(hostConns)
+		c.hosts[host] = h
+	}
+
+	fn(h)
+
+	if h.open == 0 && h.active == 0 && h.waiting == 0 {
+		delete(c.hosts, host)
+	}
+}
+
+func (c *connStats) stats() []ConnStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := make([]ConnStats, 0, len(c.hosts))
+
+	for host, h := range c.hosts {
+		idle := h.open - h.active
+		if idle < 0 {
+			// requests share http/2 connections
+			idle = 0
+		}
+
+		stats = append(stats, ConnStats{
+			Host:    host,
+			Active:  h.active,
+			Idle:    idle,
+			Waiting: h.waiting,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Host < stats[j].Host
+	})
+
+	return stats
+}
+
+// dial wraps the dialer counting the open connections.
+func (c *connStats) dial(next dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		c.add(addr, func(h *hostConns) { h.open++ })
+
+		return &statsConn{Conn: conn, done: func() {
+			c.add(addr, func(h *hostConns) { h.open-- })
+		}}, nil
+	}
+}
+
+// statsConn is a connection which is counted until it's closed.
+type statsConn struct {
+	net.Conn
+
+	once sync.Once
+	done func()
+}
+
+func (c *statsConn) Close() error {
+	c.once.Do(c.done)
+	return c.Conn.Close()
+}
+
+// statsTransport counts the requests waiting for
+// a connection and in flight until their body is read.
+type statsTransport struct {
+	rt    http.RoundTripper
+	stats *connStats
+}
+
+func (t *statsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := r.URL.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if r.URL.Scheme == "https" {
+			host = net.JoinHostPort(host, "443")
+		} else {
+			host = net.JoinHostPort(host, "80")
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		waiting bool
+		active  bool
+	)
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !waiting && !active {
+				waiting = true
+				t.stats.add(host, func(h *hostConns) { h.waiting++ })
+			}
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if active {
+				return
+			}
+
+			active = true
+			t.stats.add(host, func(h *hostConns) {
+				if waiting {
+					h.waiting--
+				}
+				h.active++
+			})
+			waiting = false
+		},
+	}
+
+	// done stops counting the request once
+	done := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !waiting && !active {
+			return
+		}
+
+		t.stats.add(host, func(h *hostConns) {
+			if waiting {
+				h.waiting--
+			}
+			if active {
+				h.active--
+			}
+		})
+		waiting, active = false, false
+	}
+
+	rsp, err := t.rt.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	rsp.Body = &statsBody{ReadCloser: rsp.Body, done: done}
+
+	return rsp, nil
+}
+
+// statsBody ends the request when it's read or closed.
+type statsBody struct {
+	io.ReadCloser
+
+	once sync.Once
+	done func()
+}
+
+func (b *statsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+
+	return n, err
+}
+
+func (b *statsBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+func newConnStats() *connStats {
+	return &connStats{
+		hosts: make(map[string]*hostConns),
+	}
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"go-micro.org/v5/registry"
+)
+
+// methodHandler routes the requests of a pattern by their method,
+// answering those of other methods with a 405.
+type methodHandler struct {
+	sync.RWMutex
+	handlers map[string]http.Handler
+	// allowed are the methods in registration order
+	allowed []string
+}
+
+func (m *methodHandler) add(method string, h http.Handler) {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.handlers[method]; !ok {
+		m.allowed = append(m.allowed, method)
+	}
+
+	m.handlers[method] = h
+}
+
+func (m *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.RLock()
+	h, ok := m.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		// a get handler serves head requests
+		h, ok = m.handlers[http.MethodGet]
+	}
+	allow := strings.Join(m.allowed, ", ")
+	m.RUnlock()
+
+	if !ok {
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+// addMethod adds the method to the "method" metadata of the endpoint of
+// the pattern, adding the endpoint if it's unseen. The caller must hold
+// the lock.
+func (s *service) addMethod(pattern, method string) {
+	var ep *registry.Endpoint
+
+	for _, e := range s.srv.Endpoints {
+		if e.Name == pattern {
+			ep = e
+			break
+		}
+	}
+
+	if ep == nil {
+		ep = &registry.Endpoint{Name: pattern}
+		s.srv.Endpoints = append(s.srv.Endpoints, ep)
+	}
+
+	methods := split(ep.Metadata["method"])
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return
+		}
+	}
+
+	// copy the metadata as it may be shared by registered services
+	md := make(map[string]string, len(ep.Metadata)+1)
+	for k, v := range ep.Metadata {
+		md[k] = v
+	}
+
+	md["method"] = strings.Join(append(methods, method), ",")
+	ep.Metadata = md
+}
+
+func (s *service) HandleMethod(method, pattern string, handler http.Handler) {
+	method = strings.ToUpper(method)
+
+	s.Lock()
+	s.addMethod(pattern, method)
+
+	// further methods of the pattern are added to its handler
+	if mh, ok := s.methods[pattern]; ok {
+		s.Unlock()
+		mh.add(method, handler)
+		return
+	}
+
+	mh := &methodHandler{handlers: make(map[string]http.Handler)}
+	mh.add(method, handler)
+
+	if s.methods == nil {
+		s.methods = make(map[string]*methodHandler)
+	}
+
+	s.methods[pattern] = mh
+
+	// disable static serving
+	if pattern == "/" {
+		s.static = false
+	}
+	s.Unlock()
+
+	s.mux.Handle(pattern, mh)
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"bufio"
+	errs "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-micro.org/v5/debug/stats"
+	"go-micro.org/v5/util/metrics"
+)
+
+// Metrics records request metrics for the web service. Requests are labeled
+// by method and the registered route pattern rather than the raw path so
+// path parameters don't explode the label cardinality.
+type Metrics interface {
+	// Begin is called when a request starts. Use it to track in-flight requests.
+	Begin(method, path string)
+	// End is called when a request completes with the status code and latency.
+	End(method, path string, status int, d time.Duration)
+}
+
+type statsMetrics struct {
+	stats stats.Stats
+}
+
+func (m *statsMetrics) Begin(method, path string) {}
+
+func (m *statsMetrics) End(method, path string, status int, d time.Duration) {
+	var err error
+	if status >= http.StatusInternalServerError {
+		err = fmt.Errorf("%s %s: %d %s", method, path, status, http.StatusText(status))
+	}
+
+	m.stats.Record(err)
+}
+
+// NewStatsMetrics returns Metrics which records request counts and errors
+// to the given stats. The stats have no in-flight or latency figures, use
+// NewPrometheusMetrics for those.
+func NewStatsMetrics(st stats.Stats) Metrics {
+	return &statsMetrics{stats: st}
+}
+
+type prometheusMetrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func (m *prometheusMetrics) Begin(method, path string) {
+	m.inFlight.WithLabelValues(method, path).Inc()
+}
+
+func (m *prometheusMetrics) End(method, path string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+
+	m.inFlight.WithLabelValues(method, path).Dec()
+	m.requests.WithLabelValues(method, path, code).Inc()
+	m.latency.WithLabelValues(method, path, code).Observe(d.Seconds())
+}
+
+// NewPrometheusMetrics returns Metrics which registers a request counter,
+// an in-flight gauge and a latency histogram with the registerer, the
+// default one if nil. Metrics already registered by another service are
+// shared, an error is returned if others conflict with them.
+func NewPrometheusMetrics(reg prometheus.Registerer) (Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	labels := []string{"method", "path", "status"}
+
+	requests, err := metrics.RegisterCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "micro",
+		Subsystem: "web",
+		Name:      "requests_total",
+		Help:      "Requests handled by the web service.",
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+
+	latency, err := metrics.RegisterCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "micro",
+		Subsystem: "web",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests handled by the web service.",
+		Buckets:   prometheus.DefBuckets,
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+
+	inFlight, err := metrics.RegisterCollector(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "micro",
+		Subsystem: "web",
+		Name:      "requests_in_flight",
+		Help:      "Requests the web service is handling.",
+	}, []string{"method", "path"}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &prometheusMetrics{
+		requests: requests.(*prometheus.CounterVec),
+		latency:  latency.(*prometheus.HistogramVec),
+		inFlight: inFlight.(*prometheus.GaugeVec),
+	}, nil
+}
+
+// statusWriter captures the status code written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection to the handler, e.g. to upgrade
+// it to a websocket.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errs.New("response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// pattern returns the registered route pattern for the request.
+func (s *service) pattern(r *http.Request) string {
+	if _, p := s.mux.Handler(r); len(p) > 0 {
+		return p
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, ep := range s.srv.Endpoints {
+		if ep.Name == r.URL.Path {
+			return ep.Name
+		}
+	}
+
+	return "unmatched"
+}
+
+// metricsHandler wraps the handler recording request metrics.
+func (s *service) metricsHandler(h http.Handler) http.Handler {
+	m := s.opts.Metrics
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := s.pattern(r)
+		start := time.Now()
+
+		m.Begin(r.Method, path)
+
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		m.End(r.Method, path, sw.status, time.Since(start))
+	})
+}
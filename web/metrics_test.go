@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"go-micro.org/v5/debug/stats"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gather := func() map[string]*dto.Metric {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metrics := make(map[string]*dto.Metric)
+		for _, mf := range mfs {
+			for _, metric := range mf.GetMetric() {
+				metrics[mf.GetName()] = metric
+			}
+		}
+
+		return metrics
+	}
+
+	m.Begin("GET", "/foo/")
+
+	if g := gather()["micro_web_requests_in_flight"]; g == nil || g.GetGauge().GetValue() != 1 {
+		t.Fatalf("Expected a request in flight got %v", g)
+	}
+
+	m.End("GET", "/foo/", 202, 50*time.Millisecond)
+
+	metrics := gather()
+
+	if g := metrics["micro_web_requests_in_flight"]; g.GetGauge().GetValue() != 0 {
+		t.Fatalf("Expected no requests in flight got %v", g)
+	}
+
+	c := metrics["micro_web_requests_total"]
+	if c == nil || c.GetCounter().GetValue() != 1 {
+		t.Fatalf("Expected a request got %v", c)
+	}
+
+	labels := make(map[string]string)
+	for _, l := range c.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	if labels["method"] != "GET" || labels["path"] != "/foo/" || labels["status"] != "202" {
+		t.Fatalf("Unexpected labels %v", labels)
+	}
+
+	h := metrics["micro_web_request_duration_seconds"].GetHistogram()
+	if h.GetSampleCount() != 1 || h.GetSampleSum() != 0.05 {
+		t.Fatalf("Expected the latency observed got %v", h)
+	}
+
+	// the metrics of a second service are shared
+	m, err = NewPrometheusMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.End("GET", "/foo/", 202, 0)
+
+	if c := gather()["micro_web_requests_total"]; c.GetCounter().GetValue() != 2 {
+		t.Fatalf("Expected two requests got %v", c)
+	}
+}
+
+func TestPrometheusMetricsConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// a collector of the same name with other labels
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "micro",
+		Subsystem: "web",
+		Name:      "requests_total",
+		Help:      "Requests handled by the web service.",
+	}, []string{"path"}))
+
+	if _, err := NewPrometheusMetrics(reg); err == nil {
+		t.Fatal("Expected an error for conflicting metrics")
+	}
+}
+
+func TestMetricsHijack(t *testing.T) {
+	s := NewService(Name("go.micro.web.test"), RequestMetrics(NewStatsMetrics(stats.NewStats()))).(*service)
+
+	h := s.metricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected the writer to be a hijacker")
+		}
+
+		if _, _, err := hj.Hijack(); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if !w.hijacked {
+		t.Fatal("expected the connection to be hijacked")
+	}
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go-micro.org/v5/registry"
+)
+
+// openAPIVersion is the version of the generated document.
+const openAPIVersion = "3.0.3"
+
+type openAPIDoc struct {
+	OpenAPI string                     `json:"openapi"`
+	Info    openAPIInfo                `json:"info"`
+	Paths   map[string]openAPIPathItem `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// openAPIPathItem maps a lower case method to its operation.
+type openAPIPathItem map[string]*openAPIOperation
+
+type openAPIOperation struct {
+	Summary     string                  `json:"summary,omitempty"`
+	Parameters  []*openAPIParameter     `json:"parameters,omitempty"`
+	RequestBody *openAPIBody            `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIBody `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+type openAPIBody struct {
+	Description string                            `json:"description,omitempty"`
+	Content     map[string]map[string]interface{} `json:"content,omitempty"`
+}
+
+// OpenAPI returns an OpenAPI 3.0 document describing the endpoints
+// registered with Handle, HandleFunc and the Endpoint option. The "path",
+// "method" and "description" endpoint metadata are used when set, the
+// endpoint name is used as the path otherwise. Request and response
+// schemas are generated from the endpoint's registry values.
+func (s *service) OpenAPI() ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	doc := &openAPIDoc{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   s.opts.Name,
+			Version: s.opts.Version,
+		},
+		Paths: make(map[string]openAPIPathItem),
+	}
+
+	for _, ep := range s.srv.Endpoints {
+		methods, paths := endpointRoutes(ep)
+
+		for _, p := range paths {
+			item, ok := doc.Paths[p]
+			if !ok {
+				item = make(openAPIPathItem)
+				doc.Paths[p] = item
+			}
+
+			for _, m := range methods {
+				item[m] = openAPIOp(ep, p)
+			}
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// endpointRoutes returns the lower case methods and paths of the endpoint.
+// Without declared methods an endpoint with a request body is assumed to
+// be a post, one with only a response a get and otherwise none are listed.
+func endpointRoutes(ep *registry.Endpoint) ([]string, []string) {
+	var methods, paths []string
+
+	name := ep.Name
+
+	// method qualified mux patterns e.g GET /foo
+	if i := strings.IndexByte(name, ' '); i > 0 {
+		methods = append(methods, strings.ToLower(name[:i]))
+		name = strings.TrimSpace(name[i+1:])
+	}
+
+	for _, m := range split(ep.Metadata["method"]) {
+		methods = append(methods, strings.ToLower(m))
+	}
+
+	paths = split(ep.Metadata["path"])
+	if len(paths) == 0 {
+		paths = []string{name}
+	}
+
+	if len(methods) == 0 {
+		switch {
+		case ep.Request != nil:
+			methods = []string{"post"}
+		case ep.Response != nil:
+			methods = []string{"get"}
+		}
+	}
+
+	return methods, paths
+}
+
+func split(s string) []string {
+	var sl []string
+
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			sl = append(sl, p)
+		}
+	}
+
+	return sl
+}
+
+func openAPIOp(ep *registry.Endpoint, path string) *openAPIOperation {
+	op := &openAPIOperation{
+		Summary: ep.Metadata["description"],
+		Responses: map[string]*openAPIBody{
+			"default": {Description: "response"},
+		},
+	}
+
+	// path parameters e.g /users/{id}
+	for _, seg := range strings.Split(path, "/") {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     strings.TrimSuffix(seg[1:len(seg)-1], "..."),
+			In:       "path",
+			Required: true,
+			Schema:   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if ep.Request != nil {
+		op.RequestBody = &openAPIBody{
+			Content: jsonContent(ep.Request),
+		}
+	}
+
+	if ep.Response != nil {
+		op.Responses["default"].Content = jsonContent(ep.Response)
+	}
+
+	return op
+}
+
+func jsonContent(v *registry.Value) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"application/json": {"schema": schema(v)},
+	}
+}
+
+// schema converts a registry value into a JSON schema.
+func schema(v *registry.Value) map[string]interface{} {
+	if len(v.Values) > 0 {
+		props := make(map[string]interface{}, len(v.Values))
+		for _, f := range v.Values {
+			props[f.Name] = schema(f)
+		}
+
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+	}
+
+	if strings.HasPrefix(v.Type, "[]") {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schema(&registry.Value{Type: v.Type[2:]}),
+		}
+	}
+
+	switch v.Type {
+	case "string":
+		return map[string]interface{}{"type": "string"}
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "int", "int8", "int16", "uint", "uint8", "uint16", "uint32", "uint64":
+		return map[string]interface{}{"type": "integer"}
+	case "int32", "int64":
+		return map[string]interface{}{"type": "integer", "format": v.Type}
+	case "float32":
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case "float64":
+		return map[string]interface{}{"type": "number", "format": "double"}
+	}
+
+	// unknown types are left unconstrained
+	return map[string]interface{}{}
+}
+
+// openAPIHandler serves the OpenAPI document at the configured path.
+func (s *service) openAPIHandler(h http.Handler) http.Handler {
+	path := s.opts.OpenAPIPath
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		b, err := s.OpenAPI()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -1,11 +1,18 @@
 package web
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v2"
 	"go-micro.org/v5"
 	"go-micro.org/v5/logger"
@@ -21,6 +28,8 @@ type Options struct {
 	Service micro.Service
 
 	Registry registry.Registry
+	// Registries the service is also registered with
+	Registries []registry.Registry
 
 	// Alternative Options
 	Context context.Context
@@ -31,6 +40,9 @@ type Options struct {
 
 	Server *http.Server
 
+	// Metrics records request count, in-flight requests and latency
+	Metrics Metrics
+
 	// RegisterCheck runs a check function before registering the service
 	RegisterCheck func(context.Context) error
 
@@ -39,25 +51,112 @@ type Options struct {
 	// Static directory
 	StaticDir string
 
+	// StaticDisable turns off static file serving
+	StaticDisable bool
+
+	// StaticIndex is served for unmatched paths with StaticSPAFallback
+	StaticIndex       string
+	StaticSPAFallback bool
+
+	// NotFoundHandler serves static requests matching no file
+	NotFoundHandler http.Handler
+
 	Advertise string
+	// AdvertiseFunc resolves the advertise address at
+	// registration, taking precedence over Advertise
+	AdvertiseFunc func() (string, error)
 
 	Address string
 	Name    string
 	Id      string
-	Flags   []cli.Flag
+	// Hostname the default node id is derived
+	// from, the os hostname if empty
+	Hostname string
+	Flags    []cli.Flag
+
+	// Endpoints advertised to the registry in addition to
+	// those registered via Handle and HandleFunc
+	Endpoints []*registry.Endpoint
+
+	// OpenAPIPath serves the OpenAPI document when set
+	OpenAPIPath string
+
+	// Compress gzips responses when set
+	Compress *CompressOptions
 
 	BeforeStart []func() error
 	BeforeStop  []func() error
 	AfterStart  []func() error
 	AfterStop   []func() error
 
+	// OnStart and OnStop hooks receive the service instance
+	OnStart []func(Service) error
+	OnStop  []func(Service) error
+
+	// Registry lifecycle hooks
+	AfterRegister    []func() error
+	BeforeDeregister []func() error
+
 	RegisterInterval time.Duration
 
 	RegisterTTL time.Duration
 
+	// DrainPeriod to advertise draining before deregistering
+	DrainPeriod time.Duration
+
 	Secure bool
 
+	// Certificates served by SNI host name
+	Certificates map[string]tls.Certificate
+	// CertFile and KeyFile are reloaded when they change
+	CertFile string
+	KeyFile  string
+
+	// ClientCAs verify client certificates when they're required
+	ClientCAs         *x509.CertPool
+	RequireClientCert bool
+
+	// SessionTicketRotation is the interval TLS session
+	// ticket keys are rotated at, disabled if zero
+	SessionTicketRotation time.Duration
+
+	// ListenConfig sets the socket options of the listener
+	ListenConfig *net.ListenConfig
+
+	// ProxyProtocol parses the PROXY protocol header of connections
+	// from the ProxyProtocolTrusted CIDRs or IPs, Start fails if
+	// none are set or one is invalid
+	ProxyProtocol        bool
+	ProxyProtocolTrusted []string
+
 	Signal bool
+
+	// Signals overrides the default set of signals handled by Run
+	Signals []os.Signal
+
+	// OnSignal is called when a signal is received, returning
+	// false prevents the service from shutting down
+	OnSignal func(os.Signal) bool
+
+	// H2C enables cleartext HTTP/2 when TLS is not configured
+	H2C bool
+
+	// ClientCacheTTL caches the nodes resolved by Client, disabled if zero
+	ClientCacheTTL time.Duration
+
+	// PropagateHeaders are copied from incoming requests
+	// to the requests of the Client made in their context
+	PropagateHeaders []string
+
+	// ClientMaxIdleConns, ClientMaxIdleConnsPerHost and
+	// ClientIdleConnTimeout tune the transport of the Client,
+	// the http.DefaultTransport settings are kept if zero
+	ClientMaxIdleConns        int
+	ClientMaxIdleConnsPerHost int
+	ClientIdleConnTimeout     time.Duration
+
+	// ClientConnStats counts the connections of the Client
+	ClientConnStats bool
 }
 
 func newOptions(opts ...Option) Options {
@@ -69,6 +168,7 @@ func newOptions(opts ...Option) Options {
 		RegisterTTL:      DefaultRegisterTTL,
 		RegisterInterval: DefaultRegisterInterval,
 		StaticDir:        DefaultStaticDir,
+		StaticIndex:      DefaultStaticIndex,
 		Service:          micro.NewService(),
 		Context:          context.TODO(),
 		Signal:           true,
@@ -125,6 +225,14 @@ func Metadata(md map[string]string) Option {
 	}
 }
 
+// Hostname sets the host the node id is derived from when no Id is set,
+// e.g. the pod name of a stateful set, rather than the os hostname.
+func Hostname(h string) Option {
+	return func(o *Options) {
+		o.Hostname = h
+	}
+}
+
 // Address to bind to - host:port.
 func Address(a string) Option {
 	return func(o *Options) {
@@ -139,6 +247,24 @@ func Advertise(a string) Option {
 	}
 }
 
+// AdvertiseFunc resolves the address to advertise each time the service
+// registers, e.g. an external address from cloud metadata known only at
+// runtime. It returns host:port, or a host to use with the listener port.
+// An empty address falls back to Advertise or the listener address.
+func AdvertiseFunc(fn func() (string, error)) Option {
+	return func(o *Options) {
+		o.AdvertiseFunc = fn
+	}
+}
+
+// AdvertiseEnv advertises the address held in the environment variable,
+// read each time the service registers.
+func AdvertiseEnv(key string) Option {
+	return AdvertiseFunc(func() (string, error) {
+		return os.Getenv(key), nil
+	})
+}
+
 // Context specifies a context for the service.
 // Can be used to signal shutdown of the service.
 // Can be used for extra option values.
@@ -155,6 +281,14 @@ func Registry(r registry.Registry) Option {
 	}
 }
 
+// Registries registers the service with each of the registries,
+// e.g. to be discoverable across independent registry clusters.
+func Registries(rs ...registry.Registry) Option {
+	return func(o *Options) {
+		o.Registries = append(o.Registries, rs...)
+	}
+}
+
 // RegisterTTL Register the service with a TTL.
 func RegisterTTL(t time.Duration) Option {
 	return func(o *Options) {
@@ -169,13 +303,121 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// DrainPeriod enables connection draining on shutdown. The node is first
+// re-registered with "status" metadata set to "draining", then after the
+// period it's deregistered and the listener closed. Clients should skip
+// nodes with the draining status when selecting.
+func DrainPeriod(d time.Duration) Option {
+	return func(o *Options) {
+		o.DrainPeriod = d
+	}
+}
+
 // Handler for custom handler.
+// Routes served by a custom handler bypass Handle and HandleFunc so they
+// are not advertised to the registry; declare them with Endpoint instead.
 func Handler(h http.Handler) Option {
 	return func(o *Options) {
 		o.Handler = h
 	}
 }
 
+// EndpointOption sets a value on a registry endpoint.
+type EndpointOption func(*registry.Endpoint)
+
+// Endpoint declares an endpoint to advertise to the registry independent
+// of the built in mux. This is required to advertise the routes of a custom
+// router set via Handler.
+func Endpoint(name string, opts ...EndpointOption) Option {
+	return func(o *Options) {
+		ep := &registry.Endpoint{
+			Name: name,
+		}
+
+		for _, opt := range opts {
+			opt(ep)
+		}
+
+		o.Endpoints = append(o.Endpoints, ep)
+	}
+}
+
+// EndpointMetadata sets a metadata key/value on the endpoint.
+func EndpointMetadata(key, val string) EndpointOption {
+	return func(ep *registry.Endpoint) {
+		if ep.Metadata == nil {
+			ep.Metadata = make(map[string]string)
+		}
+
+		ep.Metadata[key] = val
+	}
+}
+
+// EndpointRequest sets the request value the endpoint's
+// OpenAPI request schema is generated from.
+func EndpointRequest(v *registry.Value) EndpointOption {
+	return func(ep *registry.Endpoint) {
+		ep.Request = v
+	}
+}
+
+// EndpointResponse sets the response value the endpoint's
+// OpenAPI response schema is generated from.
+func EndpointResponse(v *registry.Value) EndpointOption {
+	return func(ep *registry.Endpoint) {
+		ep.Response = v
+	}
+}
+
+// OpenAPIPath serves the OpenAPI document of the service at the path,
+// e.g. DefaultOpenAPIPath. The document is not served by default.
+func OpenAPIPath(path string) Option {
+	return func(o *Options) {
+		o.OpenAPIPath = path
+	}
+}
+
+// Compress gzips responses for clients sending Accept-Encoding. Bodies
+// smaller than DefaultCompressMinSize and already compressed content
+// types such as images are sent as is.
+func Compress(opts ...CompressOption) Option {
+	return func(o *Options) {
+		c := &CompressOptions{
+			MinSize: DefaultCompressMinSize,
+			Level:   gzip.DefaultCompression,
+		}
+
+		for _, opt := range opts {
+			opt(c)
+		}
+
+		o.Compress = c
+	}
+}
+
+// RequestMetrics installs middleware recording request metrics labeled
+// by method and registered route pattern.
+func RequestMetrics(m Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// PrometheusMetrics installs the request metrics of NewPrometheusMetrics,
+// registering them with the registerer. If they fail to register the
+// error is logged and the metrics aren't installed.
+func PrometheusMetrics(reg prometheus.Registerer) Option {
+	return func(o *Options) {
+		m, err := NewPrometheusMetrics(reg)
+		if err != nil {
+			logger.LoggerOrDefault(o.Logger).Logf(logger.ErrorLevel, "web: failed to register metrics: %v", err)
+			return
+		}
+
+		o.Metrics = m
+	}
+}
+
 // Server for custom Server.
 func Server(srv *http.Server) Option {
 	return func(o *Options) {
@@ -232,6 +474,38 @@ func AfterStop(fn func() error) Option {
 	}
 }
 
+// OnStart is executed once the server has started with the service
+// instance, e.g to read the resolved address.
+func OnStart(fn func(Service) error) Option {
+	return func(o *Options) {
+		o.OnStart = append(o.OnStart, fn)
+	}
+}
+
+// OnStop is executed with the service instance before the server stops.
+func OnStop(fn func(Service) error) Option {
+	return func(o *Options) {
+		o.OnStop = append(o.OnStop, fn)
+	}
+}
+
+// AfterRegister is executed after the service registers successfully,
+// including re-registrations. Errors are logged and don't fail the
+// registration.
+func AfterRegister(fn func() error) Option {
+	return func(o *Options) {
+		o.AfterRegister = append(o.AfterRegister, fn)
+	}
+}
+
+// BeforeDeregister is executed before the service deregisters.
+// An error aborts the deregistration.
+func BeforeDeregister(fn func() error) Option {
+	return func(o *Options) {
+		o.BeforeDeregister = append(o.BeforeDeregister, fn)
+	}
+}
+
 // Secure Use secure communication.
 // If TLSConfig is not specified we use InsecureSkipVerify and generate a self signed cert.
 func Secure(b bool) Option {
@@ -240,6 +514,14 @@ func Secure(b bool) Option {
 	}
 }
 
+// H2C enables HTTP/2 over cleartext TCP (h2c) when the service is not secure.
+// With TLS configured HTTP/2 is negotiated via ALPN as normal.
+func H2C() Option {
+	return func(o *Options) {
+		o.H2C = true
+	}
+}
+
 // TLSConfig to be used for the transport.
 func TLSConfig(t *tls.Config) Option {
 	return func(o *Options) {
@@ -247,13 +529,124 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// Certificates serves a certificate per host name, selected by the SNI
+// server name of the client. Clients without a matching name are served
+// the certificate of the first host in sorted order. The listener is secure.
+func Certificates(certs map[string]tls.Certificate) Option {
+	return func(o *Options) {
+		if o.Certificates == nil {
+			o.Certificates = make(map[string]tls.Certificate, len(certs))
+		}
+
+		for host, cert := range certs {
+			o.Certificates[strings.ToLower(host)] = cert
+		}
+	}
+}
+
+// CertFiles serves the certificate of the PEM files, reloading it for new
+// connections when the files change, e.g. when cert-manager renews it.
+// Existing connections keep their certificate. Certificates matching the
+// SNI host name take precedence. The listener is secure.
+func CertFiles(certFile, keyFile string) Option {
+	return func(o *Options) {
+		o.CertFile = certFile
+		o.KeyFile = keyFile
+	}
+}
+
+// RequireClientCert requires and verifies client certificates (mTLS),
+// overriding the ClientAuth of any TLSConfig. The listener is secure.
+// The certificates are verified against ClientCAs, or the ClientCAs of
+// the TLSConfig, and Start fails if neither is set.
+func RequireClientCert() Option {
+	return func(o *Options) {
+		o.RequireClientCert = true
+	}
+}
+
+// ClientCAs requires client certificates signed by the pool (mTLS).
+func ClientCAs(pool *x509.CertPool) Option {
+	return func(o *Options) {
+		o.ClientCAs = pool
+		o.RequireClientCert = true
+	}
+}
+
+// SessionTicketRotation rotates the TLS session ticket keys at the
+// interval for forward secrecy, keeping the previous key to resume
+// sessions. A TLSConfig with a SessionTicketKey set or session tickets
+// disabled is left as is.
+func SessionTicketRotation(interval time.Duration) Option {
+	return func(o *Options) {
+		o.SessionTicketRotation = interval
+	}
+}
+
+// ListenConfig sets the config the listener is created with, e.g. to set
+// SO_REUSEPORT from its Control func. The service context is passed on.
+func ListenConfig(lc *net.ListenConfig) Option {
+	return func(o *Options) {
+		o.ListenConfig = lc
+	}
+}
+
+// ProxyProtocol accepts connections beginning with a PROXY protocol v1 or
+// v2 header, e.g. from an AWS NLB, and reports the client address it
+// carries as the RemoteAddr of the requests. The header precedes the TLS
+// handshake of secure listeners. Headers are only parsed on connections
+// from the trusted CIDRs or IPs, e.g. the subnets of the load balancer,
+// as they let a client claim any address; 0.0.0.0/0 and ::/0 trust all.
+// Malformed headers close the connection. Connections without a header
+// are served with the address of the connection. Start fails if no CIDR
+// is given or one is invalid.
+func ProxyProtocol(trusted ...string) Option {
+	return func(o *Options) {
+		o.ProxyProtocol = true
+		o.ProxyProtocolTrusted = append(o.ProxyProtocolTrusted, trusted...)
+	}
+}
+
 // StaticDir sets the static file directory. This defaults to ./html.
+// An empty directory disables static file serving.
 func StaticDir(d string) Option {
 	return func(o *Options) {
 		o.StaticDir = d
 	}
 }
 
+// StaticDisable disables static file serving regardless of
+// whether the static directory exists.
+func StaticDisable() Option {
+	return func(o *Options) {
+		o.StaticDisable = true
+	}
+}
+
+// StaticIndex sets the index file served by the SPA fallback.
+// This defaults to index.html.
+func StaticIndex(file string) Option {
+	return func(o *Options) {
+		o.StaticIndex = file
+	}
+}
+
+// StaticSPAFallback serves the index file with a 200 for requests matching
+// no static file, for single page apps with client side routing.
+func StaticSPAFallback(b bool) Option {
+	return func(o *Options) {
+		o.StaticSPAFallback = b
+	}
+}
+
+// NotFoundHandler handles static requests matching no file in
+// place of the default plain 404.
+func NotFoundHandler(h http.Handler) Option {
+	return func(o *Options) {
+		o.NotFoundHandler = h
+	}
+}
+
 // RegisterCheck run func before registry service.
 func RegisterCheck(fn func(context.Context) error) Option {
 	return func(o *Options) {
@@ -261,6 +654,37 @@ func RegisterCheck(fn func(context.Context) error) Option {
 	}
 }
 
+// RegisterCheckAll runs each check before registering the service, e.g.
+// probing the database, cache and downstream services. The node is kept out
+// of the registry until all pass. The checks can get the service options
+// with OptionsFromContext.
+func RegisterCheckAll(fns ...func(context.Context) error) Option {
+	return RegisterCheck(func(ctx context.Context) error {
+		var errs []string
+
+		for _, fn := range fns {
+			if err := fn(ctx); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d checks failed: %s", len(errs), len(fns), strings.Join(errs, "; "))
+		}
+
+		return nil
+	})
+}
+
+type optionsKey struct{}
+
+// OptionsFromContext returns the service options from the
+// context passed to the register check.
+func OptionsFromContext(ctx context.Context) (Options, bool) {
+	o, ok := ctx.Value(optionsKey{}).(Options)
+	return o, ok
+}
+
 // HandleSignal toggles automatic installation of the signal handler that
 // traps TERM, INT, and QUIT.  Users of this feature to disable the signal
 // handler, should control liveness of the service through the context.
@@ -270,9 +694,82 @@ func HandleSignal(b bool) Option {
 	}
 }
 
+// Signals overrides the set of signals Run listens on, which
+// defaults to TERM, INT, and QUIT.
+func Signals(sig ...os.Signal) Option {
+	return func(o *Options) {
+		o.Signals = sig
+	}
+}
+
+// OnSignal sets a callback invoked when a signal is received. Returning
+// false means the service doesn't shut down, e.g to reload config on HUP.
+func OnSignal(fn func(os.Signal) bool) Option {
+	return func(o *Options) {
+		o.OnSignal = fn
+	}
+}
+
 // Logger sets the underline logger.
 func Logger(l logger.Logger) Option {
 	return func(o *Options) {
 		o.Logger = l
 	}
 }
+
+// ClientRegistryCache caches the nodes the http client returned by Client
+// resolves for the ttl. Cached services are watched for changes and their
+// nodes are dropped when a request to all of them fails.
+func ClientRegistryCache(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ClientCacheTTL = ttl
+	}
+}
+
+// ClientPropagateHeaders copies the headers, e.g. Authorization, from the
+// requests the service handles to the requests the http client returned
+// by Client makes with their context:
+//
+//	req, _ := http.NewRequestWithContext(r.Context(), "GET", "http://backend/", nil)
+//	rsp, err := service.Client().Do(req)
+//
+// The headers are stored as the go-micro metadata of the request context.
+func ClientPropagateHeaders(headers ...string) Option {
+	return func(o *Options) {
+		o.PropagateHeaders = append(o.PropagateHeaders, headers...)
+	}
+}
+
+// ClientMaxIdleConns limits the idle connections the http client returned
+// by Client keeps to all nodes.
+func ClientMaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.ClientMaxIdleConns = n
+	}
+}
+
+// ClientMaxIdleConnsPerHost limits the idle connections the http client
+// returned by Client keeps to each node.
+func ClientMaxIdleConnsPerHost(n int) Option {
+	return func(o *Options) {
+		o.ClientMaxIdleConnsPerHost = n
+	}
+}
+
+// ClientIdleConnTimeout closes the connections of the http client returned
+// by Client once idle for the duration, so those to nodes which went away,
+// e.g. when a deployment is scaled down, don't accumulate.
+func ClientIdleConnTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ClientIdleConnTimeout = d
+	}
+}
+
+// ClientConnStats counts the active and idle connections and the requests
+// waiting for one of the http client returned by Client by node, reported
+// by the service's ConnStats. Disabled by default to avoid the overhead.
+func ClientConnStats(b bool) Option {
+	return func(o *Options) {
+		o.ClientConnStats = b
+	}
+}
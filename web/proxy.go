@@ -0,0 +1,257 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultProxyHeaderTimeout is how long a connection
+	// may take to send its PROXY protocol header.
+	DefaultProxyHeaderTimeout = 10 * time.Second
+
+	// the signatures of the PROXY protocol headers
+	proxyV1Sig = []byte("PROXY ")
+	proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// parseTrusted parses the trusted CIDRs or IPs of ProxyProtocol.
+func parseTrusted(trusted []string) ([]*net.IPNet, error) {
+	if len(trusted) == 0 {
+		return nil, errors.New("ProxyProtocol requires a trusted CIDR")
+	}
+
+	nets := make([]*net.IPNet, 0, len(trusted))
+
+	for _, t := range trusted {
+		cidr := t
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", t, err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// proxyListener accepts connections which may begin with a PROXY
+// protocol header, see https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+type proxyListener struct {
+	net.Listener
+	// trusted are the networks whose headers are parsed
+	trusted []*net.IPNet
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.trust(c.RemoteAddr()) {
+		return c, nil
+	}
+
+	// the header is read by the connection's goroutine
+	// so a slow client doesn't block the accept loop
+	return &proxyConn{Conn: c, br: bufio.NewReader(c)}, nil
+}
+
+func (l *proxyListener) trust(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, n := range l.trusted {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyConn reads the PROXY protocol header, if any, on first use.
+type proxyConn struct {
+	net.Conn
+
+	br   *bufio.Reader
+	once sync.Once
+	err  error
+	// remote is the client address of the header
+	remote net.Addr
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(DefaultProxyHeaderTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		c.remote, c.err = readProxyHeader(c.br)
+		if c.err != nil {
+			// a malformed header from a trusted source
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.br.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a version 1 or 2 header, returning the client
+// address or nil if there's no header or it carries no address.
+func readProxyHeader(br *bufio.Reader) (net.Addr, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		// let the reads fail
+		return nil, nil
+	}
+
+	var sig []byte
+
+	switch b[0] {
+	case proxyV1Sig[0]:
+		sig = proxyV1Sig
+	case proxyV2Sig[0]:
+		sig = proxyV2Sig
+	default:
+		return nil, nil
+	}
+
+	if b, err := br.Peek(len(sig)); err != nil || !bytes.Equal(b, sig) {
+		return nil, nil
+	}
+
+	if sig[0] == proxyV1Sig[0] {
+		return readProxyV1(br)
+	}
+
+	return readProxyV2(br)
+}
+
+// readProxyV1 reads a header such as
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readProxyV1(br *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	// the header is at most 107 bytes
+	for len(line) < 107 {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxy protocol: header too long")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("proxy protocol: malformed header %q", line)
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("proxy protocol: invalid source address %q", fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid source port %q", fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 reads a binary header, skipping its TLVs.
+func readProxyV2(br *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, err
+	}
+
+	if hdr[12]>>4 != 2 {
+		return nil, fmt.Errorf("proxy protocol: unsupported version %d", hdr[12]>>4)
+	}
+
+	cmd := hdr[12] & 0x0f
+	if cmd > 1 {
+		return nil, fmt.Errorf("proxy protocol: unsupported command %d", cmd)
+	}
+
+	data := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+
+	// a LOCAL connection e.g. a health check of the balancer
+	if cmd == 0 {
+		return nil, nil
+	}
+
+	switch hdr[13] >> 4 {
+	case 1: // AF_INET
+		if len(data) < 12 {
+			return nil, errors.New("proxy protocol: short ipv4 addresses")
+		}
+
+		return &net.TCPAddr{IP: net.IP(data[:4]), Port: int(binary.BigEndian.Uint16(data[8:]))}, nil
+	case 2: // AF_INET6
+		if len(data) < 36 {
+			return nil, errors.New("proxy protocol: short ipv6 addresses")
+		}
+
+		return &net.TCPAddr{IP: net.IP(data[:16]), Port: int(binary.BigEndian.Uint16(data[32:]))}, nil
+	}
+
+	// unix sockets and unspecified families keep the connection address
+	return nil, nil
+}
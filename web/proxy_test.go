@@ -0,0 +1,146 @@
+package web
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"go-micro.org/v5/registry"
+)
+
+// proxyV2Header returns a v2 PROXY header of the ipv4 client.
+func proxyV2Header(ip net.IP, port uint16) []byte {
+	b := append([]byte(nil), proxyV2Sig...)
+	b = append(b, 0x21, 0x11, 0, 12)
+	b = append(b, ip.To4()...)
+	b = append(b, 127, 0, 0, 1)
+	b = binary.BigEndian.AppendUint16(b, port)
+	b = binary.BigEndian.AppendUint16(b, 443)
+
+	return b
+}
+
+func TestProxyProtocolTrusted(t *testing.T) {
+	// a source must be trusted and valid
+	for _, trusted := range [][]string{nil, {"10.0.0.0/33"}, {"not an ip"}} {
+		if _, err := parseTrusted(trusted); err == nil {
+			t.Fatalf("%v: expected an error", trusted)
+		}
+	}
+
+	nets, err := parseTrusted([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nets) != 3 {
+		t.Fatalf("Expected 3 trusted networks got %v", nets)
+	}
+
+	// and the service doesn't start
+	s := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		ProxyProtocol("10.0.0.0/33"),
+	)
+
+	if err := s.Start(); err == nil {
+		s.Stop()
+		t.Fatal("Expected an invalid trusted proxy to fail the start")
+	}
+
+	// none are trusted if set without any
+	l := &proxyListener{}
+	if l.trust(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}) {
+		t.Fatal("Expected no source to be trusted")
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	testData := []struct {
+		opts   []Option
+		header []byte
+		secure bool
+		// the remote address, empty if the request fails
+		want string
+	}{
+		{nil, []byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n"), false, "192.0.2.1:56324"},
+		{nil, []byte("PROXY TCP6 2001:db8::1 ::1 56324 443\r\n"), false, "[2001:db8::1]:56324"},
+		{nil, proxyV2Header(net.ParseIP("192.0.2.2"), 1234), false, "192.0.2.2:1234"},
+		{nil, proxyV2Header(net.ParseIP("192.0.2.2"), 1234), true, "192.0.2.2:1234"},
+		// the header is optional and may carry no address
+		{nil, nil, false, "127.0.0.1"},
+		{nil, []byte("PROXY UNKNOWN\r\n"), false, "127.0.0.1"},
+		// malformed headers close the connection
+		{nil, []byte("PROXY TCP4 bad 127.0.0.1 56324 443\r\n"), false, ""},
+		// the headers of untrusted sources are not parsed
+		{[]Option{ProxyProtocol("10.0.0.0/8")}, []byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n"), false, ""},
+		{[]Option{ProxyProtocol("127.0.0.1")}, []byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n"), false, "192.0.2.1:56324"},
+		{[]Option{ProxyProtocol("0.0.0.0/0")}, []byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n"), false, "192.0.2.1:56324"},
+	}
+
+	for i, d := range testData {
+		if d.opts == nil {
+			d.opts = []Option{ProxyProtocol("127.0.0.0/8")}
+		}
+
+		opts := append([]Option{
+			Name("go.micro.web.test"),
+			Address("127.0.0.1:0"),
+			Registry(registry.NewMemoryRegistry()),
+			Secure(d.secure),
+		}, d.opts...)
+
+		s := NewService(opts...)
+		s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.RemoteAddr)
+		})
+
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("tcp", s.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Write(d.header)
+
+		// the header precedes the handshake
+		if d.secure {
+			conn = tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		}
+
+		req, _ := http.NewRequest("GET", "http://"+s.Address()+"/", nil)
+		req.Write(conn)
+
+		var got string
+
+		rsp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err == nil {
+			b, _ := io.ReadAll(rsp.Body)
+			rsp.Body.Close()
+
+			if rsp.StatusCode == http.StatusOK {
+				got = string(b)
+			}
+		}
+
+		conn.Close()
+		s.Stop()
+
+		if host, _, err := net.SplitHostPort(got); err == nil && d.want == host {
+			got = host
+		}
+
+		if got != d.want {
+			t.Fatalf("%d: expected remote address %q got %q", i, d.want, got)
+		}
+	}
+}
@@ -1,29 +1,43 @@
 package web
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/urfave/cli/v2"
 	"go-micro.org/v5"
 	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/metadata"
 	"go-micro.org/v5/registry"
+	"go-micro.org/v5/registry/cache"
 	maddr "go-micro.org/v5/util/addr"
 	"go-micro.org/v5/util/backoff"
 	mhttp "go-micro.org/v5/util/http"
 	mnet "go-micro.org/v5/util/net"
 	signalutil "go-micro.org/v5/util/signal"
 	mls "go-micro.org/v5/util/tls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// ErrNoRegistry is returned by Start and Run when neither the options
+// nor the client of the micro service set a registry.
+var ErrNoRegistry = errors.New("no registry configured")
+
 type service struct {
 	mux *http.ServeMux
 	srv *registry.Service
@@ -32,9 +46,33 @@ type service struct {
 	ex   chan bool
 	opts Options
 
+	// addr is the last registered node address
+	addr string
+
 	sync.RWMutex
 	running bool
 	static  bool
+	// subscribers consume broker messages while running
+	subscribers []*subscriber
+	// tickets is the listener config whose
+	// session ticket keys are rotated
+	tickets *tls.Config
+	// methods are the handlers of HandleMethod by pattern
+	methods map[string]*methodHandler
+	// certs reloads the CertFiles, nil if not set
+	certs *certReloader
+	// cache of the nodes resolved by Client
+	cache cache.Cache
+	// transport of the Client, nil if not tuned
+	transport *http.Transport
+	// conns of the transport, nil if not counted
+	conns *connStats
+	// httpSrv serves the listener
+	httpSrv *http.Server
+	// exOnce stops the register loop once
+	exOnce sync.Once
+	// drained is set once the service is deregistered
+	drained bool
 }
 
 func newService(opts ...Option) Service {
@@ -45,12 +83,22 @@ func newService(opts ...Option) Service {
 		static: true,
 		ex:     make(chan bool),
 	}
-	s.srv = s.genSrv()
+
+	srv, err := s.genSrv()
+	if err != nil {
+		options.Logger.Log(log.FatalLevel, err)
+	}
+
+	s.srv = srv
+
+	for _, ep := range options.Endpoints {
+		s.addEndpoint(ep)
+	}
 
 	return s
 }
 
-func (s *service) genSrv() *registry.Service {
+func (s *service) genSrv() (*registry.Service, error) {
 	var (
 		host string
 		port string
@@ -63,7 +111,7 @@ func (s *service) genSrv() *registry.Service {
 	if len(s.opts.Address) > 0 {
 		host, port, err = net.SplitHostPort(s.opts.Address)
 		if err != nil {
-			logger.Log(log.FatalLevel, err)
+			return nil, err
 		}
 	}
 
@@ -73,13 +121,26 @@ func (s *service) genSrv() *registry.Service {
 	if len(s.opts.Advertise) > 0 {
 		host, port, err = net.SplitHostPort(s.opts.Advertise)
 		if err != nil {
-			logger.Log(log.FatalLevel, err)
+			return nil, err
+		}
+	}
+
+	// the runtime advertise address takes precedence
+	if s.opts.AdvertiseFunc != nil {
+		if adv, err := s.opts.AdvertiseFunc(); err != nil {
+			logger.Logf(log.ErrorLevel, "Server %s-%s advertise address error: %s", s.opts.Name, s.opts.Id, err)
+		} else if len(adv) > 0 {
+			host = adv
+
+			if h, p, err := net.SplitHostPort(adv); err == nil {
+				host, port = h, p
+			}
 		}
 	}
 
 	addr, err := maddr.Extract(host)
 	if err != nil {
-		logger.Log(log.FatalLevel, err)
+		return nil, err
 	}
 
 	if strings.Count(addr, ":") > 0 {
@@ -90,11 +151,39 @@ func (s *service) genSrv() *registry.Service {
 		Name:    s.opts.Name,
 		Version: s.opts.Version,
 		Nodes: []*registry.Node{{
-			Id:       s.opts.Id,
+			Id:       s.nodeId(port),
 			Address:  net.JoinHostPort(addr, port),
 			Metadata: s.opts.Metadata,
 		}},
+	}, nil
+}
+
+// nodeId returns the configured id, or one derived from the name, the
+// hostname and the port so the node registers with the same id across
+// restarts.
+func (s *service) nodeId(port string) string {
+	if len(s.opts.Id) > 0 {
+		return s.opts.Id
+	}
+
+	host := s.opts.Hostname
+	if len(host) == 0 {
+		host, _ = os.Hostname()
 	}
+
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(s.opts.Name+"@"+net.JoinHostPort(host, port))).String()
+}
+
+// addEndpoint adds the endpoint to the service if it's unseen.
+// The caller must hold the lock.
+func (s *service) addEndpoint(ep *registry.Endpoint) {
+	for _, e := range s.srv.Endpoints {
+		if e.Name == ep.Name {
+			return
+		}
+	}
+
+	s.srv.Endpoints = append(s.srv.Endpoints, ep)
 }
 
 func (s *service) run() {
@@ -110,7 +199,9 @@ func (s *service) run() {
 	for {
 		select {
 		case <-t.C:
-			s.register()
+			if err := s.register(nil); err != nil {
+				s.opts.Logger.Logf(log.ErrorLevel, "Server %s-%s register error: %s", s.opts.Name, s.opts.Id, err)
+			}
 		case <-s.ex:
 			t.Stop()
 			return
@@ -118,71 +209,268 @@ func (s *service) run() {
 	}
 }
 
-func (s *service) register() error {
+// register registers the service node. The metadata overrides are applied
+// to this registration only, e.g to advertise a transient status. The
+// AfterRegister hooks run without the lock so they may use the service.
+func (s *service) register(md map[string]string) error {
 	s.Lock()
-	defer s.Unlock()
+	hooks, err := s.registerNode(md)
+	logger, name, id := s.opts.Logger, s.opts.Name, s.opts.Id
+	s.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range hooks {
+		if err := fn(); err != nil {
+			logger.Logf(log.ErrorLevel, "Server %s-%s after register error: %s", name, id, err)
+		}
+	}
 
+	return nil
+}
+
+// registerNode registers the service node, returning the AfterRegister
+// hooks to run if it registered. The caller must hold the lock.
+func (s *service) registerNode(md map[string]string) ([]func() error, error) {
 	if s.srv == nil {
-		return nil
+		return nil, nil
 	}
 
 	logger := s.opts.Logger
 
-	// default to service registry
-	r := s.opts.Service.Client().Options().Registry
-	// switch to option if specified
-	if s.opts.Registry != nil {
-		r = s.opts.Registry
+	registries := s.registries()
+	if len(registries) == 0 {
+		return nil, ErrNoRegistry
 	}
 
 	// service node need modify, node address maybe changed
-	srv := s.genSrv()
+	srv, err := s.genSrv()
+	if err != nil {
+		logger.Logf(log.ErrorLevel, "Server %s-%s address error: %s", s.opts.Name, s.opts.Id, err)
+		return nil, err
+	}
+
 	srv.Endpoints = s.srv.Endpoints
 	s.srv = srv
 
+	if len(md) > 0 {
+		node := srv.Nodes[0]
+		meta := make(map[string]string, len(node.Metadata)+len(md))
+
+		for k, v := range node.Metadata {
+			meta[k] = v
+		}
+
+		for k, v := range md {
+			meta[k] = v
+		}
+
+		node.Metadata = meta
+	}
+
 	// use RegisterCheck func before register
-	if err := s.opts.RegisterCheck(s.opts.Context); err != nil {
+	if err := s.opts.RegisterCheck(context.WithValue(s.opts.Context, optionsKey{}, s.opts)); err != nil {
 		logger.Logf(log.ErrorLevel, "Server %s-%s register check error: %s", s.opts.Name, s.opts.Id, err)
-		return err
+		return nil, err
+	}
+
+	// deregister the stale node if the address changed
+	if node := s.srv.Nodes[0]; len(s.addr) > 0 && s.addr != node.Address {
+		stale := &registry.Service{
+			Name:    s.srv.Name,
+			Version: s.srv.Version,
+			Nodes: []*registry.Node{{
+				Id:       node.Id,
+				Address:  s.addr,
+				Metadata: node.Metadata,
+			}},
+		}
+
+		for _, r := range registries {
+			if err := r.Deregister(stale); err != nil {
+				logger.Logf(log.ErrorLevel, "Server %s-%s deregister stale node %s error: %s", s.opts.Name, s.opts.Id, s.addr, err)
+			}
+		}
 	}
 
-	var regErr error
+	var errs []string
+
+	for _, r := range registries {
+		var regErr error
+
+		// try three times if necessary
+		for i := 0; i < 3; i++ {
+			// attempt to register
+			if err := r.Register(s.srv, registry.RegisterTTL(s.opts.RegisterTTL)); err != nil {
+				// set the error
+				regErr = err
+				// backoff then retry
+				time.Sleep(backoff.Do(i + 1))
+
+				continue
+			}
+			// success so nil error
+			regErr = nil
 
-	// try three times if necessary
-	for i := 0; i < 3; i++ {
-		// attempt to register
-		if err := r.Register(s.srv, registry.RegisterTTL(s.opts.RegisterTTL)); err != nil {
-			// set the error
-			regErr = err
-			// backoff then retry
-			time.Sleep(backoff.Do(i + 1))
+			break
+		}
 
+		if regErr != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.String(), regErr))
 			continue
 		}
-		// success so nil error
-		regErr = nil
 
-		break
+		s.addr = s.srv.Nodes[0].Address
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("register error: %s", strings.Join(errs, "; "))
+	}
+
+	// transient registrations such as draining aren't announced
+	if len(md) > 0 {
+		return nil, nil
+	}
+
+	return s.opts.AfterRegister, nil
+}
+
+// registries returns the registries to register with, the service
+// registry unless any are specified. Nil registries are skipped so
+// none are returned if there's no registry at all.
+func (s *service) registries() []registry.Registry {
+	var rs []registry.Registry
+
+	// switch to option if specified
+	if s.opts.Registry != nil {
+		rs = append(rs, s.opts.Registry)
+	}
+
+	for _, r := range s.opts.Registries {
+		if r != nil {
+			rs = append(rs, r)
+		}
+	}
+
+	if len(rs) > 0 || s.opts.Service == nil {
+		return rs
 	}
 
-	return regErr
+	// default to service registry
+	if c := s.opts.Service.Client(); c != nil && c.Options().Registry != nil {
+		rs = append(rs, c.Options().Registry)
+	}
+
+	return rs
 }
 
+// drain advertises the node as draining and waits for the drain
+// period so clients stop selecting it before it's deregistered.
+func (s *service) drain() {
+	s.RLock()
+	period := s.opts.DrainPeriod
+	s.RUnlock()
+
+	if period <= 0 {
+		return
+	}
+
+	if err := s.register(map[string]string{"status": "draining"}); err != nil {
+		s.opts.Logger.Logf(log.ErrorLevel, "Server %s-%s drain register error: %s", s.opts.Name, s.opts.Id, err)
+	}
+
+	time.Sleep(period)
+}
+
+// leave stops the register loop, advertises draining and deregisters
+// the service. It's a no-op once the service has been drained.
+func (s *service) leave() error {
+	// exit reg loop
+	s.exOnce.Do(func() { close(s.ex) })
+
+	s.RLock()
+	drained := s.drained
+	s.RUnlock()
+
+	if drained {
+		return nil
+	}
+
+	// advertise draining before deregistering
+	s.drain()
+
+	if err := s.deregister(); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.drained = true
+	s.Unlock()
+
+	return nil
+}
+
+// Drain deregisters the service and stops accepting connections, waiting
+// for in-flight requests to finish or the context to be done. Unlike Stop
+// the service isn't torn down, Stop is still called to complete shutdown.
+func (s *service) Drain(ctx context.Context) error {
+	if err := s.leave(); err != nil {
+		return err
+	}
+
+	s.RLock()
+	srv := s.httpSrv
+	s.RUnlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Shutdown(ctx)
+}
+
+// deregister runs the BeforeDeregister hooks, without the lock so they
+// may use the service, and deregisters the service node.
 func (s *service) deregister() error {
+	s.RLock()
+	registered := s.srv != nil
+	hooks := s.opts.BeforeDeregister
+	s.RUnlock()
+
+	if !registered {
+		return nil
+	}
+
+	for _, fn := range hooks {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
 	if s.srv == nil {
 		return nil
 	}
-	// default to service registry
-	r := s.opts.Service.Client().Options().Registry
-	// switch to option if specified
-	if s.opts.Registry != nil {
-		r = s.opts.Registry
+
+	s.addr = ""
+
+	var errs []string
+
+	for _, r := range s.registries() {
+		if err := r.Deregister(s.srv); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.String(), err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("deregister error: %s", strings.Join(errs, "; "))
 	}
 
-	return r.Deregister(s.srv)
+	return nil
 }
 
 func (s *service) start() error {
@@ -207,7 +495,13 @@ func (s *service) start() error {
 	logger := s.opts.Logger
 
 	s.opts.Address = listener.Addr().String()
-	srv := s.genSrv()
+
+	srv, err := s.genSrv()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
 	srv.Endpoints = s.srv.Endpoints
 	s.srv = srv
 
@@ -219,21 +513,26 @@ func (s *service) start() error {
 		handler = s.mux
 		var r sync.Once
 
+		// static serving disabled explicitly
+		if s.opts.StaticDisable || len(s.opts.StaticDir) == 0 {
+			s.static = false
+		}
+
 		// register the html dir
 		r.Do(func() {
-			// static dir
-			static := s.opts.StaticDir
-			if s.opts.StaticDir[0] != '/' {
-				dir, _ := os.Getwd()
-				static = filepath.Join(dir, static)
-			}
-
 			// set static if no / handler is registered
 			if s.static {
+				// static dir
+				static := s.opts.StaticDir
+				if s.opts.StaticDir[0] != '/' {
+					dir, _ := os.Getwd()
+					static = filepath.Join(dir, static)
+				}
+
 				_, err := os.Stat(static)
 				if err == nil {
 					logger.Logf(log.InfoLevel, "Enabling static file serving from %s", static)
-					s.mux.Handle("/", http.FileServer(http.Dir(static)))
+					s.mux.Handle("/", s.staticHandler(static))
 				}
 			}
 		})
@@ -246,22 +545,78 @@ func (s *service) start() error {
 		httpSrv = &http.Server{}
 	}
 
+	if len(s.opts.OpenAPIPath) > 0 {
+		handler = s.openAPIHandler(handler)
+	}
+
+	if len(s.opts.PropagateHeaders) > 0 {
+		handler = s.propagateHandler(handler)
+	}
+
+	if s.opts.Compress != nil {
+		handler = s.compressHandler(handler)
+	}
+
+	if s.opts.Metrics != nil {
+		handler = s.metricsHandler(handler)
+	}
+
+	if s.opts.H2C {
+		h2s := &http2.Server{}
+
+		// insecure connection use h2c
+		if !s.secure() {
+			handler = h2c.NewHandler(handler, h2s)
+		}
+
+		if err := http2.ConfigureServer(httpSrv, h2s); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
 	httpSrv.Handler = handler
+	s.httpSrv = httpSrv
 
 	go httpSrv.Serve(listener)
 
 	for _, fn := range s.opts.AfterStart {
 		if err := fn(); err != nil {
+			listener.Close()
 			return err
 		}
 	}
 
+	// start consuming broker messages
+	if err := s.subscribe(); err != nil {
+		listener.Close()
+		return err
+	}
+
 	s.exit = make(chan chan error, 1)
 	s.running = true
 
+	done := make(chan bool)
+
+	if s.tickets != nil {
+		go rotateTickets(s.tickets, s.opts.SessionTicketRotation, done)
+	}
+
+	if s.certs != nil {
+		go s.certs.watch(done)
+	}
+
 	go func() {
 		ch := <-s.exit
-		ch <- listener.Close()
+		close(done)
+
+		// the listener is already closed if the service was drained
+		err := listener.Close()
+		if errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+
+		ch <- err
 	}()
 
 	logger.Logf(log.InfoLevel, "Listening on %v", listener.Addr().String())
@@ -283,11 +638,7 @@ func (s *service) stop() error {
 		}
 	}
 
-	ch := make(chan error, 1)
-	s.exit <- ch
-	s.running = false
-
-	s.opts.Logger.Log(log.InfoLevel, "Stopping")
+	ch := s.halt()
 
 	for _, fn := range s.opts.AfterStop {
 		if err := fn(); err != nil {
@@ -302,15 +653,162 @@ func (s *service) stop() error {
 	return <-ch
 }
 
+// halt closes the listener and stops the subscribers and clients, the
+// returned channel receives the error of closing the listener. The
+// caller must hold the lock.
+func (s *service) halt() chan error {
+	ch := make(chan error, 1)
+	s.exit <- ch
+	s.running = false
+
+	s.unsubscribe()
+
+	s.opts.Logger.Log(log.InfoLevel, "Stopping")
+
+	// stop watching the services of the clients
+	if s.cache != nil {
+		s.cache.Stop()
+		s.cache = nil
+	}
+
+	if s.transport != nil {
+		s.transport.CloseIdleConnections()
+	}
+
+	return ch
+}
+
+// abort stops a service whose start failed after it began listening,
+// without the stop hooks as it didn't start.
+func (s *service) abort() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	<-s.halt()
+}
+
+// Address returns the address the service is listening on. Once the
+// service has started this is the resolved listener address.
+func (s *service) Address() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.opts.Address
+}
+
+func (s *service) ReloadTLS() error {
+	s.RLock()
+	certs := s.certs
+	s.RUnlock()
+
+	if certs == nil {
+		return errors.New("no certificate files configured")
+	}
+
+	_, err := certs.load()
+
+	return err
+}
+
+func (s *service) Id() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.srv.Nodes[0].Id
+}
+
 func (s *service) Client() *http.Client {
-	rt := mhttp.NewRoundTripper(
-		mhttp.WithRegistry(s.opts.Registry),
-	)
+	opts := []mhttp.Option{
+		mhttp.WithRegistry(s.clientRegistry()),
+		mhttp.WithHeaders(s.opts.PropagateHeaders...),
+	}
+
+	if t := s.clientTransport(); t != nil && s.opts.ClientConnStats {
+		opts = append(opts, mhttp.WithTransport(&statsTransport{rt: t, stats: s.conns}))
+	} else if t != nil {
+		opts = append(opts, mhttp.WithTransport(t))
+	}
+
+	rt := mhttp.NewRoundTripper(opts...)
 	return &http.Client{
 		Transport: rt,
 	}
 }
 
+// clientTransport returns the transport shared by the clients, nil to
+// use the default if the idle connections aren't tuned or counted.
+func (s *service) clientTransport() *http.Transport {
+	if s.opts.ClientMaxIdleConns <= 0 && s.opts.ClientMaxIdleConnsPerHost <= 0 && s.opts.ClientIdleConnTimeout <= 0 && !s.opts.ClientConnStats {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.transport != nil {
+		return s.transport
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if s.opts.ClientMaxIdleConns > 0 {
+		t.MaxIdleConns = s.opts.ClientMaxIdleConns
+	}
+
+	if s.opts.ClientMaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.opts.ClientMaxIdleConnsPerHost
+	}
+
+	if s.opts.ClientIdleConnTimeout > 0 {
+		t.IdleConnTimeout = s.opts.ClientIdleConnTimeout
+	}
+
+	if s.opts.ClientConnStats {
+		s.conns = newConnStats()
+		t.DialContext = s.conns.dial(t.DialContext)
+	}
+
+	s.transport = t
+
+	return t
+}
+
+// clientRegistry returns the registry clients resolve nodes
+// with, the cache shared by all clients if enabled.
+func (s *service) clientRegistry() registry.Registry {
+	if s.opts.ClientCacheTTL <= 0 {
+		return s.opts.Registry
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.cache == nil {
+		s.cache = cache.New(s.opts.Registry,
+			cache.WithTTL(s.opts.ClientCacheTTL),
+			cache.WithLogger(s.opts.Logger),
+		)
+	}
+
+	return s.cache
+}
+
+func (s *service) ConnStats() []ConnStats {
+	s.RLock()
+	conns := s.conns
+	s.RUnlock()
+
+	if conns == nil {
+		return nil
+	}
+
+	return conns.stats()
+}
+
 func (s *service) Handle(pattern string, handler http.Handler) {
 	var seen bool
 	s.RLock()
@@ -443,10 +941,19 @@ func (s *service) Init(opts ...Option) error {
 	s.opts.Service.Init(serviceOpts...)
 
 	s.Lock()
-	srv := s.genSrv()
+	defer s.Unlock()
+
+	srv, err := s.genSrv()
+	if err != nil {
+		return err
+	}
+
 	srv.Endpoints = s.srv.Endpoints
 	s.srv = srv
-	s.Unlock()
+
+	for _, ep := range s.opts.Endpoints {
+		s.addEndpoint(ep)
+	}
 
 	return nil
 }
@@ -456,7 +963,14 @@ func (s *service) Start() error {
 		return err
 	}
 
-	if err := s.register(); err != nil {
+	for _, fn := range s.opts.OnStart {
+		if err := fn(s); err != nil {
+			s.abort()
+			return err
+		}
+	}
+
+	if err := s.register(nil); err != nil {
 		return err
 	}
 
@@ -467,10 +981,13 @@ func (s *service) Start() error {
 }
 
 func (s *service) Stop() error {
-	// exit reg loop
-	close(s.ex)
+	for _, fn := range s.opts.OnStop {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
 
-	if err := s.deregister(); err != nil {
+	if err := s.leave(); err != nil {
 		return err
 	}
 
@@ -482,6 +999,13 @@ func (s *service) Run() error {
 		return err
 	}
 
+	for _, fn := range s.opts.OnStart {
+		if err := fn(s); err != nil {
+			s.abort()
+			return err
+		}
+	}
+
 	logger := s.opts.Logger
 	// start the profiler
 	if s.opts.Service.Options().Profile != nil {
@@ -501,7 +1025,7 @@ func (s *service) Run() error {
 		}()
 	}
 
-	if err := s.register(); err != nil {
+	if err := s.register(nil); err != nil {
 		return err
 	}
 
@@ -510,22 +1034,42 @@ func (s *service) Run() error {
 
 	ch := make(chan os.Signal, 1)
 	if s.opts.Signal {
-		signal.Notify(ch, signalutil.Shutdown()...)
+		signals := signalutil.Shutdown()
+		if len(s.opts.Signals) > 0 {
+			signals = s.opts.Signals
+		}
+
+		signal.Notify(ch, signals...)
+		defer signal.Stop(ch)
 	}
 
-	select {
-	// wait on kill signal
-	case sig := <-ch:
-		logger.Logf(log.InfoLevel, "Received signal %s", sig)
-	// wait on context cancel
-	case <-s.opts.Context.Done():
-		logger.Log(log.InfoLevel, "Received context shutdown")
+wait:
+	for {
+		select {
+		// wait on kill signal
+		case sig := <-ch:
+			logger.Logf(log.InfoLevel, "Received signal %s", sig)
+
+			// let the callback decide whether to shut down
+			if s.opts.OnSignal != nil && !s.opts.OnSignal(sig) {
+				continue
+			}
+
+			break wait
+		// wait on context cancel
+		case <-s.opts.Context.Done():
+			logger.Log(log.InfoLevel, "Received context shutdown")
+			break wait
+		}
 	}
 
-	// exit reg loop
-	close(s.ex)
+	for _, fn := range s.opts.OnStop {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
 
-	if err := s.deregister(); err != nil {
+	if err := s.leave(); err != nil {
 		return err
 	}
 
@@ -537,17 +1081,160 @@ func (s *service) Options() Options {
 	return s.opts
 }
 
+// propagateHandler stores the headers to propagate as the
+// metadata of the request context for the Client to send on.
+func (s *service) propagateHandler(h http.Handler) http.Handler {
+	headers := s.opts.PropagateHeaders
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := make(metadata.Metadata, len(headers))
+
+		for _, k := range headers {
+			if v := r.Header.Get(k); len(v) > 0 {
+				md[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+
+		if len(md) > 0 {
+			r = r.WithContext(metadata.MergeContext(r.Context(), md, true))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// secure reports whether the listener uses TLS.
+func (s *service) secure() bool {
+	return s.opts.Secure || s.opts.TLSConfig != nil || s.opts.RequireClientCert || len(s.opts.Certificates) > 0 || len(s.opts.CertFile) > 0
+}
+
+// rotateTickets sets fresh session ticket keys on the config every interval
+// until done, keeping the previous key so existing tickets can be resumed.
+func rotateTickets(config *tls.Config, interval time.Duration, done chan bool) {
+	var keys [][32]byte
+
+	rotate := func() {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return
+		}
+
+		keys = append([][32]byte{key}, keys...)
+		if len(keys) > 2 {
+			keys = keys[:2]
+		}
+
+		config.SetSessionTicketKeys(keys)
+	}
+
+	rotate()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			rotate()
+		case <-done:
+			return
+		}
+	}
+}
+
+// sniConfig returns a copy of the config serving the certificates by
+// SNI server name, falling back to the first host in sorted order.
+func (s *service) sniConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	hosts := make([]string, 0, len(s.opts.Certificates))
+	for host := range s.opts.Certificates {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	config.Certificates = nil
+	for _, host := range hosts {
+		config.Certificates = append(config.Certificates, s.opts.Certificates[host])
+	}
+
+	// a single certificate needs no lookup
+	if len(hosts) == 1 {
+		return config
+	}
+
+	certs := s.opts.Certificates
+
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert, ok := certs[strings.ToLower(hello.ServerName)]; ok {
+			return &cert, nil
+		}
+
+		// use the default certificate
+		return nil, nil
+	}
+
+	return config
+}
+
+// rawListen listens with the ListenConfig if set.
+func (s *service) rawListen(network, addr string) (net.Listener, error) {
+	var (
+		l       net.Listener
+		trusted []*net.IPNet
+		err     error
+	)
+
+	if s.opts.ProxyProtocol {
+		if trusted, err = parseTrusted(s.opts.ProxyProtocolTrusted); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.opts.ListenConfig == nil {
+		l, err = net.Listen(network, addr)
+	} else {
+		l, err = s.opts.ListenConfig.Listen(s.opts.Context, network, addr)
+	}
+
+	if err != nil || !s.opts.ProxyProtocol {
+		return l, err
+	}
+
+	return &proxyListener{Listener: l, trusted: trusted}, nil
+}
+
 func (s *service) listen(network, addr string) (net.Listener, error) {
 	var (
 		listener net.Listener
 		err      error
 	)
 
-	// TODO: support use of listen options
-	if s.opts.Secure || s.opts.TLSConfig != nil {
+	if s.secure() {
 		config := s.opts.TLSConfig
 
 		fn := func(addr string) (net.Listener, error) {
+			if len(s.opts.Certificates) > 0 {
+				config = s.sniConfig(config)
+			}
+
+			s.certs = nil
+
+			if len(s.opts.CertFile) > 0 {
+				certs, err := newCertReloader(s.opts.CertFile, s.opts.KeyFile, s.opts.Logger)
+				if err != nil {
+					return nil, err
+				}
+
+				s.certs = certs
+				config = certs.config(config)
+			}
+
 			if config == nil {
 				hosts := []string{addr}
 
@@ -568,13 +1255,42 @@ func (s *service) listen(network, addr string) (net.Listener, error) {
 				config = &tls.Config{Certificates: []tls.Certificate{cert}}
 			}
 
-			return tls.Listen(network, addr, config)
+			// only override the client auth when asked to
+			if s.opts.RequireClientCert {
+				config = config.Clone()
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+
+				if s.opts.ClientCAs != nil {
+					config.ClientCAs = s.opts.ClientCAs
+				}
+
+				// go verifies against the system roots without a pool,
+				// which any publicly issued certificate would pass
+				if config.ClientCAs == nil {
+					return nil, errors.New("client certificates are required but no ClientCAs are set")
+				}
+			}
+
+			s.tickets = nil
+
+			// rotate unless keys were set or tickets disabled
+			if s.opts.SessionTicketRotation > 0 && !config.SessionTicketsDisabled && config.SessionTicketKey == [32]byte{} {
+				config = config.Clone()
+				s.tickets = config
+			}
+
+			l, err := s.rawListen(network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			return tls.NewListener(l, config), nil
 		}
 
 		listener, err = mnet.Listen(addr, fn)
 	} else {
 		fn := func(addr string) (net.Listener, error) {
-			return net.Listen(network, addr)
+			return s.rawListen(network, addr)
 		}
 
 		listener, err = mnet.Listen(addr, fn)
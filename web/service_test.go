@@ -1,17 +1,39 @@
 package web
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"go-micro.org/v5"
+	"go-micro.org/v5/broker"
+	"go-micro.org/v5/client"
 	"go-micro.org/v5/registry"
+	"go-micro.org/v5/registry/cache"
+	mls "go-micro.org/v5/util/tls"
+	"golang.org/x/net/http2"
 )
 
 func TestService(t *testing.T) {
@@ -295,3 +317,1302 @@ func TestTLS(t *testing.T) {
 		}
 	}
 }
+
+func TestAddress(t *testing.T) {
+	var started, stopped string
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		OnStart(func(srv Service) error {
+			started = srv.Address()
+			return nil
+		}),
+		OnStop(func(srv Service) error {
+			stopped = srv.Address()
+			return nil
+		}),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, err := net.SplitHostPort(service.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host != "127.0.0.1" {
+		t.Errorf("Expected host 127.0.0.1 got %s", host)
+	}
+
+	if port == "0" {
+		t.Error("Expected resolved port got 0")
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if started != service.Address() || stopped != started {
+		t.Errorf("Expected hooks to receive %s got %s and %s", service.Address(), started, stopped)
+	}
+}
+
+func TestOnStartError(t *testing.T) {
+	var stopped bool
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		OnStart(func(srv Service) error {
+			return errors.New("start failed")
+		}),
+		BeforeStop(func() error {
+			stopped = true
+			return nil
+		}),
+	)
+
+	if err := service.Start(); err == nil {
+		t.Fatal("Expected the on start error")
+	}
+
+	// the listener is closed without running the stop hooks
+	if conn, err := net.Dial("tcp", service.Address()); err == nil {
+		conn.Close()
+		t.Fatal("Expected the listener to be closed")
+	}
+
+	if stopped {
+		t.Fatal("Expected the stop hooks not to run")
+	}
+}
+
+func TestH2C(t *testing.T) {
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		H2C(),
+	)
+
+	service.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	rsp, err := client.Get(fmt.Sprintf("http://%s", service.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0 got %s", string(b))
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		Handler(http.NewServeMux()),
+		Endpoint("/foo", EndpointMetadata("method", "GET")),
+		Endpoint("/bar"),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	s, err := reg.GetService("go.micro.web.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eps := s[0].Endpoints
+	if have, want := len(eps), 2; have != want {
+		t.Fatalf("Expected %d but got %d endpoints", want, have)
+	}
+
+	if eps[0].Name != "/foo" || eps[0].Metadata["method"] != "GET" {
+		t.Errorf("Unexpected endpoint %+v", eps[0])
+	}
+}
+
+type testMetrics struct {
+	sync.Mutex
+	begin  []string
+	status []int
+}
+
+func (m *testMetrics) Begin(method, path string) {
+	m.Lock()
+	defer m.Unlock()
+	m.begin = append(m.begin, method+" "+path)
+}
+
+func (m *testMetrics) End(method, path string, status int, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.status = append(m.status, status)
+}
+
+func TestRequestMetrics(t *testing.T) {
+	m := new(testMetrics)
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		RequestMetrics(m),
+	)
+
+	service.HandleFunc("/foo/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/foo/123", service.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	eventually(func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(m.status) > 0
+	}, t.Fatal)
+
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.begin) != 1 || m.begin[0] != "GET /foo/" {
+		t.Errorf("Unexpected metrics labels %v", m.begin)
+	}
+
+	if len(m.status) != 1 || m.status[0] != http.StatusAccepted {
+		t.Errorf("Unexpected metrics status %v", m.status)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		DrainPeriod(time.Millisecond*200),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- service.Stop()
+	}()
+
+	eventually(func() bool {
+		s, err := reg.GetService("go.micro.web.test")
+		return err == nil && s[0].Nodes[0].Metadata["status"] == "draining"
+	}, t.Fatal)
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reg.GetService("go.micro.web.test"); err != registry.ErrNotFound {
+		t.Fatalf("Expected service to be deregistered got %v", err)
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	regs := []registry.Registry{
+		registry.NewMemoryRegistry(),
+		registry.NewMemoryRegistry(),
+	}
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registries(regs...),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, reg := range regs {
+		if _, err := reg.GetService("go.micro.web.test"); err != nil {
+			t.Fatalf("Expected service in registry %d got %v", i, err)
+		}
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, reg := range regs {
+		if _, err := reg.GetService("go.micro.web.test"); err != registry.ErrNotFound {
+			t.Fatalf("Expected service deregistered from registry %d got %v", i, err)
+		}
+	}
+}
+
+func TestRegisterHooks(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	var (
+		service    Service
+		registered int
+		addr, id   string
+	)
+
+	// the hooks may use the service
+	service = NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		AfterRegister(func() error {
+			registered++
+			addr, id = service.Address(), service.Id()
+			return errors.New("notify failed")
+		}),
+		BeforeDeregister(func() error {
+			addr, id = service.Address(), service.Id()
+			return errors.New("not yet")
+		}),
+	)
+
+	done := make(chan error)
+
+	// after register errors don't fail the registration
+	go func() { done <- service.Start() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the after register hook not to deadlock")
+	}
+
+	if registered != 1 {
+		t.Fatalf("Expected after register hook to run once got %d", registered)
+	}
+
+	if addr != service.Address() || id != service.Id() {
+		t.Fatalf("Unexpected address %q and id %q in the hook", addr, id)
+	}
+
+	addr = ""
+
+	// before deregister errors abort the deregistration
+	go func() { done <- service.Stop() }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected before deregister error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the before deregister hook not to deadlock")
+	}
+
+	if addr != service.Address() {
+		t.Fatalf("Unexpected address %q in the hook", addr)
+	}
+
+	if _, err := reg.GetService("go.micro.web.test"); err != nil {
+		t.Fatalf("Expected service to stay registered got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+	brk := broker.NewMemoryBroker()
+
+	ms := micro.NewService(
+		micro.Name("go.micro.web.test"),
+		micro.Registry(reg),
+		micro.Broker(brk),
+	)
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		MicroService(ms),
+	)
+
+	got := make(chan string, 1)
+
+	err := service.Subscribe("test.topic", func(ctx context.Context, msg map[string]string) error {
+		got <- msg["key"]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the handler must take a context and return an error
+	if err := service.Subscribe("test.topic", func(msg map[string]string) {}); err == nil {
+		t.Fatal("Expected an invalid subscriber error")
+	}
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	// only the web node is registered
+	services, err := reg.GetService("go.micro.web.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("Expected the web node only got %+v", services)
+	}
+
+	if err := micro.NewEvent("test.topic", ms.Client()).Publish(context.TODO(), map[string]string{"key": "val"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case v := <-got:
+		if v != "val" {
+			t.Fatalf("Expected val got %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the message")
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscription ends with the service
+	if err := micro.NewEvent("test.topic", ms.Client()).Publish(context.TODO(), map[string]string{"key": "again"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case v := <-got:
+		t.Fatalf("Expected no message after stopping got %s", v)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		ClientCAs(x509.NewCertPool()),
+	)
+
+	service.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	// the handshake fails without a client certificate
+	if rsp, err := client.Get("https://" + service.Address()); err == nil {
+		rsp.Body.Close()
+		t.Fatal("Expected request without a client certificate to fail")
+	}
+
+	// the system roots aren't used to verify client certificates
+	service = NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		RequireClientCert(),
+	)
+
+	if err := service.Start(); err == nil {
+		service.Stop()
+		t.Fatal("Expected an error without client CAs")
+	}
+
+	// the CAs of the tls config are used
+	service = NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		TLSConfig(&tls.Config{ClientCAs: x509.NewCertPool()}),
+		RequireClientCert(),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	service.Stop()
+}
+
+func TestCertificates(t *testing.T) {
+	certs := make(map[string]tls.Certificate)
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		cert, err := mls.Certificate(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		certs[host] = cert
+	}
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		Certificates(certs),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		conn, err := tls.Dial("tcp", service.Address(), &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := conn.ConnectionState().PeerCertificates[0].DNSNames
+		conn.Close()
+
+		if len(names) != 1 || names[0] != host {
+			t.Fatalf("Expected certificate for %s got %v", host, names)
+		}
+	}
+}
+
+func TestAdvertiseFunc(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		AdvertiseFunc(func() (string, error) {
+			return "203.0.113.5", nil
+		}),
+	)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	s, err := reg.GetService("go.micro.web.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, port, _ := net.SplitHostPort(service.Address())
+
+	if want := "203.0.113.5:" + port; s[0].Nodes[0].Address != want {
+		t.Fatalf("Expected address %s got %s", want, s[0].Nodes[0].Address)
+	}
+}
+
+func TestRegisterAddressError(t *testing.T) {
+	s := newService(
+		Name("go.micro.web.test"),
+		Registry(registry.NewMemoryRegistry()),
+	).(*service)
+
+	// a bad address fails the registration rather than the process
+	s.opts.Address = "bad-address"
+
+	if err := s.register(nil); err == nil {
+		t.Fatal("Expected register error")
+	}
+}
+
+func TestRegisterCheckAll(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	var dbDown = true
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+		RegisterCheckAll(
+			func(ctx context.Context) error {
+				if o, ok := OptionsFromContext(ctx); !ok || o.Name != "go.micro.web.test" {
+					return errors.New("no options")
+				}
+				return nil
+			},
+			func(context.Context) error {
+				if dbDown {
+					return errors.New("db down")
+				}
+				return nil
+			},
+		),
+	).(*service)
+
+	err := service.Start()
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 checks failed: db down") {
+		t.Fatalf("Expected check error got %v", err)
+	}
+
+	dbDown = false
+
+	if err := service.register(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reg.GetService("go.micro.web.test"); err != nil {
+		t.Fatalf("Expected service to be registered got %v", err)
+	}
+
+	service.Stop()
+}
+
+func TestOpenAPI(t *testing.T) {
+	service := NewService(
+		Name("go.micro.web.test"),
+		Version("1.0.0"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		OpenAPIPath(DefaultOpenAPIPath),
+		Endpoint("/users/{id}",
+			EndpointMetadata("method", "GET,DELETE"),
+			EndpointMetadata("description", "A user"),
+			EndpointResponse(&registry.Value{
+				Name: "User",
+				Type: "User",
+				Values: []*registry.Value{
+					{Name: "name", Type: "string"},
+					{Name: "age", Type: "int64"},
+					{Name: "tags", Type: "[]string"},
+				},
+			}),
+		),
+	)
+
+	service.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s%s", service.Address(), DefaultOpenAPIPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			Summary    string `json:"summary"`
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Type       string                            `json:"type"`
+						Properties map[string]map[string]interface{} `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.0.3" || doc.Info.Title != "go.micro.web.test" || doc.Info.Version != "1.0.0" {
+		t.Fatalf("Unexpected document %+v", doc)
+	}
+
+	// endpoints without metadata get a minimal entry
+	if item, ok := doc.Paths["/health"]; !ok || len(item) != 0 {
+		t.Fatalf("Expected a minimal /health entry got %+v", item)
+	}
+
+	user := doc.Paths["/users/{id}"]
+	if len(user) != 2 {
+		t.Fatalf("Expected get and delete operations got %+v", user)
+	}
+
+	op := user["get"]
+	if op.Summary != "A user" || len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" {
+		t.Fatalf("Unexpected operation %+v", op)
+	}
+
+	s := op.Responses["default"].Content["application/json"].Schema
+	if s.Type != "object" || s.Properties["age"]["type"] != "integer" || s.Properties["tags"]["type"] != "array" {
+		t.Fatalf("Unexpected response schema %+v", s)
+	}
+}
+
+func TestListenConfig(t *testing.T) {
+	var called int32
+
+	lc := &net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			atomic.AddInt32(&called, 1)
+			return nil
+		},
+	}
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		ListenConfig(lc),
+	)
+
+	service.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	if atomic.LoadInt32(&called) != 1 {
+		t.Fatalf("Expected the listen config control to be called")
+	}
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/", service.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+}
+
+func TestSessionTicketRotation(t *testing.T) {
+	interval := time.Millisecond * 200
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		Secure(true),
+		SessionTicketRotation(interval),
+	)
+
+	service.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	// tls 1.2 issues the ticket in the handshake
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+
+	resumed := func() bool {
+		conn, err := tls.Dial("tcp", service.Address(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().DidResume
+	}
+
+	if resumed() {
+		t.Fatal("Expected a full handshake")
+	}
+
+	if !resumed() {
+		t.Fatal("Expected the session to resume")
+	}
+
+	// the ticket's key is dropped after two rotations
+	time.Sleep(interval * 3)
+
+	if resumed() {
+		t.Fatal("Expected the session not to resume with a rotated key")
+	}
+}
+
+func TestClientRegistryCache(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	s := newService(Registry(reg)).(*service)
+	if r := s.clientRegistry(); r != reg {
+		t.Fatal("expected the registry without a cache")
+	}
+
+	s = newService(Registry(reg), ClientRegistryCache(time.Minute)).(*service)
+
+	r := s.clientRegistry()
+	if _, ok := r.(cache.Cache); !ok {
+		t.Fatalf("expected a registry cache got %T", r)
+	}
+
+	// the cache is shared by the clients
+	if s.clientRegistry() != r {
+		t.Fatal("expected the same cache")
+	}
+}
+
+func TestServiceDrain(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	service := NewService(
+		Name("go.micro.web.drain"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+	)
+
+	started := make(chan bool)
+	release := make(chan bool)
+
+	service.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := service.Address()
+
+	// an in-flight request
+	errCh := make(chan error, 1)
+
+	go func() {
+		rsp, err := http.Get(fmt.Sprintf("http://%s/slow", addr))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer rsp.Body.Close()
+
+		b, _ := io.ReadAll(rsp.Body)
+		if string(b) != "done" {
+			err = fmt.Errorf("unexpected body %q", b)
+		}
+
+		errCh <- err
+	}()
+
+	<-started
+
+	drained := make(chan error, 1)
+
+	go func() {
+		drained <- service.Drain(context.Background())
+	}()
+
+	// wait for the service to be deregistered
+	for i := 0; ; i++ {
+		if _, err := reg.GetService("go.micro.web.drain"); err == registry.ErrNotFound {
+			break
+		}
+
+		if i == 100 {
+			t.Fatal("Expected the service to be deregistered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("Expected the in-flight request to finish: %v", err)
+	}
+
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+
+	// no new connections are accepted
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatal("Expected the listener to be closed")
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Fatalf("Expected stop after drain to succeed: %v", err)
+	}
+}
+
+func TestClientPropagateHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("Cookie")))
+	}))
+	defer backend.Close()
+
+	reg := registry.NewMemoryRegistry()
+	reg.Register(&registry.Service{
+		Name:  "go.micro.web.backend",
+		Nodes: []*registry.Node{{Id: "1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+	})
+
+	s := newService(Registry(reg), ClientPropagateHeaders("authorization")).(*service)
+
+	h := s.propagateHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", "http://go.micro.web.backend/", nil)
+
+		rsp, err := s.Client().Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer rsp.Body.Close()
+
+		io.Copy(w, rsp.Body)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=1")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	// only the configured headers are propagated
+	if got := w.Body.String(); got != "Bearer token|" {
+		t.Fatalf("expected the authorization header to be propagated got %q", got)
+	}
+}
+
+func TestClientIdleConnTimeout(t *testing.T) {
+	var conns int32
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	backend.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	reg := registry.NewMemoryRegistry()
+	reg.Register(&registry.Service{
+		Name:  "go.micro.web.backend",
+		Nodes: []*registry.Node{{Id: "1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+	})
+
+	s := newService(
+		Registry(reg),
+		ClientMaxIdleConnsPerHost(4),
+		ClientIdleConnTimeout(50*time.Millisecond),
+	).(*service)
+
+	// the clients share the tuned transport
+	tr := s.clientTransport()
+	if tr == nil || tr != s.clientTransport() {
+		t.Fatal("expected a shared transport")
+	}
+
+	if tr.MaxIdleConnsPerHost != 4 || tr.IdleConnTimeout != 50*time.Millisecond {
+		t.Fatalf("unexpected transport settings %d %v", tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+
+	get := func() {
+		rsp, err := s.Client().Get("http://go.micro.web.backend/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}
+
+	// the idle connection is reused
+	get()
+	get()
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("expected 1 connection got %d", n)
+	}
+
+	// and closed after the timeout
+	time.Sleep(200 * time.Millisecond)
+	get()
+
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Fatalf("expected 2 connections got %d", n)
+	}
+
+	// the default transport is used unless tuned
+	if newService().(*service).clientTransport() != nil {
+		t.Fatal("expected the default transport")
+	}
+}
+
+func TestClientConnStats(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	host := strings.TrimPrefix(backend.URL, "http://")
+
+	reg := registry.NewMemoryRegistry()
+	reg.Register(&registry.Service{
+		Name:  "go.micro.web.backend",
+		Nodes: []*registry.Node{{Id: "1", Address: host}},
+	})
+
+	s := newService(Registry(reg), ClientConnStats(true))
+
+	done := make(chan error, 1)
+
+	go func() {
+		rsp, err := s.Client().Get("http://go.micro.web.backend/")
+		if err == nil {
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+		done <- err
+	}()
+
+	<-started
+
+	if stats := s.ConnStats(); len(stats) != 1 || stats[0] != (ConnStats{Host: host, Active: 1}) {
+		t.Fatalf("expected an active connection got %+v", stats)
+	}
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the connection is kept for the next request
+	if stats := s.ConnStats(); len(stats) != 1 || stats[0] != (ConnStats{Host: host, Idle: 1}) {
+		t.Fatalf("expected an idle connection got %+v", stats)
+	}
+
+	// and dropped once closed
+	s.(*service).transport.CloseIdleConnections()
+
+	if stats := s.ConnStats(); len(stats) != 0 {
+		t.Fatalf("expected no connections got %+v", stats)
+	}
+
+	// connections aren't counted by default
+	if newService().ConnStats() != nil {
+		t.Fatal("expected no stats")
+	}
+}
+
+func TestRegisterNoRegistry(t *testing.T) {
+	ms := micro.NewService(micro.Client(client.NewClient(client.Registry(nil))))
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		MicroService(ms),
+	)
+
+	// a clean error rather than a nil registry panic
+	if err := service.Start(); !errors.Is(err, ErrNoRegistry) {
+		t.Fatalf("Expected %v got %v", ErrNoRegistry, err)
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNodeId(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	newWeb := func(opts ...Option) Service {
+		return NewService(append([]Option{
+			Name("go.micro.web.test"),
+			Registry(reg),
+			Hostname("node-0"),
+		}, opts...)...)
+	}
+
+	// the same host and port get the same id
+	a := newWeb(Address("127.0.0.1:8080"))
+	b := newWeb(Address("127.0.0.1:8080"))
+
+	if len(a.Id()) == 0 || a.Id() != b.Id() {
+		t.Fatalf("Expected a stable id got %q and %q", a.Id(), b.Id())
+	}
+
+	if c := newWeb(Address("127.0.0.1:8081")); c.Id() == a.Id() {
+		t.Fatal("Expected another port to get another id")
+	}
+
+	if c := newWeb(Address("127.0.0.1:8080"), Hostname("node-1")); c.Id() == a.Id() {
+		t.Fatal("Expected another host to get another id")
+	}
+
+	// an explicit id is kept
+	if c := newWeb(Address("127.0.0.1:8080"), Id("foo")); c.Id() != "foo" {
+		t.Fatalf("Expected id foo got %q", c.Id())
+	}
+
+	// the node is registered with the id of the listener port
+	s := newWeb(Address("127.0.0.1:0"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	services, err := reg.GetService("go.micro.web.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := services[0].Nodes[0].Id; id != s.Id() {
+		t.Fatalf("Expected node id %q got %q", s.Id(), id)
+	}
+
+	if s.Id() != newWeb(Address(s.Address())).Id() {
+		t.Fatal("Expected the id of the address")
+	}
+}
+
+func TestCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	// write replaces the files with a certificate of the host
+	write := func(host string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: host},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kder, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// swapped in like a mounted secret
+		for file, block := range map[string]*pem.Block{
+			certFile: {Type: "CERTIFICATE", Bytes: der},
+			keyFile:  {Type: "EC PRIVATE KEY", Bytes: kder},
+		} {
+			if err := os.WriteFile(file+".tmp", pem.EncodeToMemory(block), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Rename(file+".tmp", file); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	write("one")
+
+	s := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(registry.NewMemoryRegistry()),
+		CertFiles(certFile, keyFile),
+	)
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	served := func() string {
+		conn, err := tls.Dial("tcp", s.Address(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if host := served(); host != "one" {
+		t.Fatalf("Expected the certificate of one got %s", host)
+	}
+
+	// the rotated files are watched
+	write("two")
+
+	eventually(func() bool { return served() == "two" }, t.Fatal)
+
+	// and may be reloaded explicitly
+	write("three")
+
+	if err := s.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+
+	if host := served(); host != "three" {
+		t.Fatalf("Expected the certificate of three got %s", host)
+	}
+
+	// a broken file keeps the current certificate
+	os.WriteFile(keyFile, []byte("broken"), 0o600)
+
+	if err := s.ReloadTLS(); err == nil {
+		t.Fatal("Expected a reload error")
+	}
+
+	if host := served(); host != "three" {
+		t.Fatalf("Expected the certificate of three got %s", host)
+	}
+
+	if err := NewService().ReloadTLS(); err == nil {
+		t.Fatal("Expected an error without certificate files")
+	}
+}
+
+func TestHandleMethod(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+
+	service := NewService(
+		Name("go.micro.web.test"),
+		Address("127.0.0.1:0"),
+		Registry(reg),
+	)
+
+	service.HandleMethod("post", "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("created"))
+	}))
+	service.HandleMethod("GET", "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("listed"))
+	}))
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	url := fmt.Sprintf("http://%s/items", service.Address())
+
+	for _, test := range []struct {
+		method string
+		status int
+		body   string
+	}{
+		{"POST", http.StatusOK, "created"},
+		{"GET", http.StatusOK, "listed"},
+		{"HEAD", http.StatusOK, ""},
+		{"DELETE", http.StatusMethodNotAllowed, ""},
+	} {
+		req, _ := http.NewRequest(test.method, url, nil)
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != test.status {
+			t.Fatalf("%s: expected status %d got %d", test.method, test.status, rsp.StatusCode)
+		}
+
+		if test.status == http.StatusMethodNotAllowed {
+			if allow := rsp.Header.Get("Allow"); allow != "POST, GET" {
+				t.Fatalf("Expected Allow POST, GET got %q", allow)
+			}
+			continue
+		}
+
+		if string(b) != test.body {
+			t.Fatalf("%s: expected body %q got %q", test.method, test.body, b)
+		}
+	}
+
+	services, err := reg.GetService("go.micro.web.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services[0].Endpoints) != 1 || services[0].Endpoints[0].Metadata["method"] != "POST,GET" {
+		t.Fatalf("Expected the /items endpoint with the POST,GET methods got %+v", services[0].Endpoints)
+	}
+}
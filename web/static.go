@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"path"
+)
+
+// staticHandler serves the static files, falling back to the
+// index file or the not found handler for unmatched paths.
+type staticHandler struct {
+	dir      http.Dir
+	files    http.Handler
+	index    string
+	spa      bool
+	notFound http.Handler
+}
+
+func (s *service) staticHandler(dir string) http.Handler {
+	if !s.opts.StaticSPAFallback && s.opts.NotFoundHandler == nil {
+		return http.FileServer(http.Dir(dir))
+	}
+
+	return &staticHandler{
+		dir:      http.Dir(dir),
+		files:    http.FileServer(http.Dir(dir)),
+		index:    s.opts.StaticIndex,
+		spa:      s.opts.StaticSPAFallback,
+		notFound: s.opts.NotFoundHandler,
+	}
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := h.dir.Open(path.Clean("/" + r.URL.Path))
+	if err == nil {
+		f.Close()
+		h.files.ServeHTTP(w, r)
+
+		return
+	}
+
+	if !os.IsNotExist(err) {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	if h.spa && (r.Method == http.MethodGet || r.Method == http.MethodHead) && h.serveIndex(w, r) {
+		return
+	}
+
+	if h.notFound != nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// serveIndex serves the index file, returning false if there is none.
+func (h *staticHandler) serveIndex(w http.ResponseWriter, r *http.Request) bool {
+	f, err := h.dir.Open(path.Clean("/" + h.index))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
+	}
+
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+
+	return true
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticFallback(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("index"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom"))
+	})
+
+	testData := []struct {
+		opts []Option
+		path string
+		code int
+		body string
+	}{
+		{[]Option{StaticSPAFallback(true)}, "/app.js", 200, "app"},
+		{[]Option{StaticSPAFallback(true)}, "/users/1", 200, "index"},
+		{[]Option{NotFoundHandler(notFound)}, "/users/1", 404, "custom"},
+		{[]Option{StaticSPAFallback(true), StaticIndex("missing.html"), NotFoundHandler(notFound)}, "/users/1", 404, "custom"},
+	}
+
+	for _, d := range testData {
+		s := &service{opts: newOptions(d.opts...)}
+
+		w := httptest.NewRecorder()
+		s.staticHandler(dir).ServeHTTP(w, httptest.NewRequest("GET", d.path, nil))
+
+		if w.Code != d.code || w.Body.String() != d.body {
+			t.Fatalf("Expected %d %s for %s got %d %s", d.code, d.body, d.path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go-micro.org/v5/broker"
+	"go-micro.org/v5/codec"
+	log "go-micro.org/v5/logger"
+	"go-micro.org/v5/metadata"
+	"go-micro.org/v5/server"
+	"go-micro.org/v5/util/buf"
+)
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// subscriber is a topic subscription of Subscribe, decoding each message
+// into the argument of its handlers as the micro server does.
+type subscriber struct {
+	topic string
+	opts  server.SubscriberOptions
+	// handlers are the func or the methods of the handler
+	handlers []reflect.Value
+	// sub is set while the service runs
+	sub broker.Subscriber
+}
+
+// newSubscriber validates the handler, a func or the methods of a value
+// with the signature func(context.Context, interface{}) error.
+func newSubscriber(topic string, h interface{}, opts ...server.SubscriberOption) (*subscriber, error) {
+	v := reflect.ValueOf(h)
+	if !v.IsValid() {
+		return nil, errors.New("subscriber is nil")
+	}
+
+	var handlers []reflect.Value
+
+	if v.Kind() == reflect.Func {
+		handlers = append(handlers, v)
+	} else {
+		for i := 0; i < v.NumMethod(); i++ {
+			handlers = append(handlers, v.Method(i))
+		}
+	}
+
+	if len(handlers) == 0 {
+		return nil, fmt.Errorf("subscriber %T has no handler functions", h)
+	}
+
+	for _, fn := range handlers {
+		typ := fn.Type()
+
+		if typ.NumIn() != 2 || typ.In(0) != typeOfContext || typ.NumOut() != 1 || typ.Out(0) != typeOfError {
+			return nil, fmt.Errorf("subscriber %T has signature %v, required func(context.Context, interface{}) error", h, typ)
+		}
+	}
+
+	return &subscriber{
+		topic:    topic,
+		opts:     server.NewSubscriberOptions(opts...),
+		handlers: handlers,
+	}, nil
+}
+
+// handle decodes the message for each handler and calls it.
+func (s *subscriber) handle(e broker.Event) error {
+	msg := e.Message()
+
+	ct := msg.Header["Content-Type"]
+	if len(ct) == 0 {
+		ct = server.DefaultContentType
+	}
+
+	cf, ok := server.DefaultCodecs[ct]
+	if !ok {
+		return fmt.Errorf("unsupported Content-Type: %s", ct)
+	}
+
+	header := make(map[string]string, len(msg.Header))
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+
+	ctx := metadata.NewContext(context.Background(), header)
+
+	var errs []string
+
+	for _, fn := range s.handlers {
+		typ := fn.Type().In(1)
+
+		var arg reflect.Value
+		if typ.Kind() == reflect.Ptr {
+			arg = reflect.New(typ.Elem())
+		} else {
+			arg = reflect.New(typ)
+		}
+
+		cc := cf(buf.New(bytes.NewBuffer(msg.Body)))
+
+		if err := cc.ReadHeader(&codec.Message{}, codec.Event); err != nil {
+			return err
+		}
+
+		if err := cc.ReadBody(arg.Interface()); err != nil {
+			return err
+		}
+
+		if typ.Kind() != reflect.Ptr {
+			arg = arg.Elem()
+		}
+
+		out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+		if err, ok := out[0].Interface().(error); ok && err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// Subscribe adds a subscriber for the topic whose messages are consumed
+// from the broker of the micro service while the web service runs. The
+// micro server isn't started so no rpc node is registered. Subscribers
+// must be added before the service starts.
+func (s *service) Subscribe(topic string, h interface{}, opts ...server.SubscriberOption) error {
+	sub, err := newSubscriber(topic, h, opts...)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.running {
+		return errors.New("subscribe must be called before the service starts")
+	}
+
+	s.subscribers = append(s.subscribers, sub)
+
+	return nil
+}
+
+// subscribe connects the broker and subscribes to the topics of the
+// subscribers, unsubscribing them all if one fails. The caller must
+// hold the lock.
+func (s *service) subscribe() error {
+	if len(s.subscribers) == 0 {
+		return nil
+	}
+
+	brk := s.opts.Service.Options().Broker
+
+	if err := brk.Connect(); err != nil {
+		return err
+	}
+
+	for _, sub := range s.subscribers {
+		var opts []broker.SubscribeOption
+
+		if len(sub.opts.Queue) > 0 {
+			opts = append(opts, broker.Queue(sub.opts.Queue))
+		}
+
+		if sub.opts.Context != nil {
+			opts = append(opts, broker.SubscribeContext(sub.opts.Context))
+		}
+
+		if !sub.opts.AutoAck {
+			opts = append(opts, broker.DisableAutoAck())
+		}
+
+		bs, err := brk.Subscribe(sub.topic, sub.handle, opts...)
+		if err != nil {
+			s.unsubscribe()
+			return err
+		}
+
+		sub.sub = bs
+	}
+
+	return nil
+}
+
+// unsubscribe ends the subscriptions. The caller must hold the lock.
+func (s *service) unsubscribe() {
+	for _, sub := range s.subscribers {
+		if sub.sub == nil {
+			continue
+		}
+
+		if err := sub.sub.Unsubscribe(); err != nil {
+			s.opts.Logger.Logf(log.ErrorLevel, "Unsubscribe from %s error: %v", sub.topic, err)
+		}
+
+		sub.sub = nil
+	}
+}
@@ -6,18 +6,37 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
+	"go-micro.org/v5/server"
 )
 
 // Service is a web service with service discovery built in.
 type Service interface {
+	Address() string
+	// Id returns the id the node is registered with
+	Id() string
 	Client() *http.Client
+	// ConnStats returns the connections of the Client by node,
+	// nil unless enabled with the ClientConnStats option
+	ConnStats() []ConnStats
 	Init(opts ...Option) error
 	Options() Options
 	Handle(pattern string, handler http.Handler)
 	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+	// HandleMethod registers the handler for requests of the method,
+	// others get a 405. The methods of a pattern accumulate in the
+	// "method" metadata of its endpoint.
+	HandleMethod(method, pattern string, handler http.Handler)
+	// OpenAPI returns an OpenAPI 3.0 document of the registered endpoints
+	OpenAPI() ([]byte, error)
+	Subscribe(topic string, h interface{}, opts ...server.SubscriberOption) error
 	Start() error
 	Stop() error
+	// ReloadTLS reloads the certificate of the CertFiles
+	// for new connections, the files are also watched
+	ReloadTLS() error
+	// Drain deregisters the service and stops accepting
+	// connections while in-flight requests finish
+	Drain(ctx context.Context) error
 	Run() error
 }
 
@@ -29,7 +48,9 @@ var (
 	// For serving.
 	DefaultName    = "go-web"
 	DefaultVersion = "latest"
-	DefaultId      = uuid.New().String()
+	// DefaultId is the node id of services without an Id, one
+	// is derived from the name, hostname and port if empty.
+	DefaultId      = ""
 	DefaultAddress = ":0"
 
 	// for registration.
@@ -38,6 +59,8 @@ var (
 
 	// static directory.
 	DefaultStaticDir     = "html"
+	DefaultStaticIndex   = "index.html"
+	DefaultOpenAPIPath   = "/openapi.json"
 	DefaultRegisterCheck = func(context.Context) error { return nil }
 )
 
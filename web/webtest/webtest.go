@@ -0,0 +1,85 @@
+// Package webtest provides utilities for testing web services.
+package webtest
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-micro.org/v5/registry"
+	"go-micro.org/v5/web"
+)
+
+// DefaultName is the name of services without a Name option.
+var DefaultName = "go.micro.web.test"
+
+// Server is a web service listening on a random local port and registered
+// with its own memory registry, analogous to an httptest.Server.
+type Server struct {
+	// URL is the base url of the service, e.g. http://127.0.0.1:54321
+	URL string
+	// Service is the web service, handlers may be
+	// added to it before an unstarted server is started
+	Service web.Service
+	// Registry the service is registered with
+	Registry registry.Registry
+}
+
+// NewServer starts and returns a new web service. The options are applied
+// after the defaults so they may e.g. set a Handler or another Registry.
+// The caller should call Close when finished, to shut it down.
+func NewServer(opts ...web.Option) *Server {
+	s := NewUnstartedServer(opts...)
+	s.Start()
+
+	return s
+}
+
+// NewUnstartedServer returns a new web service which isn't started,
+// so handlers can be registered before they're advertised. The caller
+// should call Start and then Close when finished.
+func NewUnstartedServer(opts ...web.Option) *Server {
+	options := []web.Option{
+		web.Name(DefaultName),
+		web.Address("127.0.0.1:0"),
+		web.Registry(registry.NewMemoryRegistry()),
+	}
+
+	service := web.NewService(append(options, opts...)...)
+
+	return &Server{
+		Service:  service,
+		Registry: service.Options().Registry,
+	}
+}
+
+// Start starts the service, it panics if it fails to.
+func (s *Server) Start() {
+	if len(s.URL) > 0 {
+		panic("webtest: Server already started")
+	}
+
+	if err := s.Service.Start(); err != nil {
+		panic(fmt.Sprintf("webtest: failed to start service: %v", err))
+	}
+
+	scheme := "http"
+
+	opts := s.Service.Options()
+	if opts.Secure || opts.TLSConfig != nil || opts.RequireClientCert || len(opts.Certificates) > 0 || len(opts.CertFile) > 0 {
+		scheme = "https"
+	}
+
+	s.URL = scheme + "://" + s.Service.Address()
+}
+
+// Close deregisters and stops the service.
+func (s *Server) Close() {
+	s.Service.Stop()
+}
+
+// Client returns the client of the service, which resolves the
+// hosts of requests by service name in the registry, e.g.
+// http://go.micro.web.test/foo is sent to the service.
+func (s *Server) Client() *http.Client {
+	return s.Service.Client()
+}
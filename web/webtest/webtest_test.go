@@ -0,0 +1,46 @@
+package webtest
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := NewUnstartedServer()
+	s.Service.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	s.Start()
+	defer s.Close()
+
+	services, err := s.Registry.GetService(DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 || len(services[0].Nodes) != 1 || len(services[0].Endpoints) != 1 {
+		t.Fatalf("Expected a registered node and endpoint got %+v", services)
+	}
+
+	for _, test := range []struct {
+		url    string
+		client *http.Client
+	}{
+		{s.URL + "/hello", http.DefaultClient},
+		// resolved in the registry
+		{"http://" + DefaultName + "/hello", s.Client()},
+	} {
+		rsp, err := test.client.Get(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if string(b) != "hello" {
+			t.Fatalf("%s: expected hello got %q", test.url, b)
+		}
+	}
+}